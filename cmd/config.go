@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/jllovet/go-server-template/config"
+)
+
+// configCommand runs the config command, whose only subcommand, print,
+// lists the effective configuration with the source of each setting and
+// secrets redacted. It takes the same flags as serve.
+func configCommand(
+	ctx context.Context,
+	args []string,
+	getenv func(key string, defaultValue string) string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: config print [flags]")
+	}
+	settings, err := config.Explain(getenv, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(stdout)
+		return nil
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	for _, s := range settings {
+		value := s.Value
+		if s.Secret && value != "" {
+			value += " (redacted)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, value, s.Source)
+	}
+	if flushErr := w.Flush(); flushErr != nil {
+		return flushErr
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
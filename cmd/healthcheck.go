@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// healthcheck asks the server at the configured address whether it is
+// healthy, or with --ready whether it is ready, failing unless it answers
+// 200 OK. It needs no other tools, which suits a Docker HEALTHCHECK.
+func healthcheck(
+	ctx context.Context,
+	args []string,
+	getenv func(key string, defaultValue string) string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	ready := flags.Bool("ready", false, "check GET /ready instead of GET /healthz")
+	timeout := flags.Duration("timeout", 5*time.Second, "how long to wait for an answer")
+	config, err := loadConfig("healthcheck", getenv, args, flags, stdout)
+	if err != nil || config == nil {
+		return err
+	}
+
+	host := config.Host
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		// The server listens on every address; ask it on the loopback.
+		host = "localhost"
+	}
+	scheme := "http"
+	client := &http.Client{Timeout: *timeout}
+	if config.CertFile != "" {
+		scheme = "https"
+		// The certificate names the server's public address, not the one
+		// checked here, and only the status is read.
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	path := "/healthz"
+	if *ready {
+		path = "/ready"
+	}
+	url := scheme + "://" + net.JoinHostPort(host, strconv.Itoa(config.Port)) + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("healthcheck: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("healthcheck: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("healthcheck: %s answered %s: %s", url, resp.Status, body)
+	}
+	fmt.Fprintf(stdout, "%s\n", body)
+	return nil
+}
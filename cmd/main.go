@@ -2,29 +2,30 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net"
-	"net/http"
+	"log/slog"
+	"maps"
 	"os"
 	"os/signal"
-	"sync"
-	"time"
+	"slices"
+	"strings"
+	"text/tabwriter"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/joho/godotenv"
+	"github.com/segmentio/ksuid"
 
 	"github.com/jllovet/go-server-template/config"
-	"github.com/jllovet/go-server-template/internal/server"
-	"github.com/jllovet/go-server-template/internal/todo"
-	"github.com/jllovet/go-server-template/internal/todo/memory"
-	"github.com/jllovet/go-server-template/internal/todo/postgres"
 	"github.com/jllovet/go-server-template/logger"
 )
 
 // See: https://grafana.com/blog/2024/02/09/how-i-write-http-services-in-go-after-13-years/
 func main() {
 	ctx := context.Background()
+	// Variables in .env fill in for those missing from the environment.
+	_ = godotenv.Load()
 	if err := run(
 		ctx,
 		os.Args,
@@ -38,6 +39,34 @@ func main() {
 	}
 }
 
+// command is a subcommand of the server binary. Like run, it gets every
+// input as an argument so that it can be tested in-process; args are those
+// following the command's name.
+type command struct {
+	summary string
+	run     func(
+		ctx context.Context,
+		args []string,
+		getenv func(key string, defaultValue string) string,
+		stdin io.Reader,
+		stdout, stderr io.Writer,
+	) error
+}
+
+func commands() map[string]command {
+	return map[string]command{
+		"serve":       {"Run the HTTP server (the default command)", serve},
+		"migrate":     {"Create or update the database schema", migrate},
+		"seed":        {"Add example todos", seed},
+		"export":      {"Write every todo to stdout as JSON, NDJSON or CSV", export},
+		"import":      {"Import the todos read from stdin as JSON, NDJSON or CSV", importTodos},
+		"healthcheck": {"Check that a running server is healthy", healthcheck},
+		"version":     {"Print the version", printVersion},
+		"config":      {"Print the effective configuration (config print)", configCommand},
+	}
+}
+
+// run runs the command named by args[1], or serves when there is none.
 func run(
 	ctx context.Context,
 	args []string,
@@ -47,63 +76,95 @@ func run(
 ) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
 	defer cancel()
-	config := &config.InitializedConfig
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage(stdout)
+		return nil
+	}
+	cmd, ok := commands()[name]
+	if !ok {
+		usage(stderr)
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(ctx, args, getenv, stdin, stdout, stderr)
+}
 
-	logOutput := stdout
-	if getenv("DISABLE_LOGGING", "") == "true" {
-		logOutput = io.Discard
+// usage lists the commands.
+func usage(w io.Writer) {
+	cmds := commands()
+	fmt.Fprintf(w, "Usage: server [command] [flags]\n\nCommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range slices.Sorted(maps.Keys(cmds)) {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, cmds[name].summary)
 	}
-	logger := logger.New(logOutput, getenv("SERVICE_NAME", "todo-service"))
+	tw.Flush()
+	fmt.Fprintf(w, "\nRun \"server <command> -h\" for the flags and settings of a command.\n")
+}
 
-	var repo todo.Repository
-	if config.DatabaseURL != "" {
-		db, err := sql.Open("pgx", config.DatabaseURL)
-		if err != nil {
-			return fmt.Errorf("open db: %w", err)
-		}
-		defer db.Close()
-		if err := db.Ping(); err != nil {
-			return fmt.Errorf("ping db: %w", err)
+// loadConfig loads the configuration for the command name, accepting the
+// command's own flags, if any. When args ask for help it prints it to
+// stdout and returns nil and no error.
+func loadConfig(
+	name string,
+	getenv func(key string, defaultValue string) string,
+	args []string,
+	flags *flag.FlagSet,
+	stdout io.Writer,
+) (*config.Config, error) {
+	cfg, err := config.LoadWithFlags(getenv, args, flags)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(stdout, "Usage: server %s [flags]\n\n%s.\n\n", name, commands()[name].summary)
+		if flags != nil {
+			flags.SetOutput(stdout)
+			flags.PrintDefaults()
+			fmt.Fprintln(stdout)
 		}
-		repo = postgres.New(db)
-	} else {
-		repo = memory.New()
+		config.Usage(stdout)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
 	}
-	service := todo.NewService(repo)
+	return &cfg, nil
+}
 
-	srv := server.NewServer(
-		service,
-		config,
-		logger,
-	)
-	httpServer := &http.Server{
-		Addr:    net.JoinHostPort(config.Host, config.Port),
-		Handler: srv,
+// newLogger logs to out in the configured format and at the configured
+// levels, which can be changed through the returned Levels, unless
+// DISABLE_LOGGING is true.
+func newLogger(config *config.Config, getenv func(key string, defaultValue string) string, out io.Writer) (*slog.Logger, *logger.Levels) {
+	if getenv("DISABLE_LOGGING", "") == "true" {
+		out = io.Discard
 	}
-	go func() {
-		logger.Info("listening", "address", httpServer.Addr)
-		var err error
-		if config.CertFile != "" && config.KeyFile != "" {
-			err = httpServer.ListenAndServeTLS(config.CertFile, config.KeyFile)
-		} else {
-			err = httpServer.ListenAndServe()
-		}
-		if err != nil && err != http.ErrServerClosed {
-			fmt.Fprintf(os.Stderr, "error listening and serving: %s\n", err)
-		}
-	}()
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		<-ctx.Done()
-		shutdownCtx := context.Background()
-		shutdownCtx, cancel := context.WithTimeout(shutdownCtx, 10*time.Second)
-		defer cancel()
-		if err := httpServer.Shutdown(shutdownCtx); err != nil {
-			fmt.Fprintf(os.Stderr, "error shutting down http server: %s\n", err)
-		}
-	}()
-	wg.Wait()
-	return nil
+	levels := logger.NewLevels(slog.LevelInfo)
+	configureLevels(levels, config)
+	log := logger.NewWithOptions(out, getenv("SERVICE_NAME", "todo-service"), logger.Options{
+		Format: logger.Format(config.LogFormat),
+		Levels: levels,
+		Sample: logger.SampleOptions{
+			First:      config.LogSampleFirst,
+			Thereafter: config.LogSampleThereafter,
+			Interval:   config.LogSampleInterval,
+		},
+	})
+	return log, levels
+}
+
+// configureLevels sets levels to LOG_LEVEL and LOG_COMPONENT_LEVELS, which
+// config.Validate has checked.
+func configureLevels(levels *logger.Levels, config *config.Config) {
+	level, _ := logger.ParseLevel(config.LogLevel)
+	overrides, _ := logger.ParseLevels(config.LogComponentLevels)
+	levels.Configure(level, overrides)
+}
+
+// instanceName is a name for this process, unique among the instances of
+// the service.
+func instanceName(getenv func(key string, defaultValue string) string) string {
+	return getenv("SERVICE_NAME", "todo-service") + "/" + ksuid.New().String()
 }
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
+	"net"
+	"net/http"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -23,8 +28,11 @@ func TestRun_Logging(t *testing.T) {
 		stdin := strings.NewReader("")
 		args := []string{"cmd"}
 
-		// Mock getenv to return defaults
+		// Mock getenv to return defaults, on a free port
 		getenv := func(key, defaultValue string) string {
+			if key == "PROJECT_PORT" {
+				return "0"
+			}
 			return defaultValue
 		}
 
@@ -52,8 +60,11 @@ func TestRun_Logging(t *testing.T) {
 
 		// Mock getenv to simulate DISABLE_LOGGING=true
 		getenv := func(key, defaultValue string) string {
-			if key == "DISABLE_LOGGING" {
+			switch key {
+			case "DISABLE_LOGGING":
 				return "true"
+			case "PROJECT_PORT":
+				return "0"
 			}
 			return defaultValue
 		}
@@ -68,3 +79,278 @@ func TestRun_Logging(t *testing.T) {
 		}
 	})
 }
+
+// startServer runs the server with the given environment on a free port
+// and returns its base URL. The server stops when the test ends.
+func startServer(t *testing.T, env map[string]string) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	getenv := func(key, defaultValue string) string {
+		if v, ok := env[key]; ok {
+			return v
+		}
+		if key == "PROJECT_PORT" {
+			return "0"
+		}
+		return defaultValue
+	}
+
+	logs, stdout := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, []string{"cmd"}, getenv, strings.NewReader(""), stdout, io.Discard)
+		stdout.Close()
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("run failed: %v", err)
+		}
+	})
+
+	addr := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(logs)
+		for scanner.Scan() {
+			var line struct {
+				Msg     string `json:"msg"`
+				Address string `json:"address"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &line) == nil && line.Msg == "listening" {
+				addr <- line.Address
+			}
+		}
+		// Keep draining so that logging never blocks the server.
+		_, _ = io.Copy(io.Discard, logs)
+	}()
+	select {
+	case a := <-addr:
+		return "http://" + a
+	case err := <-done:
+		t.Fatalf("run failed before listening: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not start listening")
+	}
+	return ""
+}
+
+func TestRun_EphemeralPorts(t *testing.T) {
+	// Two servers can run side by side, each on its own port and store.
+	a := startServer(t, nil)
+	b := startServer(t, nil)
+	if a == b {
+		t.Fatalf("both servers listen on %s", a)
+	}
+
+	resp, err := http.Post(a+"/api/v1/todos", "application/json", strings.NewReader(`{"title": "Only in A"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d", resp.StatusCode)
+	}
+
+	count := func(base string) int {
+		t.Helper()
+		resp, err := http.Get(base + "/api/v1/todos")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var todos []map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&todos); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return len(todos)
+	}
+	if n := count(a); n != 1 {
+		t.Errorf("server A has %d todos, want 1", n)
+	}
+	if n := count(b); n != 0 {
+		t.Errorf("server B has %d todos, want 0", n)
+	}
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	getenv := func(key, defaultValue string) string {
+		if key == "PROJECT_PORT" {
+			return "eighty"
+		}
+		return defaultValue
+	}
+	err := run(context.Background(), []string{"cmd"}, getenv, strings.NewReader(""), io.Discard, io.Discard)
+	if err == nil {
+		t.Fatal("run() expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "PROJECT_PORT") {
+		t.Errorf("error %q does not mention PROJECT_PORT", err)
+	}
+}
+
+func TestRun_ConfigPrint(t *testing.T) {
+	getenv := func(key, defaultValue string) string {
+		if key == "DATABASE_URL" {
+			return "postgres://todo:s3cret@db/todo"
+		}
+		return defaultValue
+	}
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"cmd", "config", "print", "--cache-size", "5"}, getenv, strings.NewReader(""), &stdout, io.Discard)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	out := stdout.String()
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("output leaks the password:\n%s", out)
+	}
+	for _, want := range []string{
+		"postgres://todo:xxxxx@db/todo (redacted)  environment",
+		"CACHE_SIZE",
+		"flag",
+		"default",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+// runCommand runs the server binary with args and the environment env,
+// returning what it wrote to stdout.
+func runCommand(t *testing.T, env map[string]string, stdin string, args ...string) (string, error) {
+	t.Helper()
+	getenv := func(key, defaultValue string) string {
+		if v, ok := env[key]; ok {
+			return v
+		}
+		return defaultValue
+	}
+	var stdout bytes.Buffer
+	err := run(context.Background(), append([]string{"cmd"}, args...), getenv, strings.NewReader(stdin), &stdout, io.Discard)
+	return stdout.String(), err
+}
+
+func TestRun_Commands(t *testing.T) {
+	t.Run("Help", func(t *testing.T) {
+		out, err := runCommand(t, nil, "", "help")
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		for _, name := range []string{"serve", "migrate", "seed", "export", "import", "healthcheck", "version"} {
+			if !strings.Contains(out, "  "+name+" ") {
+				t.Errorf("help does not list %s:\n%s", name, out)
+			}
+		}
+		if _, err := runCommand(t, nil, "", "frobnicate"); err == nil || !strings.Contains(err.Error(), "frobnicate") {
+			t.Errorf("run() error = %v, want an unknown command error", err)
+		}
+	})
+
+	t.Run("Version", func(t *testing.T) {
+		out, err := runCommand(t, nil, "", "version")
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if !strings.HasPrefix(out, "server dev\n") {
+			t.Errorf("got %q, want the version first", out)
+		}
+	})
+
+	t.Run("Command Flags", func(t *testing.T) {
+		out, err := runCommand(t, nil, "", "seed", "-h")
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if !strings.Contains(out, "-count") || !strings.Contains(out, "--project-port") {
+			t.Errorf("help does not describe the command's flags and settings:\n%s", out)
+		}
+		if _, err := runCommand(t, nil, "", "export", "--count", "3"); err == nil {
+			t.Error("run() expected error for another command's flag, got nil")
+		}
+	})
+
+	t.Run("Import Flags", func(t *testing.T) {
+		if _, err := runCommand(t, nil, "[]", "import", "--on-conflict", "replace"); err == nil || !strings.Contains(err.Error(), "conflict policy") {
+			t.Errorf("run() error = %v, want one about the conflict policy", err)
+		}
+	})
+
+	t.Run("Commands Need a Database", func(t *testing.T) {
+		for _, command := range []string{"migrate", "export", "import"} {
+			if _, err := runCommand(t, nil, "[]", command); err == nil || !strings.Contains(err.Error(), "DATABASE_URL") {
+				t.Errorf("%s: run() error = %v, want one about DATABASE_URL", command, err)
+			}
+		}
+	})
+}
+
+func TestRun_Healthcheck(t *testing.T) {
+	base := startServer(t, nil)
+	_, port, _ := strings.Cut(strings.TrimPrefix(base, "http://"), ":")
+	env := map[string]string{"PROJECT_PORT": port}
+
+	out, err := runCommand(t, env, "", "healthcheck")
+	if err != nil {
+		t.Fatalf("healthcheck failed: %v", err)
+	}
+	if !strings.Contains(out, `"status":"ok"`) {
+		t.Errorf("got %q, want the health check's answer", out)
+	}
+	if _, err := runCommand(t, env, "", "healthcheck", "--ready"); err != nil {
+		t.Errorf("readiness check failed: %v", err)
+	}
+
+	// Nothing listens on the port once the listener is closed.
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ln.Close()
+	_, port, _ = net.SplitHostPort(ln.Addr().String())
+	if _, err := runCommand(t, map[string]string{"PROJECT_PORT": port}, "", "healthcheck", "--timeout", "1s"); err == nil {
+		t.Error("healthcheck of a stopped server succeeded")
+	}
+}
+
+func TestRun_DatabaseCommands(t *testing.T) {
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("Skipping database command tests: TEST_DATABASE_URL not set")
+	}
+	env := map[string]string{"DATABASE_URL": dbURL}
+
+	// Migrating is repeatable.
+	for range 2 {
+		if _, err := runCommand(t, env, "", "migrate"); err != nil {
+			t.Fatalf("migrate failed: %v", err)
+		}
+	}
+	if _, err := runCommand(t, env, "", "seed", "--count", "2"); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+	out, err := runCommand(t, env, "", "export")
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	var todos []map[string]any
+	if err := json.Unmarshal([]byte(out), &todos); err != nil {
+		t.Fatalf("export wrote invalid JSON: %v\n%s", err, out)
+	}
+	if len(todos) < 2 {
+		t.Errorf("exported %d todos, want at least the 2 seeded", len(todos))
+	}
+
+	in := "title,completed\nOne,false\nTwo,true\n"
+	out, err = runCommand(t, env, in, "import", "--format", "csv", "--ids", "new", "--dry-run")
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !strings.Contains(out, `"created": 2`) {
+		t.Errorf("got report %s, want 2 created", out)
+	}
+	out, err = runCommand(t, env, `[{"title": "One"}, {"title": ""}]`, "import", "--ids", "new", "--dry-run")
+	if err == nil || !strings.Contains(out, `"record": 2`) {
+		t.Errorf("import = %s, %v, want an error and a report naming the invalid todo", out, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	idempotencypostgres "github.com/jllovet/go-server-template/internal/idempotency/postgres"
+	"github.com/jllovet/go-server-template/internal/todo/postgres"
+	webhookpostgres "github.com/jllovet/go-server-template/internal/webhook/postgres"
+)
+
+// migrationLock is the Postgres advisory lock held while migrating, so
+// that instances starting together take turns.
+const migrationLock = 7_146_572_281
+
+// schemas are applied by migrate, in order.
+var schemas = []struct {
+	name string
+	sql  string
+}{
+	{"todo", postgres.Schema},
+	{"webhook", webhookpostgres.Schema},
+	{"idempotency", idempotencypostgres.Schema},
+}
+
+// migrate applies the database schemas in a single transaction. They can
+// be applied any number of times.
+func migrate(
+	ctx context.Context,
+	args []string,
+	getenv func(key string, defaultValue string) string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	config, err := loadConfig("migrate", getenv, args, nil, stdout)
+	if err != nil || config == nil {
+		return err
+	}
+	if config.DatabaseURL == "" {
+		return errors.New("migrate: DATABASE_URL is not set")
+	}
+	log, _ := newLogger(config, getenv, stderr)
+
+	db, err := openSQLDB(config, config.DatabaseURL, instanceName(getenv))
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	for _, s := range schemas {
+		if _, err := tx.ExecContext(ctx, s.sql); err != nil {
+			return fmt.Errorf("migrate: %s schema: %w", s.name, err)
+		}
+		log.Info("schema applied", "schema", s.name)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build unix
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRun_ReloadOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+	}
+	write("rate_limit_read: 1/m\n")
+	base := startServer(t, map[string]string{"CONFIG_FILE": path})
+
+	get := func() int {
+		t.Helper()
+		resp, err := http.Get(base + "/api/v1/todos")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	get()
+	if status := get(); status != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 Too Many Requests, got %d", status)
+	}
+
+	// An invalid file is ignored.
+	write("rate_limit_read: often\n")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to signal: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if status := get(); status != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 Too Many Requests after an invalid reload, got %d", status)
+	}
+
+	write("rate_limit_read: \"\"\n")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to signal: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for get() != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("rate limit was not lifted by the reload")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
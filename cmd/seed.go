@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// examples are the todos added by seed, over and over as needed.
+var examples = []struct {
+	title     string
+	completed bool
+}{
+	{"Buy groceries", false},
+	{"Write the quarterly report", false},
+	{"Book dentist appointment", true},
+	{"Renew passport", false},
+	{"Water the plants", true},
+	{"Call the plumber", false},
+	{"Plan the team offsite", false},
+	{"Back up the laptop", true},
+}
+
+// seed adds example todos, as many as the --count flag asks.
+func seed(
+	ctx context.Context,
+	args []string,
+	getenv func(key string, defaultValue string) string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	count := flags.Int("count", len(examples), "number of todos to add")
+	config, err := loadConfig("seed", getenv, args, flags, stdout)
+	if err != nil || config == nil {
+		return err
+	}
+	log, _ := newLogger(config, getenv, stderr)
+	service, closeStores, err := openService(ctx, config, getenv, log)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	ctx = todo.WithActor(ctx, commandActor)
+	for i := range *count {
+		example := examples[i%len(examples)]
+		title := example.title
+		if round := i / len(examples); round > 0 {
+			title = fmt.Sprintf("%s (%d)", title, round+1)
+		}
+		t, err := service.Create(ctx, title)
+		if err != nil {
+			return fmt.Errorf("seed: %w", err)
+		}
+		if example.completed {
+			if _, err := service.SetCompleted(ctx, t.ID, true); err != nil {
+				return fmt.Errorf("seed: %w", err)
+			}
+		}
+	}
+	log.Info("seeded todos", "count", *count)
+	return nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/idempotency"
+	"github.com/jllovet/go-server-template/internal/ratelimit"
+	ratelimitmemory "github.com/jllovet/go-server-template/internal/ratelimit/memory"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/cache"
+	"github.com/jllovet/go-server-template/internal/todo/feed"
+	"github.com/jllovet/go-server-template/internal/todo/pgnotify"
+	"github.com/jllovet/go-server-template/internal/todo/pgxstore"
+	"github.com/jllovet/go-server-template/internal/todo/resilience"
+	"github.com/jllovet/go-server-template/internal/webhook"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// serve runs the HTTP server until ctx is done.
+func serve(
+	ctx context.Context,
+	args []string,
+	getenv func(key string, defaultValue string) string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	config, err := loadConfig("serve", getenv, args, nil, stdout)
+	if err != nil || config == nil {
+		return err
+	}
+
+	log, levels := newLogger(config, getenv, stdout)
+	log.Debug("configuration loaded", "config", config)
+
+	// Database sessions are named after this instance so that it can tell
+	// its own change notifications from those of other instances.
+	instance := instanceName(getenv)
+	st, err := openStores(ctx, config, instance)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	repo, pool, pgRepo := st.repo, st.pool, st.pgRepo
+
+	// Retries and the circuit breaker sit below the cache, which keeps
+	// serving hits while the database is unavailable.
+	var resilient *resilience.Repository
+	if config.DatabaseURL != "" {
+		resilient = resilience.New(repo, resilience.Options{
+			MaxAttempts:      config.DBRetryMaxAttempts,
+			Backoff:          config.DBRetryBackoff,
+			MaxBackoff:       config.DBRetryMaxBackoff,
+			FailureThreshold: config.DBBreakerThreshold,
+			Cooldown:         config.DBBreakerCooldown,
+			Logger:           log.With(logger.ComponentKey, "database"),
+		})
+		repo = resilient
+	}
+
+	// Searches go straight to the store; only lookups by ID are cached.
+	var todoCache *cache.Repository
+	if config.CacheSize > 0 {
+		todoCache = cache.New(repo, cache.Options{
+			Size:        config.CacheSize,
+			TTL:         config.CacheTTL,
+			NegativeTTL: config.CacheNegativeTTL,
+		})
+		repo = todoCache
+	}
+
+	dispatcher := webhook.NewDispatcher(st.webhooks, webhook.Options{
+		Timeout:     config.WebhookTimeout,
+		MaxAttempts: config.WebhookMaxAttempts,
+		Backoff:     config.WebhookBackoff,
+		MaxBackoff:  config.WebhookMaxBackoff,
+		Concurrency: config.WebhookConcurrency,
+	})
+	broker := feed.New(config.SSEReplayBuffer)
+	service := todo.NewService(
+		repo,
+		todo.WithAudit(st.audit),
+		todo.WithSearcher(st.searcher),
+		todo.WithPublisher(dispatcher),
+		todo.WithPublisher(broker),
+		todo.WithMaxBatchSize(config.BatchMaxSize),
+	)
+
+	policy, err := rateLimitPolicy(config)
+	if err != nil {
+		return err
+	}
+	serverOpts := []server.Option{
+		server.WithWebhooks(webhook.NewService(st.webhooks, dispatcher)),
+		server.WithFeed(broker),
+		server.WithIdempotency(st.idempotency),
+		server.WithRateLimit(ratelimitmemory.New(), policy),
+		server.WithCORS(server.ParseOrigins(config.CORSAllowedOrigins)),
+	}
+	if resilient != nil {
+		serverOpts = append(serverOpts, server.WithReadinessCheck("database", resilient.Ready))
+	}
+	if config.AdminToken != "" {
+		serverOpts = append(serverOpts, server.WithAdmin(config.AdminToken, levels))
+	}
+	srv := server.NewServer(service, config, log.With(logger.ComponentKey, "http"), serverOpts...)
+	httpServer := &http.Server{
+		Addr:    net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		Handler: srv,
+	}
+	// Shutdown waits for active requests, which event streams never finish
+	// on their own, and ignores hijacked WebSocket connections entirely, so
+	// end both as soon as shutdown begins.
+	httpServer.RegisterOnShutdown(broker.Close)
+	// SIGHUP reloads the configuration; catch it before anyone can know
+	// where to send requests.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	// Listening before serving reports a busy port as an error and, with
+	// port 0, logs the port actually chosen.
+	ln, err := net.Listen("tcp", httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	go func() {
+		log.Info("listening", "address", ln.Addr().String())
+		var err error
+		if config.CertFile != "" && config.KeyFile != "" {
+			err = httpServer.ServeTLS(ln, config.CertFile, config.KeyFile)
+		} else {
+			err = httpServer.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(stderr, "error listening and serving: %s\n", err)
+		}
+	}()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dispatcher.Run(logger.WithContext(ctx, log.With(logger.ComponentKey, "webhook")))
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		purger := todo.NewPurger(service, config.TrashRetention, config.TrashPurgeInterval)
+		purger.Run(logger.WithContext(ctx, log.With(logger.ComponentKey, "trash")))
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		idempotency.Sweep(logger.WithContext(ctx, log.With(logger.ComponentKey, "idempotency")), st.idempotency, time.Hour)
+	}()
+	if config.DatabaseURL != "" && config.ChangeNotifications {
+		listener := pgnotify.NewListener(config.DatabaseURL, pgnotify.Options{Origin: instance})
+		if todoCache != nil {
+			// Subscribed first so that Republish reads the changed todo
+			// rather than a stale copy.
+			listener.Subscribe(func(ctx context.Context, c pgnotify.Change) {
+				if c.Reset {
+					// Changes may have been missed while disconnected.
+					todoCache.Purge()
+					return
+				}
+				todoCache.Invalidate(c.TodoID)
+			})
+		}
+		listener.Subscribe(pgnotify.Republish(repo, broker))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listener.Run(logger.WithContext(ctx, log.With(logger.ComponentKey, "notifications")))
+		}()
+	}
+	if todoCache != nil && config.CacheStatsInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			todoCache.LogStats(logger.WithContext(ctx, log.With(logger.ComponentKey, "cache")), config.CacheStatsInterval)
+		}()
+	}
+	if pgRepo != nil && config.DatabaseReplicaURL != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pgRepo.MonitorReplica(logger.WithContext(ctx, log.With(logger.ComponentKey, "database")), config.ReplicaHealthCheckInterval)
+		}()
+	}
+	if pool != nil && config.DBStatsInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pgxstore.LogStats(logger.WithContext(ctx, log.With(logger.ComponentKey, "database")), pool, config.DBStatsInterval)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		running := *config
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				running = reload(log, running, getenv, args, levels, srv)
+			}
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		shutdownCtx := context.Background()
+		shutdownCtx, cancel := context.WithTimeout(shutdownCtx, 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			fmt.Fprintf(stderr, "error shutting down http server: %s\n", err)
+		}
+	}()
+	wg.Wait()
+	return nil
+}
+
+// reload loads the configuration again and applies its reloadable
+// settings to the running server, returning the configuration now in
+// effect. An invalid configuration changes nothing.
+func reload(
+	log *slog.Logger,
+	running config.Config,
+	getenv func(key string, defaultValue string) string,
+	args []string,
+	levels *logger.Levels,
+	srv *server.Server,
+) config.Config {
+	loaded, err := config.Load(getenv, args)
+	if err != nil {
+		log.Error("reload failed, keeping the current configuration", "error", err)
+		return running
+	}
+	policy, err := rateLimitPolicy(&loaded)
+	if err != nil {
+		log.Error("reload failed, keeping the current configuration", "error", err)
+		return running
+	}
+
+	changes := config.Diff(running, loaded)
+	for _, c := range changes {
+		if c.Reloadable {
+			log.Info("setting changed", "setting", c.Setting, "old", c.Old, "new", c.New)
+		} else {
+			log.Warn("setting change requires a restart", "setting", c.Setting, "old", c.Old, "new", c.New)
+		}
+	}
+	// Levels changed through the admin endpoint go back to the configured
+	// ones.
+	configureLevels(levels, &loaded)
+	srv.SetRateLimitPolicy(policy)
+	srv.SetCORSOrigins(server.ParseOrigins(loaded.CORSAllowedOrigins))
+	log.Info("configuration reloaded", "changes", len(changes))
+	return config.Reloaded(running, loaded)
+}
+
+// rateLimitPolicy builds the rate limiting policy described by config.
+func rateLimitPolicy(config *config.Config) (ratelimit.Policy, error) {
+	policy := ratelimit.Policy{Limits: map[string]ratelimit.Limit{}}
+	for group, s := range map[string]string{
+		server.RouteGroupRead:   config.RateLimitRead,
+		server.RouteGroupWrite:  config.RateLimitWrite,
+		server.RouteGroupStream: config.RateLimitStream,
+	} {
+		limit, err := ratelimit.ParseLimit(s)
+		if err != nil {
+			return ratelimit.Policy{}, fmt.Errorf("RATE_LIMIT_%s: %w", strings.ToUpper(group), err)
+		}
+		policy.Limits[group] = limit
+	}
+	trusted, err := ratelimit.ParsePrefixes(config.TrustedProxies)
+	if err != nil {
+		return ratelimit.Policy{}, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	policy.TrustedProxies = trusted
+	return policy, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/idempotency"
+	idempotencymemory "github.com/jllovet/go-server-template/internal/idempotency/memory"
+	idempotencypostgres "github.com/jllovet/go-server-template/internal/idempotency/postgres"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/internal/todo/pgxstore"
+	"github.com/jllovet/go-server-template/internal/todo/postgres"
+	"github.com/jllovet/go-server-template/internal/webhook"
+	webhookmemory "github.com/jllovet/go-server-template/internal/webhook/memory"
+	webhookpostgres "github.com/jllovet/go-server-template/internal/webhook/postgres"
+)
+
+// stores are the storage adapters chosen by the configuration: Postgres
+// when DATABASE_URL is set, memory otherwise.
+type stores struct {
+	repo        todo.Repository
+	searcher    todo.Searcher
+	audit       todo.AuditRepository
+	webhooks    webhook.Store
+	idempotency idempotency.Store
+
+	// pool is set for the "pgx" driver, pgRepo for the "sql" driver.
+	pool   *pgxpool.Pool
+	pgRepo *postgres.Repository
+
+	closers []func()
+}
+
+// Close releases the database connections, if any.
+func (s *stores) Close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+}
+
+// openStores connects to the configured database, naming its sessions
+// after instance.
+func openStores(ctx context.Context, config *config.Config, instance string) (*stores, error) {
+	s := &stores{}
+	if config.DatabaseURL == "" {
+		memRepo := memory.New()
+		s.repo, s.searcher = memRepo, memRepo
+		s.audit = memory.NewAuditRepository()
+		s.webhooks = webhookmemory.New()
+		s.idempotency = idempotencymemory.New()
+		return s, nil
+	}
+
+	var db *sql.DB
+	switch config.DatabaseDriver {
+	case "pgx":
+		pool, err := pgxstore.NewPool(ctx, config.DatabaseURL, pgxstore.PoolOptions{
+			MaxConns:               int32(config.DBMaxConns),
+			MinConns:               int32(config.DBMinConns),
+			MaxConnLifetime:        config.DBMaxConnLifetime,
+			MaxConnIdleTime:        config.DBMaxConnIdleTime,
+			HealthCheckPeriod:      config.DBHealthCheckPeriod,
+			StatementCacheMode:     config.DBStatementCacheMode,
+			StatementCacheCapacity: config.DBStatementCacheCapacity,
+			ApplicationName:        instance,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("open db: %w", err)
+		}
+		s.pool = pool
+		s.closers = append(s.closers, pool.Close)
+		pgxRepo := pgxstore.New(pool)
+		s.repo, s.searcher = pgxRepo, pgxRepo
+		s.audit = pgxstore.NewAuditRepository(pool)
+		// The remaining stores only need database/sql; share the pool.
+		db = stdlib.OpenDBFromPool(pool)
+	case "sql":
+		var err error
+		db, err = openSQLDB(config, config.DatabaseURL, instance)
+		if err != nil {
+			return nil, fmt.Errorf("open db: %w", err)
+		}
+		if err := db.PingContext(ctx); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("ping db: %w", err)
+		}
+		var opts []postgres.Option
+		if config.DatabaseReplicaURL != "" {
+			// An unreachable replica is not fatal: reads fall back to
+			// the primary until it recovers.
+			replica, err := openSQLDB(config, config.DatabaseReplicaURL, instance)
+			if err != nil {
+				db.Close()
+				return nil, fmt.Errorf("open replica db: %w", err)
+			}
+			s.closers = append(s.closers, func() { replica.Close() })
+			opts = append(opts, postgres.WithReplica(replica, config.ReplicaReadYourWritesWindow))
+		}
+		s.pgRepo = postgres.New(db, opts...)
+		s.repo, s.searcher = s.pgRepo, s.pgRepo
+		s.audit = postgres.NewAuditRepository(db)
+	default:
+		return nil, fmt.Errorf("unknown DATABASE_DRIVER %q", config.DatabaseDriver)
+	}
+	s.closers = append(s.closers, func() { db.Close() })
+	s.webhooks = webhookpostgres.New(db)
+	s.idempotency = idempotencypostgres.New(db)
+	return s, nil
+}
+
+// openSQLDB opens a database/sql handle for url with the pool settings of
+// config, naming its sessions after instance.
+func openSQLDB(config *config.Config, url, instance string) (*sql.DB, error) {
+	connConfig, err := pgx.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	connConfig.RuntimeParams["application_name"] = instance
+	db := stdlib.OpenDB(*connConfig)
+	db.SetMaxOpenConns(config.DBMaxConns)
+	db.SetConnMaxLifetime(config.DBMaxConnLifetime)
+	db.SetConnMaxIdleTime(config.DBMaxConnIdleTime)
+	return db, nil
+}
+
+// commandActor is recorded in the audit log for changes made by commands
+// other than serve.
+const commandActor = "cli"
+
+// openService opens the stores for a command that reads or changes todos
+// outside the server, returning a service over them and a function that
+// closes them.
+func openService(ctx context.Context, config *config.Config, getenv func(key string, defaultValue string) string, log *slog.Logger) (todo.Service, func(), error) {
+	if config.DatabaseURL == "" {
+		log.Warn("DATABASE_URL is not set: todos are kept in memory and lost on exit")
+	}
+	st, err := openStores(ctx, config, instanceName(getenv))
+	if err != nil {
+		return nil, nil, err
+	}
+	service := todo.NewService(
+		st.repo,
+		todo.WithAudit(st.audit),
+		todo.WithSearcher(st.searcher),
+	)
+	return service, st.Close, nil
+}
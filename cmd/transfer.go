@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/transfer"
+)
+
+// export writes every todo to stdout in the format named by --format.
+func export(
+	ctx context.Context,
+	args []string,
+	getenv func(key string, defaultValue string) string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := flags.String("format", string(transfer.JSON), "output format: json, ndjson or csv")
+	config, err := loadConfig("export", getenv, args, flags, stdout)
+	if err != nil || config == nil {
+		return err
+	}
+	format, err := transfer.ParseFormat(*formatName)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	// Without a database there is nothing to export.
+	if config.DatabaseURL == "" {
+		return errors.New("export: DATABASE_URL is not set")
+	}
+	log, _ := newLogger(config, getenv, stderr)
+	service, closeStores, err := openService(ctx, config, getenv, log)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	n, err := transfer.Export(ctx, service, stdout, format)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	log.Info("exported todos", "count", n, "format", format)
+	return nil
+}
+
+// importTodos imports the todos read from stdin, as written by export, and
+// prints a report of what it did as JSON.
+func importTodos(
+	ctx context.Context,
+	args []string,
+	getenv func(key string, defaultValue string) string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := flags.String("format", string(transfer.JSON), "input format: json, ndjson or csv")
+	ids := flags.String("ids", "keep", "keep the todos' IDs, or give them new ones: keep or new")
+	onConflict := flags.String("on-conflict", string(todo.ConflictFail), "what to do with a todo whose ID is taken: fail, skip or overwrite")
+	dryRun := flags.Bool("dry-run", false, "check the todos without saving them")
+	config, err := loadConfig("import", getenv, args, flags, stdout)
+	if err != nil || config == nil {
+		return err
+	}
+	format, err := transfer.ParseFormat(*formatName)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	opts := todo.ImportOptions{DryRun: *dryRun}
+	switch *ids {
+	case "keep":
+		opts.KeepIDs = true
+	case "new":
+	default:
+		return fmt.Errorf("import: unknown --ids %q, want keep or new", *ids)
+	}
+	if opts.OnConflict, err = todo.ParseConflictPolicy(*onConflict); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	// Importing into memory would report success and keep nothing.
+	if config.DatabaseURL == "" {
+		return errors.New("import: DATABASE_URL is not set")
+	}
+	log, _ := newLogger(config, getenv, stderr)
+	service, closeStores, err := openService(ctx, config, getenv, log)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	ctx = todo.WithActor(ctx, commandActor)
+	report, err := transfer.Import(ctx, service, transfer.NewDecoder(stdin, format), opts, func(r transfer.Report) {
+		log.Info("importing todos", "read", r.Read, "failed", r.Failed)
+	})
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(report); encErr != nil {
+		return errors.Join(err, encErr)
+	}
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	log.Info("imported todos", "read", report.Read, "created", report.Created,
+		"overwritten", report.Overwritten, "skipped", report.Skipped, "failed", report.Failed, "dry_run", report.DryRun)
+	if report.Failed > 0 {
+		return fmt.Errorf("import: %d of %d todos failed", report.Failed, report.Read)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// version is the release of the binary, set when building with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// printVersion prints the release, and the commit and Go version the
+// binary was built from when known.
+func printVersion(
+	ctx context.Context,
+	args []string,
+	getenv func(key string, defaultValue string) string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	if len(args) > 0 {
+		return fmt.Errorf("version: unexpected argument %q", args[0])
+	}
+	fmt.Fprintf(stdout, "server %s\n", version)
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	fmt.Fprintf(stdout, "go: %s\n", info.GoVersion)
+	settings := map[string]string{}
+	for _, s := range info.Settings {
+		settings[s.Key] = s.Value
+	}
+	if rev := settings["vcs.revision"]; rev != "" {
+		if settings["vcs.modified"] == "true" {
+			rev += " (modified)"
+		}
+		fmt.Fprintf(stdout, "commit: %s\n", rev)
+	}
+	if at := settings["vcs.time"]; at != "" {
+		fmt.Fprintf(stdout, "commit time: %s\n", at)
+	}
+	return nil
+}
@@ -1,32 +1,168 @@
+// Package config loads the server's configuration.
 package config
 
 import (
 	"os"
-
-	"github.com/joho/godotenv"
+	"time"
 )
 
-var InitializedConfig = InitConfig()
-
+// Config is the server's configuration. Each field is read from the
+// environment variable named by its env tag; see Load for the other
+// sources. Fields tagged reload can be changed without a restart; those
+// tagged secret are redacted by String and LogValue.
 type Config struct {
-	Host        string
-	Port        string
-	DatabaseURL string
-	CertFile    string
-	KeyFile     string
+	Host        string `env:"PROJECT_HOST"`
+	Port        int    `env:"PROJECT_PORT"`
+	DatabaseURL string `env:"DATABASE_URL" secret:"true"`
+	CertFile    string `env:"CERT_FILE"`
+	KeyFile     string `env:"KEY_FILE"`
+
+	// LogLevel is the least severe level logged: debug, info, warn or error.
+	// LogComponentLevels overrides it for some components, as a
+	// comma-separated list such as "webhook=debug,http=warn". LogFormat is
+	// json, text or pretty.
+	LogLevel           string `env:"LOG_LEVEL" reload:"true"`
+	LogComponentLevels string `env:"LOG_COMPONENT_LEVELS" reload:"true"`
+	LogFormat          string `env:"LOG_FORMAT"`
+
+	// With LogSampleFirst set, each message below warn is logged at most
+	// LogSampleFirst times every LogSampleInterval, and then only once in
+	// every LogSampleThereafter times (never, with 0). Requests slower than
+	// LogSlowRequest, unless 0, are logged at warn so that they escape it.
+	LogSampleFirst      int           `env:"LOG_SAMPLE_FIRST"`
+	LogSampleThereafter int           `env:"LOG_SAMPLE_THEREAFTER"`
+	LogSampleInterval   time.Duration `env:"LOG_SAMPLE_INTERVAL"`
+	LogSlowRequest      time.Duration `env:"LOG_SLOW_REQUEST"`
+
+	// AdminToken is the bearer token of the /admin endpoints, which are
+	// disabled while it is empty.
+	AdminToken string `env:"ADMIN_TOKEN" secret:"true"`
+
+	// CORSAllowedOrigins lists, comma-separated, the origins whose browser
+	// scripts may call the API, or "*" for any. Empty disables CORS.
+	CORSAllowedOrigins string `env:"CORS_ALLOWED_ORIGINS" reload:"true"`
+
+	// DatabaseReplicaURL is an optional read replica for todo lookups and
+	// listings. Reads made within ReplicaReadYourWritesWindow of a write in
+	// the same request go to the primary, as do all reads while the replica
+	// fails the health check run every ReplicaHealthCheckInterval.
+	DatabaseReplicaURL          string        `env:"DATABASE_REPLICA_URL" secret:"true"`
+	ReplicaReadYourWritesWindow time.Duration `env:"DB_REPLICA_READ_YOUR_WRITES_WINDOW"`
+	ReplicaHealthCheckInterval  time.Duration `env:"DB_REPLICA_HEALTH_CHECK_INTERVAL"`
+
+	// Retries of transient database failures, and the circuit breaker that
+	// opens after DBBreakerThreshold failed operations in a row and lets a
+	// trial through after DBBreakerCooldown.
+	DBRetryMaxAttempts int           `env:"DB_RETRY_MAX_ATTEMPTS"`
+	DBRetryBackoff     time.Duration `env:"DB_RETRY_BACKOFF"`
+	DBRetryMaxBackoff  time.Duration `env:"DB_RETRY_MAX_BACKOFF"`
+	DBBreakerThreshold int           `env:"DB_BREAKER_THRESHOLD"`
+	DBBreakerCooldown  time.Duration `env:"DB_BREAKER_COOLDOWN"`
+
+	// DatabaseDriver selects the Postgres adapter: "sql" for database/sql
+	// with the pgx shim, or "pgx" for a native pgx pool.
+	DatabaseDriver string `env:"DATABASE_DRIVER"`
+
+	// Connection pool tuning; zero keeps the driver defaults. The statement
+	// cache settings only apply to the "pgx" driver.
+	DBMaxConns               int           `env:"DB_MAX_CONNS"`
+	DBMinConns               int           `env:"DB_MIN_CONNS"`
+	DBMaxConnLifetime        time.Duration `env:"DB_MAX_CONN_LIFETIME"`
+	DBMaxConnIdleTime        time.Duration `env:"DB_MAX_CONN_IDLE_TIME"`
+	DBHealthCheckPeriod      time.Duration `env:"DB_HEALTH_CHECK_PERIOD"`
+	DBStatementCacheMode     string        `env:"DB_STATEMENT_CACHE_MODE"`
+	DBStatementCacheCapacity int           `env:"DB_STATEMENT_CACHE_CAPACITY"`
+	// How often pool statistics are logged; zero disables them.
+	DBStatsInterval time.Duration `env:"DB_STATS_INTERVAL"`
+
+	// Whether to listen for changes made by other instances through the
+	// database's todo_changes notifications.
+	ChangeNotifications bool `env:"CHANGE_NOTIFICATIONS"`
+
+	// Read-through cache in front of the todo repository. A size of zero
+	// disables it; a negative TTL of zero disables caching of misses.
+	CacheSize          int           `env:"CACHE_SIZE"`
+	CacheTTL           time.Duration `env:"CACHE_TTL"`
+	CacheNegativeTTL   time.Duration `env:"CACHE_NEGATIVE_TTL"`
+	CacheStatsInterval time.Duration `env:"CACHE_STATS_INTERVAL"`
+
+	// How long deleted todos stay in the trash, and how often it is purged.
+	TrashRetention     time.Duration `env:"TRASH_RETENTION"`
+	TrashPurgeInterval time.Duration `env:"TRASH_PURGE_INTERVAL"`
+
+	// Webhook delivery tuning.
+	WebhookTimeout     time.Duration `env:"WEBHOOK_TIMEOUT"`
+	WebhookMaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookBackoff     time.Duration `env:"WEBHOOK_BACKOFF"`
+	WebhookMaxBackoff  time.Duration `env:"WEBHOOK_MAX_BACKOFF"`
+	WebhookConcurrency int           `env:"WEBHOOK_CONCURRENCY"`
+
+	// Server-Sent Events stream tuning.
+	SSEReplayBuffer int           `env:"SSE_REPLAY_BUFFER"`
+	SSEHeartbeat    time.Duration `env:"SSE_HEARTBEAT"`
+
+	// WebSocket keepalive interval.
+	WSPingInterval time.Duration `env:"WS_PING_INTERVAL"`
+
+	// Most operations accepted in one POST /api/v1/todos:batch request.
+	BatchMaxSize int `env:"BATCH_MAX_SIZE"`
+
+	// How long responses to requests with an Idempotency-Key are kept.
+	IdempotencyTTL time.Duration `env:"IDEMPOTENCY_TTL"`
+
+	// Per-client rate limits of each route group, such as "600/m"; empty
+	// means unlimited. TrustedProxies lists the addresses, or CIDR prefixes,
+	// whose X-Forwarded-For headers are believed.
+	RateLimitRead   string `env:"RATE_LIMIT_READ" reload:"true"`
+	RateLimitWrite  string `env:"RATE_LIMIT_WRITE" reload:"true"`
+	RateLimitStream string `env:"RATE_LIMIT_STREAM" reload:"true"`
+	TrustedProxies  string `env:"TRUSTED_PROXIES" reload:"true"`
 }
 
-func InitConfig() Config {
-	godotenv.Load()
+// Default returns the configuration used for anything that is not set.
+func Default() Config {
 	return Config{
-		Host:        GetEnv("PROJECT_HOST", "localhost"),
-		Port:        GetEnv("PROJECT_PORT", "8080"),
-		DatabaseURL: GetEnv("DATABASE_URL", ""),
-		CertFile:    GetEnv("CERT_FILE", ""),
-		KeyFile:     GetEnv("KEY_FILE", ""),
+		Host:                        "localhost",
+		Port:                        8080,
+		LogLevel:                    "info",
+		LogFormat:                   "json",
+		LogSampleThereafter:         100,
+		LogSampleInterval:           time.Second,
+		LogSlowRequest:              time.Second,
+		ReplicaReadYourWritesWindow: 5 * time.Second,
+		ReplicaHealthCheckInterval:  5 * time.Second,
+		DBRetryMaxAttempts:          3,
+		DBRetryBackoff:              50 * time.Millisecond,
+		DBRetryMaxBackoff:           time.Second,
+		DBBreakerThreshold:          5,
+		DBBreakerCooldown:           30 * time.Second,
+		DatabaseDriver:              "sql",
+		DBStatsInterval:             time.Minute,
+		ChangeNotifications:         true,
+		CacheSize:                   1000,
+		CacheTTL:                    time.Minute,
+		CacheNegativeTTL:            10 * time.Second,
+		CacheStatsInterval:          time.Minute,
+		TrashRetention:              30 * 24 * time.Hour,
+		TrashPurgeInterval:          time.Hour,
+		WebhookTimeout:              10 * time.Second,
+		WebhookMaxAttempts:          8,
+		WebhookBackoff:              time.Second,
+		WebhookMaxBackoff:           time.Hour,
+		WebhookConcurrency:          8,
+		SSEReplayBuffer:             1000,
+		SSEHeartbeat:                15 * time.Second,
+		WSPingInterval:              30 * time.Second,
+		BatchMaxSize:                100,
+		IdempotencyTTL:              24 * time.Hour,
+		RateLimitRead:               "600/m",
+		RateLimitWrite:              "120/m",
+		RateLimitStream:             "30/m",
 	}
 }
 
+// GetEnv looks key up in the process environment, returning defaultValue
+// when it is unset. It is the getenv that main passes to Load.
 func GetEnv(key, defaultValue string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
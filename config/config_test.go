@@ -0,0 +1,279 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/config"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+// env returns a getenv that looks keys up in vars.
+func env(vars map[string]string) func(key, defaultValue string) string {
+	return func(key, defaultValue string) string {
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return defaultValue
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		c, err := config.Load(env(nil), nil)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if c != config.Default() {
+			t.Errorf("got %+v, want defaults", c)
+		}
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+project_host: file-host
+project_port: 9000
+cache_ttl: 2m
+change_notifications: false
+`)
+		getenv := env(map[string]string{"PROJECT_PORT": "9001", "CACHE_TTL": "3m"})
+		c, err := config.Load(getenv, []string{"--config", path, "--cache-ttl", "4m"})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if c.Host != "file-host" {
+			t.Errorf("got host %q, want the file's", c.Host)
+		}
+		if c.Port != 9001 {
+			t.Errorf("got port %d, want the environment's", c.Port)
+		}
+		if c.CacheTTL != 4*time.Minute {
+			t.Errorf("got cache TTL %v, want the flag's", c.CacheTTL)
+		}
+		if c.ChangeNotifications {
+			t.Error("got change notifications on, want the file's false")
+		}
+	})
+
+	t.Run("Empty Variables Override", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"rate_limit_read": "10/s"}`)
+		getenv := env(map[string]string{config.FileEnv: path, "RATE_LIMIT_READ": ""})
+		c, err := config.Load(getenv, nil)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if c.RateLimitRead != "" {
+			t.Errorf("got rate limit %q, want the environment's empty value", c.RateLimitRead)
+		}
+	})
+
+	t.Run("File Formats", func(t *testing.T) {
+		files := map[string]string{
+			"config.yml":  "project_port: 9000\ntrusted_proxies: [10.0.0.0/8, 127.0.0.1]\n",
+			"config.toml": "project_port = 9000\ntrusted_proxies = [\"10.0.0.0/8\", \"127.0.0.1\"]\n",
+			"config.json": `{"project_port": 9000, "trusted_proxies": ["10.0.0.0/8", "127.0.0.1"]}`,
+		}
+		for name, content := range files {
+			getenv := env(map[string]string{config.FileEnv: writeFile(t, name, content)})
+			c, err := config.Load(getenv, nil)
+			if err != nil {
+				t.Fatalf("%s: Load() error = %v", name, err)
+			}
+			if c.Port != 9000 || c.TrustedProxies != "10.0.0.0/8,127.0.0.1" {
+				t.Errorf("%s: got port %d, proxies %q", name, c.Port, c.TrustedProxies)
+			}
+		}
+		getenv := env(map[string]string{config.FileEnv: writeFile(t, "config.ini", "project_port=9000")})
+		if _, err := config.Load(getenv, nil); err == nil {
+			t.Error("Load() expected error for unsupported format, got nil")
+		}
+	})
+
+	t.Run("Reports Every Problem", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"project_prot": 9000, "cache_size": "lots"}`)
+		getenv := env(map[string]string{"WEBHOOK_TIMEOUT": "soon"})
+		_, err := config.Load(getenv, []string{"--config", path, "--change-notifications", "maybe"})
+		if err == nil {
+			t.Fatal("Load() expected error, got nil")
+		}
+		for _, want := range []string{`unknown setting "project_prot"`, "cache_size", "WEBHOOK_TIMEOUT", "--change-notifications"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention %s", err, want)
+			}
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := config.Load(env(nil), []string{
+			"--project-port", "70000",
+			"--database-driver", "mysql",
+			"--cert-file", "cert.pem",
+			"--rate-limit-read", "fast",
+			"--trash-purge-interval", "0s",
+			"--trash-retention", "0s",
+			"--log-level", "loud",
+			"--log-component-levels", "webhook=chatty",
+			"--log-format", "xml",
+			"--log-sample-first", "10",
+			"--log-sample-interval", "0s",
+		})
+		if err == nil {
+			t.Fatal("Load() expected error, got nil")
+		}
+		for _, want := range []string{"PROJECT_PORT", "DATABASE_DRIVER", "CERT_FILE", "RATE_LIMIT_READ", "TRASH_PURGE_INTERVAL", "TRASH_RETENTION", "LOG_LEVEL", "LOG_COMPONENT_LEVELS", "LOG_FORMAT", "LOG_SAMPLE_INTERVAL"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention %s", err, want)
+			}
+		}
+	})
+
+	t.Run("Secret Files", func(t *testing.T) {
+		path := writeFile(t, "database_url", "postgres://todo:s3cret@db/todo\n")
+		c, err := config.Load(env(map[string]string{"DATABASE_URL_FILE": path}), nil)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if c.DatabaseURL != "postgres://todo:s3cret@db/todo" {
+			t.Errorf("got database URL %q, want the file's without the newline", c.DatabaseURL)
+		}
+
+		getenv := env(map[string]string{"DATABASE_URL_FILE": path, "DATABASE_URL": "postgres://db/todo"})
+		if _, err := config.Load(getenv, nil); err == nil || !strings.Contains(err.Error(), "DATABASE_URL_FILE") {
+			t.Errorf("Load() error = %v, want one about setting both", err)
+		}
+		getenv = env(map[string]string{"DATABASE_URL_FILE": path + ".missing"})
+		if _, err := config.Load(getenv, nil); err == nil {
+			t.Error("Load() expected error for a missing secret file, got nil")
+		}
+		// Only secrets can come from files.
+		getenv = env(map[string]string{"PROJECT_HOST_FILE": path})
+		if c, err := config.Load(getenv, nil); err != nil || c.Host != "localhost" {
+			t.Errorf("got host %q, error %v, want the default", c.Host, err)
+		}
+	})
+
+	t.Run("Command Flags", func(t *testing.T) {
+		extra := flag.NewFlagSet("seed", flag.ContinueOnError)
+		count := extra.Int("count", 10, "")
+		c, err := config.LoadWithFlags(env(nil), []string{"--count", "3", "--project-port", "9000"}, extra)
+		if err != nil {
+			t.Fatalf("LoadWithFlags() error = %v", err)
+		}
+		if *count != 3 || c.Port != 9000 {
+			t.Errorf("got count %d, port %d, want 3 and 9000", *count, c.Port)
+		}
+		if _, err := config.Load(env(nil), []string{"--count", "3"}); err == nil {
+			t.Error("Load() expected error for another command's flag, got nil")
+		}
+	})
+
+	t.Run("Help", func(t *testing.T) {
+		if _, err := config.Load(env(nil), []string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("Load() error = %v, want %v", err, flag.ErrHelp)
+		}
+		var b strings.Builder
+		config.Usage(&b)
+		if !strings.Contains(b.String(), "--project-port (PROJECT_PORT)\n\tdefault 8080") {
+			t.Errorf("usage does not describe --project-port:\n%s", b.String())
+		}
+	})
+}
+
+func TestDiff(t *testing.T) {
+	old := config.Default()
+	if changes := config.Diff(old, old); len(changes) != 0 {
+		t.Errorf("Diff() of equal configs = %+v, want none", changes)
+	}
+
+	new := old
+	new.Port = 9000
+	new.LogLevel = "debug"
+	want := []config.Change{
+		{Setting: "PROJECT_PORT", Old: "8080", New: "9000"},
+		{Setting: "LOG_LEVEL", Old: "info", New: "debug", Reloadable: true},
+	}
+	got := config.Diff(old, new)
+	if len(got) != len(want) {
+		t.Fatalf("Diff() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	c := config.Default()
+	c.DatabaseURL = "postgres://todo:s3cret@db:5432/todo?sslmode=disable"
+	c.DatabaseReplicaURL = "host=replica password=s3cret"
+
+	var logs bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logs, nil)).Info("loaded", "config", c)
+	for name, out := range map[string]string{
+		"String": c.String(),
+		"%v":     fmt.Sprintf("%v", c),
+		"%+v":    fmt.Sprintf("%+v", c),
+		"slog":   logs.String(),
+	} {
+		if strings.Contains(out, "s3cret") {
+			t.Errorf("%s output leaks the password: %s", name, out)
+		}
+		if !strings.Contains(out, "postgres://todo:xxxxx@db:5432/todo?sslmode=disable") {
+			t.Errorf("%s output does not show the redacted URL: %s", name, out)
+		}
+		if !strings.Contains(out, config.Redacted) {
+			t.Errorf("%s output does not redact the connection string: %s", name, out)
+		}
+	}
+
+	new := c
+	new.DatabaseURL = "postgres://todo:0ther@db:5432/todo"
+	if changes := config.Diff(c, new); len(changes) != 1 || strings.Contains(changes[0].Old+changes[0].New, "s3cret") {
+		t.Errorf("Diff() = %+v, want one redacted change", changes)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	path := writeFile(t, "config.yaml", "project_host: file-host\n")
+	secret := writeFile(t, "database_url", "postgres://todo:s3cret@db/todo")
+	getenv := env(map[string]string{config.FileEnv: path, "DATABASE_URL_FILE": secret, "CACHE_TTL": "2m"})
+	settings, err := config.Explain(getenv, []string{"--project-port", "9000"})
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	want := map[string]config.Setting{
+		"PROJECT_HOST": {Name: "PROJECT_HOST", Value: "file-host", Source: "config file " + path},
+		"PROJECT_PORT": {Name: "PROJECT_PORT", Value: "9000", Source: "flag"},
+		"CACHE_TTL":    {Name: "CACHE_TTL", Value: "2m0s", Source: "environment"},
+		"CACHE_SIZE":   {Name: "CACHE_SIZE", Value: "1000", Source: "default"},
+		"DATABASE_URL": {Name: "DATABASE_URL", Value: "postgres://todo:xxxxx@db/todo", Secret: true, Source: "secret file " + secret},
+	}
+	for _, s := range settings {
+		if w, ok := want[s.Name]; ok {
+			if s != w {
+				t.Errorf("got %+v, want %+v", s, w)
+			}
+			delete(want, s.Name)
+		}
+	}
+	for name := range want {
+		t.Errorf("setting %s is missing", name)
+	}
+}
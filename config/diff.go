@@ -0,0 +1,40 @@
+package config
+
+import "reflect"
+
+// Change is a setting whose value differs between two configurations.
+type Change struct {
+	// Setting is the environment variable naming the setting.
+	Setting string
+	Old     string
+	New     string
+	// Reloadable reports whether the running server can apply the change;
+	// the others take effect on the next restart.
+	Reloadable bool
+}
+
+// Diff lists the settings that differ between old and new, in declaration
+// order. Secrets are redacted.
+func Diff(old, new Config) []Change {
+	var changes []Change
+	for _, f := range fields() {
+		before, after := f.get(old), f.get(new)
+		if before != after {
+			before, after = f.show(old), f.show(new)
+			changes = append(changes, Change{Setting: f.env, Old: before, New: after, Reloadable: f.reload})
+		}
+	}
+	return changes
+}
+
+// Reloaded returns running with the reloadable settings of loaded, leaving
+// the others as the server started with them.
+func Reloaded(running, loaded Config) Config {
+	src, dst := reflect.ValueOf(loaded), reflect.ValueOf(&running).Elem()
+	for _, f := range fields() {
+		if f.reload {
+			dst.Field(f.index).Set(src.Field(f.index))
+		}
+	}
+	return running
+}
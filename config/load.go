@@ -0,0 +1,331 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable holding the path of a config
+// file, for when the --config flag is not given.
+const FileEnv = "CONFIG_FILE"
+
+// unset is passed to getenv as the default to tell unset variables from
+// empty ones.
+const unset = "\x00unset"
+
+// field is a setting of Config: the field at index, read from the
+// environment variable env, the file key Key and the flag Flag. Reload
+// settings may change while the server runs; secret ones are redacted
+// wherever they are shown and can be read from the file named by
+// FileVariable.
+type field struct {
+	index  int
+	env    string
+	typ    reflect.Type
+	reload bool
+	secret bool
+}
+
+// Key is the name of the setting in a config file: the environment
+// variable in lower case, such as "project_port".
+func (f field) Key() string {
+	return strings.ToLower(f.env)
+}
+
+// FileVariable is the environment variable naming a file that holds the
+// value of a secret setting, such as "DATABASE_URL_FILE".
+func (f field) FileVariable() string {
+	return f.env + "_FILE"
+}
+
+// Flag is the name of the setting's command-line flag, such as
+// "project-port".
+func (f field) Flag() string {
+	return strings.ReplaceAll(f.Key(), "_", "-")
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// fields lists the settings of Config in declaration order.
+func fields() []field {
+	t := reflect.TypeFor[Config]()
+	var fs []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if env := sf.Tag.Get("env"); env != "" {
+			fs = append(fs, field{
+				index:  i,
+				env:    env,
+				typ:    sf.Type,
+				reload: sf.Tag.Get("reload") == "true",
+				secret: sf.Tag.Get("secret") == "true",
+			})
+		}
+	}
+	return fs
+}
+
+// set parses value into the setting f of c.
+func (f field) set(c *Config, value string) error {
+	v := reflect.ValueOf(c).Elem().Field(f.index)
+	switch {
+	case f.typ == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration, such as 30s or 5m", value)
+		}
+		v.SetInt(int64(d))
+	case f.typ.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		v.SetInt(int64(n))
+	case f.typ.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		v.SetBool(b)
+	default:
+		v.SetString(value)
+	}
+	return nil
+}
+
+// get formats the setting f of c as it would be written in the environment.
+func (f field) get(c Config) string {
+	v := reflect.ValueOf(c).Field(f.index)
+	switch {
+	case f.typ == durationType:
+		return time.Duration(v.Int()).String()
+	case f.typ.Kind() == reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case f.typ.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	}
+	return v.String()
+}
+
+// Load builds the configuration from, in increasing order of precedence:
+//
+//  1. the defaults (see Default);
+//  2. a YAML, TOML or JSON file named by the --config flag or the
+//     CONFIG_FILE environment variable, whose keys are the lower-case
+//     environment variable names, such as project_port;
+//  3. environment variables, looked up with getenv; secrets, such as
+//     DATABASE_URL, may instead be read from the file named by a variable
+//     with a _FILE suffix, such as DATABASE_URL_FILE;
+//  4. command-line flags in args (without the program name), named after
+//     the environment variables, such as --project-port.
+//
+// It reports every invalid value at once, along with any Validate error,
+// and returns flag.ErrHelp when args ask for help. Load has no other
+// inputs, besides the files it is pointed to, so callers such as tests
+// fully control the result.
+func Load(getenv func(key, defaultValue string) string, args []string) (Config, error) {
+	return LoadWithFlags(getenv, args, nil)
+}
+
+// LoadWithFlags is Load for commands with flags of their own: the flags
+// defined in extra are accepted in args too, and set as they are parsed.
+func LoadWithFlags(getenv func(key, defaultValue string) string, args []string, extra *flag.FlagSet) (Config, error) {
+	config, _, err := load(getenv, args, extra)
+	return config, err
+}
+
+// load is LoadWithFlags, also returning where each setting of fields()
+// came from.
+func load(getenv func(key, defaultValue string) string, args []string, extra *flag.FlagSet) (Config, []string, error) {
+	config := Default()
+	fs := fields()
+	sources := make([]string, len(fs))
+	for i := range sources {
+		sources[i] = "default"
+	}
+	lookup := func(key string) (string, bool) {
+		value := getenv(key, unset)
+		return value, value != unset
+	}
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	file, ok := lookup(FileEnv)
+	if !ok {
+		file = ""
+	}
+	path := flags.String("config", file, "")
+	for _, f := range fs {
+		flags.String(f.Flag(), "", "")
+	}
+	if extra != nil {
+		extra.VisitAll(func(fl *flag.Flag) {
+			flags.Var(fl.Value, fl.Name, fl.Usage)
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return config, sources, err
+	}
+	if flags.NArg() > 0 {
+		return config, sources, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	var errs []error
+	if *path != "" {
+		values, err := readFile(*path)
+		if err != nil {
+			return config, sources, err
+		}
+		for i, f := range fs {
+			value, ok := values[f.Key()]
+			if !ok {
+				continue
+			}
+			delete(values, f.Key())
+			if err := f.set(&config, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", *path, f.Key(), err))
+			}
+			sources[i] = "config file " + *path
+		}
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", *path, key))
+		}
+	}
+	for i, f := range fs {
+		value, ok := lookup(f.env)
+		source := "environment"
+		if secret, isSet := lookup(f.FileVariable()); f.secret && isSet && secret != "" {
+			if ok {
+				errs = append(errs, fmt.Errorf("%s: cannot be set along with %s", f.env, f.FileVariable()))
+				continue
+			}
+			data, err := os.ReadFile(secret)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.FileVariable(), err))
+				continue
+			}
+			// Secret files usually end with a newline that is not part of
+			// the value.
+			value, ok = strings.TrimRight(string(data), "\r\n"), true
+			source = "secret file " + secret
+		}
+		if ok {
+			if err := f.set(&config, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+			sources[i] = source
+		}
+	}
+	flags.Visit(func(fl *flag.Flag) {
+		for i, f := range fs {
+			if f.Flag() == fl.Name {
+				if err := f.set(&config, fl.Value.String()); err != nil {
+					errs = append(errs, fmt.Errorf("--%s: %w", fl.Name, err))
+				}
+				sources[i] = "flag"
+			}
+		}
+	})
+
+	// Values that did not parse kept their defaults, so only validate when
+	// everything parsed to avoid reporting the same problem twice.
+	if len(errs) == 0 {
+		if err := config.Validate(); err != nil {
+			return config, sources, err
+		}
+	}
+	return config, sources, errors.Join(errs...)
+}
+
+// Usage describes the flags and environment variables accepted by Load.
+func Usage(w io.Writer) {
+	defaults := Default()
+	fmt.Fprintf(w, "Settings, as flags and environment variables, in increasing order of precedence\nafter the defaults and the config file:\n\n")
+	fmt.Fprintf(w, "  --config (%s)\n\tYAML, TOML or JSON config file\n", FileEnv)
+	for _, f := range fields() {
+		fmt.Fprintf(w, "  --%s (%s)\n", f.Flag(), f.env)
+		if f.secret {
+			fmt.Fprintf(w, "\tsecret, or read from the file named by %s\n", f.FileVariable())
+		}
+		if def := f.get(defaults); def != "" && def != "0" && def != "0s" {
+			fmt.Fprintf(w, "\tdefault %s\n", def)
+		}
+	}
+}
+
+// readFile reads a flat config file into strings keyed by setting. The
+// format is chosen by the file's extension.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q, want .yaml, .toml or .json", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	var errs []error
+	for key, v := range raw {
+		s, err := scalar(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+			continue
+		}
+		values[key] = s
+	}
+	return values, errors.Join(errs...)
+}
+
+// scalar formats a decoded file value the way it would be written in the
+// environment. Lists become comma-separated.
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := scalar(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
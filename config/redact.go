@@ -0,0 +1,94 @@
+package config
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Redacted stands in for secrets that cannot be shown even in part.
+const Redacted = "[redacted]"
+
+// redactedPassword replaces the password of a secret URL.
+const redactedPassword = "xxxxx"
+
+// redact hides the credentials in a secret. URLs keep everything but their
+// password, so that which database is used can still be seen.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	u, err := url.Parse(secret)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		// Such as a key=value connection string.
+		return Redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedPassword)
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", redactedPassword)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// show formats the setting f of c for people to read, redacting secrets.
+func (f field) show(c Config) string {
+	if f.secret {
+		return redact(f.get(c))
+	}
+	return f.get(c)
+}
+
+// String lists every setting by environment variable, with secrets
+// redacted, so that printing a Config cannot leak them.
+func (c Config) String() string {
+	var b strings.Builder
+	for i, f := range fields() {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f.env)
+		b.WriteByte('=')
+		b.WriteString(f.show(c))
+	}
+	return b.String()
+}
+
+// LogValue logs every setting under its config file key, with secrets
+// redacted.
+func (c Config) LogValue() slog.Value {
+	fs := fields()
+	attrs := make([]slog.Attr, len(fs))
+	for i, f := range fs {
+		attrs[i] = slog.String(f.Key(), f.show(c))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Setting is a setting of the effective configuration, as listed by
+// Explain.
+type Setting struct {
+	// Name is the setting's environment variable.
+	Name string
+	// Value is the setting's value, redacted if Secret.
+	Value  string
+	Secret bool
+	// Source says where the value came from: "default", "config file
+	// PATH", "environment", "secret file PATH" or "flag".
+	Source string
+}
+
+// Explain loads the configuration as Load does and lists each setting
+// with its source. The settings are returned even when the configuration
+// is invalid, to help find the problem.
+func Explain(getenv func(key, defaultValue string) string, args []string) ([]Setting, error) {
+	c, sources, err := load(getenv, args, nil)
+	fs := fields()
+	settings := make([]Setting, len(fs))
+	for i, f := range fs {
+		settings[i] = Setting{Name: f.env, Value: f.show(c), Secret: f.secret, Source: sources[i]}
+	}
+	return settings, err
+}
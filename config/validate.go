@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jllovet/go-server-template/internal/ratelimit"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// LogLevels are the accepted LOG_LEVEL values.
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// statementCacheModes are the accepted DB_STATEMENT_CACHE_MODE values.
+var statementCacheModes = []string{"", "cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol"}
+
+// Validate reports every problem with c at once, naming the settings by
+// their environment variables.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(env, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{env}, args...)...))
+	}
+
+	// No count or duration makes sense negative.
+	v := reflect.ValueOf(c)
+	for _, f := range fields() {
+		if k := f.typ.Kind(); k == reflect.Int || k == reflect.Int64 {
+			if v.Field(f.index).Int() < 0 {
+				invalid(f.env, "must not be negative")
+			}
+		}
+	}
+
+	if c.Port > 65535 {
+		invalid("PROJECT_PORT", "%d is not a port number", c.Port)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		invalid("CERT_FILE", "CERT_FILE and KEY_FILE must be set together")
+	}
+	if !slices.Contains(LogLevels, strings.ToLower(c.LogLevel)) {
+		invalid("LOG_LEVEL", "%q is not one of %v", c.LogLevel, LogLevels)
+	}
+	if _, err := logger.ParseLevels(c.LogComponentLevels); err != nil {
+		invalid("LOG_COMPONENT_LEVELS", "%v", err)
+	}
+	if _, err := logger.ParseFormat(c.LogFormat); err != nil {
+		invalid("LOG_FORMAT", "%v", err)
+	}
+	if c.LogSampleFirst > 0 && c.LogSampleInterval == 0 {
+		invalid("LOG_SAMPLE_INTERVAL", "must be positive to sample logs")
+	}
+	switch c.DatabaseDriver {
+	case "sql":
+	case "pgx":
+		if c.DatabaseReplicaURL != "" {
+			invalid("DATABASE_REPLICA_URL", "requires DATABASE_DRIVER=sql")
+		}
+	default:
+		invalid("DATABASE_DRIVER", "%q is not one of sql, pgx", c.DatabaseDriver)
+	}
+	if c.DatabaseReplicaURL != "" && c.DatabaseURL == "" {
+		invalid("DATABASE_REPLICA_URL", "requires DATABASE_URL")
+	}
+	if c.DatabaseReplicaURL != "" && c.ReplicaHealthCheckInterval == 0 {
+		invalid("DB_REPLICA_HEALTH_CHECK_INTERVAL", "must be positive")
+	}
+	if c.DBMaxConns > 0 && c.DBMinConns > c.DBMaxConns {
+		invalid("DB_MIN_CONNS", "must not exceed DB_MAX_CONNS")
+	}
+	if !slices.Contains(statementCacheModes, c.DBStatementCacheMode) {
+		invalid("DB_STATEMENT_CACHE_MODE", "%q is not one of %v", c.DBStatementCacheMode, statementCacheModes[1:])
+	}
+
+	// Tickers and timers cannot run with a zero period, and a trash kept
+	// for no time at all would be emptied on the next purge.
+	for env, d := range map[string]int64{
+		"TRASH_RETENTION":      int64(c.TrashRetention),
+		"TRASH_PURGE_INTERVAL": int64(c.TrashPurgeInterval),
+		"WEBHOOK_TIMEOUT":      int64(c.WebhookTimeout),
+		"SSE_HEARTBEAT":        int64(c.SSEHeartbeat),
+		"WS_PING_INTERVAL":     int64(c.WSPingInterval),
+		"IDEMPOTENCY_TTL":      int64(c.IdempotencyTTL),
+	} {
+		if d == 0 {
+			invalid(env, "must be positive")
+		}
+	}
+	if c.WebhookMaxAttempts == 0 {
+		invalid("WEBHOOK_MAX_ATTEMPTS", "must be at least 1")
+	}
+
+	for env, limit := range map[string]string{
+		"RATE_LIMIT_READ":   c.RateLimitRead,
+		"RATE_LIMIT_WRITE":  c.RateLimitWrite,
+		"RATE_LIMIT_STREAM": c.RateLimitStream,
+	} {
+		if _, err := ratelimit.ParseLimit(limit); err != nil {
+			invalid(env, "%v", err)
+		}
+	}
+	if _, err := ratelimit.ParsePrefixes(c.TrustedProxies); err != nil {
+		invalid("TRUSTED_PROXIES", "%v", err)
+	}
+
+	// Map iteration is random; keep the report stable.
+	slices.SortFunc(errs, func(a, b error) int {
+		return strings.Compare(a.Error(), b.Error())
+	})
+	return errors.Join(errs...)
+}
@@ -0,0 +1,61 @@
+// Package idempotency stores the responses to requests made with an
+// Idempotency-Key header so that a retried request can be answered with the
+// original response instead of being applied twice.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// Header is the request header carrying the client-chosen key.
+const Header = "Idempotency-Key"
+
+// Record is what is kept for a key: the fingerprint of the request that first
+// used it and, once that request has finished, its response.
+type Record struct {
+	Key         string
+	Fingerprint string
+	// Status is zero while the first request is still in flight.
+	Status    int
+	Header    http.Header
+	Body      []byte
+	ExpiresAt time.Time
+}
+
+// Completed reports whether the record holds a response.
+func (r Record) Completed() bool {
+	return r.Status != 0
+}
+
+// Store is the port through which records are persisted. Implementations
+// must make Reserve atomic: of several concurrent calls for the same key,
+// exactly one may succeed.
+type Store interface {
+	// Reserve stores rec, which has no response yet, unless an unexpired
+	// record for rec.Key exists. It reports whether rec was stored and
+	// otherwise returns the existing record.
+	Reserve(ctx context.Context, rec Record) (Record, bool, error)
+	// Complete saves the response of a reserved record.
+	Complete(ctx context.Context, rec Record) error
+	// Release removes a reservation so the key can be used again, for
+	// requests that failed in a way worth retrying.
+	Release(ctx context.Context, key string) error
+	// DeleteExpired removes records that expired before now.
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// Fingerprint identifies a request by its method, path and body, so that a
+// key reused for a different request can be detected.
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/idempotency"
+)
+
+// Store is an in-memory implementation of idempotency.Store.
+type Store struct {
+	// mu protects records and makes Reserve atomic.
+	mu      sync.Mutex
+	records map[string]idempotency.Record
+	now     func() time.Time
+}
+
+// New creates a new in-memory idempotency store.
+func New() *Store {
+	return &Store{
+		records: make(map[string]idempotency.Record),
+		now:     time.Now,
+	}
+}
+
+// Reserve stores rec unless an unexpired record for its key exists.
+func (s *Store) Reserve(ctx context.Context, rec idempotency.Record) (idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[rec.Key]; ok && existing.ExpiresAt.After(s.now()) {
+		return copyRecord(existing), false, nil
+	}
+	s.records[rec.Key] = copyRecord(rec)
+	return rec, true, nil
+}
+
+// Complete saves the response of a reserved record.
+func (s *Store) Complete(ctx context.Context, rec idempotency.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.Key] = copyRecord(rec)
+	return nil
+}
+
+// Release removes the record for key.
+func (s *Store) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// DeleteExpired removes records that expired before now.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key, rec := range s.records {
+		if !rec.ExpiresAt.After(now) {
+			delete(s.records, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// copyRecord keeps callers from mutating stored headers and bodies.
+func copyRecord(rec idempotency.Record) idempotency.Record {
+	if rec.Header != nil {
+		rec.Header = maps.Clone(rec.Header)
+		for k, v := range rec.Header {
+			rec.Header[k] = slices.Clone(v)
+		}
+	}
+	rec.Body = slices.Clone(rec.Body)
+	return rec
+}
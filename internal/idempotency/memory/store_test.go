@@ -0,0 +1,77 @@
+package memory_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/idempotency"
+	"github.com/jllovet/go-server-template/internal/idempotency/memory"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	now := time.Now()
+
+	rec := idempotency.Record{Key: "k1", Fingerprint: "fp", ExpiresAt: now.Add(time.Minute)}
+
+	t.Run("Reserve", func(t *testing.T) {
+		if _, ok, err := store.Reserve(ctx, rec); err != nil || !ok {
+			t.Fatalf("expected reservation, got ok=%v err=%v", ok, err)
+		}
+		existing, ok, err := store.Reserve(ctx, idempotency.Record{Key: "k1", Fingerprint: "other", ExpiresAt: now.Add(time.Minute)})
+		if err != nil || ok {
+			t.Fatalf("expected key to be taken, got ok=%v err=%v", ok, err)
+		}
+		if existing.Fingerprint != "fp" || existing.Completed() {
+			t.Errorf("unexpected existing record %+v", existing)
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		done := rec
+		done.Status = http.StatusCreated
+		done.Header = http.Header{"Content-Type": {"application/json"}}
+		done.Body = []byte(`{"id":"1"}`)
+		if err := store.Complete(ctx, done); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		done.Header.Set("Content-Type", "mutated")
+
+		existing, ok, _ := store.Reserve(ctx, rec)
+		if ok {
+			t.Fatal("expected key to be taken")
+		}
+		if existing.Status != http.StatusCreated || string(existing.Body) != `{"id":"1"}` ||
+			existing.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected stored response %+v", existing)
+		}
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		if err := store.Release(ctx, "k1"); err != nil {
+			t.Fatalf("Release failed: %v", err)
+		}
+		if _, ok, _ := store.Reserve(ctx, rec); !ok {
+			t.Error("expected released key to be reservable")
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		stale := idempotency.Record{Key: "k2", Fingerprint: "fp", ExpiresAt: now.Add(-time.Second)}
+		if _, ok, _ := store.Reserve(ctx, stale); !ok {
+			t.Fatal("expected reservation")
+		}
+		if _, ok, _ := store.Reserve(ctx, idempotency.Record{Key: "k2", Fingerprint: "new", ExpiresAt: now.Add(time.Minute)}); !ok {
+			t.Error("expected expired key to be taken over")
+		}
+
+		store.Reserve(ctx, idempotency.Record{Key: "k3", ExpiresAt: now.Add(-time.Second)})
+		n, err := store.DeleteExpired(ctx, now)
+		if err != nil || n != 1 {
+			t.Errorf("expected 1 expired record deleted, got %d (err=%v)", n, err)
+		}
+	})
+}
@@ -0,0 +1,9 @@
+package postgres
+
+import _ "embed"
+
+// Schema creates or updates the idempotency_keys table used by Store.
+// Applying it again changes nothing.
+//
+//go:embed schema.sql
+var Schema string
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/idempotency"
+)
+
+// Store implements idempotency.Store using PostgreSQL.
+type Store struct {
+	db *sql.DB
+}
+
+// New creates a new Postgres idempotency store.
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Reserve stores rec unless an unexpired record for its key exists. The
+// primary key makes the insert atomic; an expired record is taken over.
+func (s *Store) Reserve(ctx context.Context, rec idempotency.Record) (idempotency.Record, bool, error) {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("postgres encode header: %w", err)
+	}
+	query := `
+		INSERT INTO idempotency_keys (key, fingerprint, status, header, body, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status = EXCLUDED.status, header = EXCLUDED.header,
+			body = EXCLUDED.body, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()
+	`
+	res, err := s.db.ExecContext(ctx, query, rec.Key, rec.Fingerprint, rec.Status, string(header), nonNil(rec.Body), rec.ExpiresAt)
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("postgres reserve idempotency key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 1 {
+		return rec, true, nil
+	}
+
+	query = `SELECT key, fingerprint, status, header, body, expires_at FROM idempotency_keys WHERE key = $1`
+	existing, err := scanRecord(s.db.QueryRowContext(ctx, query, rec.Key))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released between the insert and the select; let the caller retry.
+		return idempotency.Record{}, false, fmt.Errorf("postgres reserve idempotency key: record vanished")
+	}
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("postgres find idempotency key: %w", err)
+	}
+	return existing, false, nil
+}
+
+// Complete saves the response of a reserved record.
+func (s *Store) Complete(ctx context.Context, rec idempotency.Record) error {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return fmt.Errorf("postgres encode header: %w", err)
+	}
+	query := `
+		UPDATE idempotency_keys SET status = $2, header = $3, body = $4, expires_at = $5
+		WHERE key = $1
+	`
+	if _, err := s.db.ExecContext(ctx, query, rec.Key, rec.Status, string(header), nonNil(rec.Body), rec.ExpiresAt); err != nil {
+		return fmt.Errorf("postgres complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release removes the record for key.
+func (s *Store) Release(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("postgres release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes records that expired before now.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("postgres delete expired idempotency keys: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("postgres delete expired idempotency keys: %w", err)
+	}
+	return int(n), nil
+}
+
+func scanRecord(row *sql.Row) (idempotency.Record, error) {
+	var (
+		rec    idempotency.Record
+		header []byte
+	)
+	if err := row.Scan(&rec.Key, &rec.Fingerprint, &rec.Status, &header, &rec.Body, &rec.ExpiresAt); err != nil {
+		return idempotency.Record{}, err
+	}
+	rec.Header = http.Header{}
+	if err := json.Unmarshal(header, &rec.Header); err != nil {
+		return idempotency.Record{}, fmt.Errorf("decode header: %w", err)
+	}
+	return rec, nil
+}
+
+// nonNil stores empty bodies as ” rather than NULL.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
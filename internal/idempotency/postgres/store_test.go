@@ -0,0 +1,83 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jllovet/go-server-template/internal/idempotency"
+	"github.com/jllovet/go-server-template/internal/idempotency/postgres"
+)
+
+func TestStore(t *testing.T) {
+	// Skip if TEST_DATABASE_URL is not set.
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("Skipping postgres idempotency store tests: TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("pgx", dbURL)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+	if _, err := db.Exec("TRUNCATE TABLE idempotency_keys"); err != nil {
+		t.Fatalf("failed to truncate tables: %v", err)
+	}
+
+	ctx := context.Background()
+	store := postgres.New(db)
+	now := time.Now()
+	rec := idempotency.Record{Key: "k1", Fingerprint: "fp", ExpiresAt: now.Add(time.Minute)}
+
+	if _, ok, err := store.Reserve(ctx, rec); err != nil || !ok {
+		t.Fatalf("expected reservation, got ok=%v err=%v", ok, err)
+	}
+	existing, ok, err := store.Reserve(ctx, idempotency.Record{Key: "k1", Fingerprint: "other", ExpiresAt: now.Add(time.Minute)})
+	if err != nil || ok || existing.Fingerprint != "fp" || existing.Completed() {
+		t.Fatalf("expected in-flight record, got %+v ok=%v err=%v", existing, ok, err)
+	}
+
+	done := rec
+	done.Status = http.StatusCreated
+	done.Header = http.Header{"Content-Type": {"application/json"}}
+	done.Body = []byte(`{"id":"1"}`)
+	if err := store.Complete(ctx, done); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	existing, _, err = store.Reserve(ctx, rec)
+	if err != nil || existing.Status != http.StatusCreated || string(existing.Body) != `{"id":"1"}` ||
+		existing.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected stored response %+v (err=%v)", existing, err)
+	}
+
+	if err := store.Release(ctx, "k1"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, ok, err := store.Reserve(ctx, rec); err != nil || !ok {
+		t.Errorf("expected released key to be reservable, got ok=%v err=%v", ok, err)
+	}
+
+	if _, _, err := store.Reserve(ctx, idempotency.Record{Key: "k2", ExpiresAt: now.Add(-time.Second)}); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if _, ok, err := store.Reserve(ctx, idempotency.Record{Key: "k2", Fingerprint: "new", ExpiresAt: now.Add(time.Minute)}); err != nil || !ok {
+		t.Errorf("expected expired key to be taken over, got ok=%v err=%v", ok, err)
+	}
+	store.Reserve(ctx, idempotency.Record{Key: "k3", ExpiresAt: now.Add(-time.Second)})
+	if n, err := store.DeleteExpired(ctx, now); err != nil || n != 1 {
+		t.Errorf("expected 1 expired record deleted, got %d (err=%v)", n, err)
+	}
+}
@@ -0,0 +1,30 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// Sweep deletes expired records from store every interval until ctx is
+// cancelled. Expired records are already ignored by Reserve; sweeping only
+// reclaims their space.
+func Sweep(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := store.DeleteExpired(ctx, now)
+			if err != nil && ctx.Err() == nil {
+				logger.FromContext(ctx).Error("idempotency sweep failed", "error", err)
+			}
+			if n > 0 {
+				logger.FromContext(ctx).Debug("swept expired idempotency keys", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/ratelimit"
+)
+
+// cleanupInterval is how often buckets that have refilled are dropped.
+const cleanupInterval = time.Minute
+
+// Store is an in-memory implementation of ratelimit.Store. It only limits
+// the requests that reach this instance.
+type Store struct {
+	// mu protects buckets and makes Take atomic.
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket will have refilled, after which it is
+	// indistinguishable from a new one and can be dropped.
+	full time.Time
+}
+
+// New creates a new in-memory rate limit store.
+func New() *Store {
+	return &Store{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take removes a token from the bucket for key.
+func (s *Store) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	if limit.Unlimited() {
+		return ratelimit.Result{Allowed: true}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.cleanup(now)
+
+	capacity := float64(limit.Requests)
+	rate := limit.Rate()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	res := ratelimit.Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.ResetAfter = secondsToDuration((capacity - b.tokens) / rate)
+	b.full = now.Add(res.ResetAfter)
+	return res, nil
+}
+
+// cleanup drops refilled buckets, at most once per cleanupInterval.
+func (s *Store) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < cleanupInterval {
+		return
+	}
+	s.lastCleanup = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
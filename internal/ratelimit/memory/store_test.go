@@ -0,0 +1,65 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/ratelimit"
+	"github.com/jllovet/go-server-template/internal/ratelimit/memory"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Exhausts the bucket", func(t *testing.T) {
+		store := memory.New()
+		limit := ratelimit.Limit{Requests: 2, Per: time.Hour}
+		for i, wantRemaining := range []int{1, 0} {
+			res, err := store.Take(ctx, "a", limit)
+			if err != nil {
+				t.Fatalf("Take() error = %v", err)
+			}
+			if !res.Allowed || res.Remaining != wantRemaining {
+				t.Errorf("request %d: got %+v, want allowed with %d remaining", i, res, wantRemaining)
+			}
+		}
+		res, _ := store.Take(ctx, "a", limit)
+		if res.Allowed {
+			t.Fatal("expected third request to be refused")
+		}
+		// One token comes back every 30 minutes.
+		if res.RetryAfter <= 29*time.Minute || res.RetryAfter > 30*time.Minute {
+			t.Errorf("got RetryAfter %v, want about 30m", res.RetryAfter)
+		}
+		if res.ResetAfter <= 59*time.Minute || res.ResetAfter > time.Hour {
+			t.Errorf("got ResetAfter %v, want about 1h", res.ResetAfter)
+		}
+
+		if res, _ := store.Take(ctx, "b", limit); !res.Allowed {
+			t.Error("expected another key to have its own bucket")
+		}
+	})
+
+	t.Run("Refills", func(t *testing.T) {
+		store := memory.New()
+		limit := ratelimit.Limit{Requests: 1, Per: 20 * time.Millisecond}
+		_, _ = store.Take(ctx, "a", limit)
+		if res, _ := store.Take(ctx, "a", limit); res.Allowed {
+			t.Fatal("expected second request to be refused")
+		}
+		time.Sleep(25 * time.Millisecond)
+		if res, _ := store.Take(ctx, "a", limit); !res.Allowed {
+			t.Error("expected request to be allowed after refill")
+		}
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		store := memory.New()
+		for range 10 {
+			if res, _ := store.Take(ctx, "a", ratelimit.Limit{}); !res.Allowed {
+				t.Fatal("expected unlimited requests to be allowed")
+			}
+		}
+	})
+}
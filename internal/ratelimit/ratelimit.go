@@ -0,0 +1,177 @@
+// Package ratelimit limits how often each client may call the API, using a
+// token bucket per client and route group.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests requests per Per: a client's bucket holds at most
+// Requests tokens and is refilled at that rate. The zero Limit is unlimited.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Unlimited reports whether l places no limit.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+// Rate returns the refill rate in tokens per second.
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// String formats l as accepted by ParseLimit.
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// ParseLimit parses a limit such as "100/m", "10/s", "1000/h" or "50/30s".
+// An empty string is the unlimited zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" {
+		return Limit{}, nil
+	}
+	n, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: want <requests>/<period>", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid request count", s)
+	}
+	var d time.Duration
+	switch per {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		d, err = time.ParseDuration(per)
+		if err != nil || d <= 0 {
+			return Limit{}, fmt.Errorf("rate limit %q: invalid period", s)
+		}
+	}
+	return Limit{Requests: requests, Per: d}, nil
+}
+
+// Policy is the limit of each route group and the proxies trusted to
+// report client addresses in X-Forwarded-For. Groups without a limit are
+// not limited.
+type Policy struct {
+	Limits         map[string]Limit
+	TrustedProxies []netip.Prefix
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Remaining is how many whole tokens are left.
+	Remaining int
+	// RetryAfter is how long until a token is available, when not Allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// Store is the port through which buckets are kept. The in-memory store
+// limits each instance on its own; a shared store limits clients across
+// instances. Implementations must make Take atomic per key.
+type Store interface {
+	// Take removes a token from the bucket for key, creating a full one
+	// if there is none, and reports whether one was available.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// SetHeaders describes res in the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers, and in Retry-After when
+// the request was refused. Times are in whole seconds, rounded up.
+func SetHeaders(h http.Header, limit Limit, res Result) {
+	h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.ResetAfter)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, seconds(limit.Per)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(1, seconds(res.RetryAfter))))
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ParsePrefixes parses a comma-separated list of CIDR prefixes and plain
+// addresses, such as the TRUSTED_PROXIES setting.
+func ParsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.Contains(field, "/") {
+			p, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ClientIP returns the address of the client that sent r. X-Forwarded-For
+// is only believed when r comes from a trusted proxy, and then only up to
+// the first address, counting from the right, that is not itself a trusted
+// proxy: anything further left could have been made up by the client.
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	client := remote.Addr().Unmap()
+	if !isTrusted(client, trusted) {
+		return client.String()
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !isTrusted(client, trusted) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
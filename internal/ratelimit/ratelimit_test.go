@@ -0,0 +1,64 @@
+package ratelimit_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/ratelimit"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ratelimit.Limit
+		wantErr bool
+	}{
+		{"", ratelimit.Limit{}, false},
+		{"100/m", ratelimit.Limit{Requests: 100, Per: time.Minute}, false},
+		{"10/s", ratelimit.Limit{Requests: 10, Per: time.Second}, false},
+		{"50/30s", ratelimit.Limit{Requests: 50, Per: 30 * time.Second}, false},
+		{"100", ratelimit.Limit{}, true},
+		{"x/m", ratelimit.Limit{}, true},
+		{"10/fortnight", ratelimit.Limit{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ratelimit.ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %v, %v, want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ratelimit.ParsePrefixes("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf("ParsePrefixes() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"Direct", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"Untrusted proxy is ignored", "203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"Trusted proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"Spoofed entries are skipped", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.1, 192.168.1.1"}, "198.51.100.1"},
+		{"Multiple headers", "10.0.0.1:1234", []string{"1.1.1.1", "198.51.100.1"}, "198.51.100.1"},
+		{"Malformed entry", "10.0.0.1:1234", []string{"garbage"}, "10.0.0.1"},
+		{"IPv6", "[2001:db8::1]:1234", nil, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := ratelimit.ClientIP(r, trusted); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// CORS response headers. Browsers cache a preflight answer for
+// corsMaxAge seconds.
+const (
+	corsAllowMethods  = "GET, POST, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Idempotency-Key, X-Request-ID"
+	corsExposeHeaders = "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After, X-Request-ID, Idempotent-Replayed"
+	corsMaxAge        = "600"
+)
+
+// cors lets browser scripts from the allowed origins call next, answering
+// their preflight requests itself. Requests from other origins are served
+// without CORS headers, which browsers then refuse to expose.
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origins := s.corsOrigins.Load(); origins != nil && len(*origins) > 0 {
+			// Responses differ by origin whether or not this one is allowed.
+			w.Header().Add("Vary", "Origin")
+			if origin != "" && (slices.Contains(*origins, "*") || slices.Contains(*origins, origin)) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+				if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+					w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+					w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+					w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ParseOrigins splits a comma-separated list of origins, such as the
+// CORS_ALLOWED_ORIGINS setting.
+func ParseOrigins(list string) []string {
+	var origins []string
+	for _, o := range strings.Split(list, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, strings.TrimSuffix(o, "/"))
+		}
+	}
+	return origins
+}
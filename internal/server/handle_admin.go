@@ -0,0 +1,102 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// adminOnly lets through the requests that bear the admin token as
+// "Authorization: Bearer <token>".
+func (s *Server) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			logger.FromContext(r.Context()).Warn("admin request refused")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// logLevelsResponse shows the overall level and the component overrides.
+type logLevelsResponse struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+func (s *Server) logLevelsResponse() logLevelsResponse {
+	level, overrides := s.logLevels.Snapshot()
+	res := logLevelsResponse{Level: levelName(level), Components: map[string]string{}}
+	for c, l := range overrides {
+		res.Components[c] = levelName(l)
+	}
+	return res
+}
+
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
+
+func (s *Server) handleGetLogLevels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.encode(w, http.StatusOK, s.logLevelsResponse())
+	}
+}
+
+// handleSetLogLevel sets the level of a component, or the overall level
+// without one. An empty level removes the component's override. With a
+// duration, such as "15m", the change is undone after it.
+func (s *Server) handleSetLogLevel() http.HandlerFunc {
+	type request struct {
+		Component string `json:"component"`
+		Level     string `json:"level"`
+		Duration  string `json:"duration"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := s.decode(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var d time.Duration
+		if req.Duration != "" {
+			var err error
+			if d, err = time.ParseDuration(req.Duration); err != nil || d <= 0 {
+				http.Error(w, "duration must be a positive duration such as 15m", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Changes are logged at warn so that they show at any level.
+		log := logger.FromContext(r.Context())
+		if req.Level == "" {
+			if req.Component == "" || d > 0 {
+				http.Error(w, "level is required, except to remove a component's override", http.StatusBadRequest)
+				return
+			}
+			s.logLevels.Unset(req.Component)
+			log.Warn("log level override removed", "for", req.Component)
+			s.encode(w, http.StatusOK, s.logLevelsResponse())
+			return
+		}
+		level, err := logger.ParseLevel(req.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if d > 0 {
+			s.logLevels.SetFor(req.Component, level, d)
+		} else {
+			s.logLevels.Set(req.Component, level)
+		}
+		log.Warn("log level changed", "for", req.Component, "level", levelName(level), "duration", d)
+		s.encode(w, http.StatusOK, s.logLevelsResponse())
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo/feed"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+const defaultHeartbeatInterval = 15 * time.Second
+
+// handleTodoEvents streams todo changes as Server-Sent Events. Each event's
+// id can be sent back in the Last-Event-ID header to resume after a
+// disconnect. A "reset" event tells the client that changes were missed and
+// it should reload the list before applying further events.
+func (s *Server) handleTodoEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		rc := http.NewResponseController(w)
+
+		sub, err := s.feed.Subscribe(r.Header.Get("Last-Event-ID"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer sub.Close()
+
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("Connection", "keep-alive")
+		// Stop reverse proxies such as nginx from buffering the stream.
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		if sub.Reset {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, entry := range sub.Replay {
+			if err := writeEvent(w, entry); err != nil {
+				log.Error("sse write failed", "error", err)
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			log.Error("sse flush failed", "error", err)
+			return
+		}
+
+		interval := s.config.SSEHeartbeat
+		if interval <= 0 {
+			interval = defaultHeartbeatInterval
+		}
+		heartbeat := time.NewTicker(interval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case entry, ok := <-sub.C():
+				if !ok {
+					// Shutting down, or we fell behind; the client reconnects
+					// with Last-Event-ID and is replayed what it missed.
+					return
+				}
+				if err := writeEvent(w, entry); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				// Comment lines keep intermediaries from timing out idle connections.
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, entry feed.Entry) error {
+	data, err := json.Marshal(entry.Event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", entry.ID, entry.Event.Type, data)
+	return err
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/feed"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+const (
+	defaultPingInterval = 30 * time.Second
+	// socketSendBuffer is how many outbound messages may queue for a client
+	// before it is considered too slow and disconnected.
+	socketSendBuffer = 32
+	socketReadLimit  = 64 << 10
+	socketWriteWait  = 10 * time.Second
+)
+
+// socketCommand is a message sent by a client.
+//
+//	{"id": "1", "type": "subscribe"}                          all todos
+//	{"id": "2", "type": "subscribe", "todo_ids": ["..."]}     specific todos
+//	{"id": "3", "type": "unsubscribe", "todo_ids": ["..."]}   omit todo_ids for all
+//	{"id": "4", "type": "create", "title": "..."}
+//	{"id": "5", "type": "update", "todo_id": "...", "title": "..."}
+//	{"id": "6", "type": "complete", "todo_id": "...", "completed": true}
+//	{"id": "7", "type": "delete", "todo_id": "..."}
+type socketCommand struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	TodoIDs   []string `json:"todo_ids"`
+	TodoID    string   `json:"todo_id"`
+	Title     string   `json:"title"`
+	Completed bool     `json:"completed"`
+}
+
+// socketMessage is a message sent to a client: the "result" or "error" of the
+// command with the same id, or an "event" for a change made by someone else.
+type socketMessage struct {
+	Type  string      `json:"type"`
+	ID    string      `json:"id,omitempty"`
+	Todo  *todo.Todo  `json:"todo,omitempty"`
+	Event *todo.Event `json:"event,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// closeError carries the close status a connection should end with.
+type closeError struct {
+	code   websocket.StatusCode
+	reason string
+}
+
+func (e closeError) Error() string { return e.reason }
+
+// handleTodoSocket serves a WebSocket on which clients subscribe to todo
+// changes and send commands that are applied through the todo.Service.
+func (s *Server) handleTodoSocket() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			// Accept has already written an error response.
+			log.Warn("websocket accept failed", "error", err)
+			return
+		}
+		defer conn.CloseNow()
+		conn.SetReadLimit(socketReadLimit)
+
+		sub, err := s.feed.Subscribe("")
+		if err != nil {
+			conn.Close(websocket.StatusGoingAway, "server shutting down")
+			return
+		}
+		defer sub.Close()
+
+		interval := s.config.WSPingInterval
+		if interval <= 0 {
+			interval = defaultPingInterval
+		}
+		sc := &socketConn{
+			id:      newConnID(),
+			conn:    conn,
+			service: s.service,
+			sub:     sub,
+			out:     make(chan socketMessage, socketSendBuffer),
+			log:     log,
+			watched: make(map[string]bool),
+		}
+		code, reason := sc.serve(r.Context(), interval)
+		log.Info("websocket closed", "status", code.String(), "reason", reason)
+	}
+}
+
+// socketConn is the state of one WebSocket client.
+type socketConn struct {
+	id      string
+	conn    *websocket.Conn
+	service todo.Service
+	sub     *feed.Subscription
+	out     chan socketMessage
+	log     *slog.Logger
+
+	// mu guards the subscription filter, which the reader updates while the
+	// feed pump consults it.
+	mu       sync.Mutex
+	watchAll bool
+	watched  map[string]bool
+}
+
+// serve runs the connection until the client leaves, falls behind, stops
+// answering pings, or the feed closes, then closes it and returns the status.
+func (c *socketConn) serve(ctx context.Context, pingInterval time.Duration) (websocket.StatusCode, string) {
+	runCtx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		// Reads use the request context rather than runCtx: cancelling a
+		// read context makes the library drop the connection without a
+		// close frame, and we want to send one.
+		stop(c.readCommands(logger.WithContext(ctx, c.log)))
+	}()
+	go func() {
+		defer wg.Done()
+		stop(c.pumpEvents(runCtx))
+	}()
+	go func() {
+		defer wg.Done()
+		stop(c.keepAlive(runCtx, pingInterval))
+	}()
+	c.writeMessages(runCtx, stop)
+
+	code, reason := websocket.StatusInternalError, "internal error"
+	var ce closeError
+	switch err := context.Cause(runCtx); {
+	case errors.As(err, &ce):
+		code, reason = ce.code, ce.reason
+	case websocket.CloseStatus(err) != -1:
+		code, reason = websocket.StatusNormalClosure, ""
+	case errors.Is(err, context.Canceled):
+		code, reason = websocket.StatusGoingAway, "request cancelled"
+	}
+	// Closing unblocks the reader so that the goroutines can be collected.
+	c.conn.Close(code, reason)
+	wg.Wait()
+	return code, reason
+}
+
+// readCommands applies client commands one at a time, which also throttles
+// clients that send faster than the service can keep up.
+func (c *socketConn) readCommands(ctx context.Context) error {
+	ctx = feed.WithOrigin(ctx, c.id)
+	for {
+		typ, data, err := c.conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if typ != websocket.MessageText {
+			return closeError{websocket.StatusUnsupportedData, "expected text messages"}
+		}
+		var cmd socketCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			if err := c.send(socketMessage{Type: "error", Error: "malformed command: " + err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.send(c.apply(ctx, cmd)); err != nil {
+			return err
+		}
+	}
+}
+
+// apply executes cmd and returns the reply for the client.
+func (c *socketConn) apply(ctx context.Context, cmd socketCommand) socketMessage {
+	var (
+		t   todo.Todo
+		err error
+	)
+	switch cmd.Type {
+	case "subscribe":
+		c.watch(cmd.TodoIDs, true)
+		return socketMessage{Type: "result", ID: cmd.ID}
+	case "unsubscribe":
+		c.watch(cmd.TodoIDs, false)
+		return socketMessage{Type: "result", ID: cmd.ID}
+	case "create":
+		t, err = c.service.Create(ctx, cmd.Title)
+	case "update":
+		t, err = c.service.Update(ctx, cmd.TodoID, cmd.Title)
+	case "complete":
+		t, err = c.service.SetCompleted(ctx, cmd.TodoID, cmd.Completed)
+	case "delete":
+		if err = c.service.Delete(ctx, cmd.TodoID); err == nil {
+			return socketMessage{Type: "result", ID: cmd.ID}
+		}
+	default:
+		return socketMessage{Type: "error", ID: cmd.ID, Error: "unknown command type " + cmd.Type}
+	}
+	if err != nil {
+		return socketMessage{Type: "error", ID: cmd.ID, Error: err.Error()}
+	}
+	return socketMessage{Type: "result", ID: cmd.ID, Todo: &t}
+}
+
+// watch adds or removes todo IDs from the filter; no IDs means every todo.
+func (c *socketConn) watch(ids []string, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(ids) == 0 {
+		c.watchAll = on
+		clear(c.watched)
+		return
+	}
+	for _, id := range ids {
+		if on {
+			c.watched[id] = true
+		} else {
+			delete(c.watched, id)
+		}
+	}
+}
+
+func (c *socketConn) watching(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.watchAll || c.watched[id]
+}
+
+// pumpEvents forwards feed entries the client is subscribed to, skipping
+// changes the client made itself since it already has their results.
+func (c *socketConn) pumpEvents(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry, ok := <-c.sub.C():
+			if !ok {
+				// The feed closed (shutdown) or dropped us for lagging.
+				return closeError{websocket.StatusGoingAway, "event feed closed"}
+			}
+			if entry.Origin == c.id || !c.watching(entry.Event.Todo.ID) {
+				continue
+			}
+			e := entry.Event
+			if err := c.send(socketMessage{Type: "event", Event: &e}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send queues msg without blocking. A full queue means the client is not
+// reading fast enough, and it is disconnected rather than buffered forever.
+func (c *socketConn) send(msg socketMessage) error {
+	select {
+	case c.out <- msg:
+		return nil
+	default:
+		return closeError{websocket.StatusTryAgainLater, "client too slow"}
+	}
+}
+
+// writeMessages is the only writer of data frames on the connection.
+func (c *socketConn) writeMessages(ctx context.Context, stop context.CancelCauseFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-c.out:
+			data, err := json.Marshal(msg)
+			if err != nil {
+				stop(err)
+				return
+			}
+			wctx, cancel := context.WithTimeout(ctx, socketWriteWait)
+			err = c.conn.Write(wctx, websocket.MessageText, data)
+			cancel()
+			if err != nil {
+				stop(err)
+				return
+			}
+		}
+	}
+}
+
+// keepAlive pings the client and gives up when a pong does not arrive
+// within the ping interval.
+func (c *socketConn) keepAlive(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			pctx, cancel := context.WithTimeout(ctx, interval)
+			err := c.conn.Ping(pctx)
+			cancel()
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return closeError{websocket.StatusPolicyViolation, "ping timeout"}
+			}
+		}
+	}
+}
+
+func newConnID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
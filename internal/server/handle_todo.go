@@ -1,7 +1,13 @@
 package server
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/transfer"
+	"github.com/jllovet/go-server-template/logger"
 )
 
 func (s *Server) handleCreateTodo() http.HandlerFunc {
@@ -16,30 +22,88 @@ func (s *Server) handleCreateTodo() http.HandlerFunc {
 		}
 		t, err := s.service.Create(r.Context(), req.Title)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), todoErrorStatus(err))
 			return
 		}
 		s.encode(w, http.StatusCreated, t)
 	}
 }
 
+// handleListTodos answers with a JSON array, or, when the Accept header
+// prefers them, CSV or newline-delimited JSON streamed a todo at a time.
 func (s *Server) handleListTodos() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		mediaType := negotiate(r.Header.Get("Accept"), transfer.JSON.ContentType(), transfer.CSV.ContentType(), transfer.NDJSON.ContentType())
+		if mediaType == "" {
+			http.Error(w, "acceptable types are application/json, text/csv and application/x-ndjson", http.StatusNotAcceptable)
+			return
+		}
+		if format, _ := transfer.FormatOf(mediaType); format != transfer.JSON {
+			s.streamTodos(w, r, format)
+			return
+		}
 		todos, err := s.service.List(r.Context())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), todoErrorStatus(err))
 			return
 		}
 		s.encode(w, http.StatusOK, todos)
 	}
 }
 
+// streamTodos writes the todos in format as they are read. Once the first
+// is written the status can no longer change, so a later failure is only
+// logged and the response cut short.
+func (s *Server) streamTodos(w http.ResponseWriter, r *http.Request, format transfer.Format) {
+	w.Header().Set("Content-Type", format.ContentType())
+	enc := transfer.NewEncoder(w, format)
+	n := 0
+	err := s.service.ForEach(r.Context(), func(t todo.Todo) error {
+		n++
+		return enc.Encode(t)
+	})
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil && n == 0 {
+		http.Error(w, err.Error(), todoErrorStatus(err))
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).Error("listing todos failed part way", "error", err, "written", n)
+	}
+}
+
+func (s *Server) handleSearchTodos() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var limit int
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		results, err := s.service.Search(r.Context(), r.URL.Query().Get("q"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), todoErrorStatus(err))
+			return
+		}
+		if results == nil {
+			results = []todo.SearchResult{}
+		}
+		s.encode(w, http.StatusOK, results)
+	}
+}
+
 func (s *Server) handleGetTodo() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 		t, err := s.service.Get(r.Context(), id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			http.Error(w, err.Error(), todoErrorStatus(err))
 			return
 		}
 		s.encode(w, http.StatusOK, t)
@@ -59,7 +123,7 @@ func (s *Server) handleUpdateTodoTitle() http.HandlerFunc {
 		}
 		t, err := s.service.Update(r.Context(), id, req.Title)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), todoErrorStatus(err))
 			return
 		}
 		s.encode(w, http.StatusOK, t)
@@ -71,7 +135,7 @@ func (s *Server) handleMarkTodoComplete() http.HandlerFunc {
 		id := r.PathValue("id")
 		t, err := s.service.SetCompleted(r.Context(), id, true)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), todoErrorStatus(err))
 			return
 		}
 		s.encode(w, http.StatusOK, t)
@@ -83,20 +147,131 @@ func (s *Server) handleMarkTodoIncomplete() http.HandlerFunc {
 		id := r.PathValue("id")
 		t, err := s.service.SetCompleted(r.Context(), id, false)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), todoErrorStatus(err))
 			return
 		}
 		s.encode(w, http.StatusOK, t)
 	}
 }
 
+// todoErrorStatus maps todo service errors to HTTP status codes.
+func todoErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, todo.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, todo.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, todo.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, todo.ErrBatchTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, todo.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, todo.ErrAuditDisabled):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
 func (s *Server) handleDeleteTodo() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 		if err := s.service.Delete(r.Context(), id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), todoErrorStatus(err))
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+func (s *Server) handleListTrash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		todos, err := s.service.ListTrash(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), todoErrorStatus(err))
+			return
+		}
+		if todos == nil {
+			todos = []todo.Todo{}
+		}
+		s.encode(w, http.StatusOK, todos)
+	}
+}
+
+func (s *Server) handleRestoreTodo() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		t, err := s.service.Restore(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), todoErrorStatus(err))
+			return
+		}
+		s.encode(w, http.StatusOK, t)
+	}
+}
+
+func (s *Server) handleTodoHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		entries, err := s.service.History(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), todoErrorStatus(err))
+			return
+		}
+		if entries == nil {
+			entries = []todo.AuditEntry{}
+		}
+		s.encode(w, http.StatusOK, entries)
+	}
+}
+
+// handleBatchTodos applies a list of operations. Atomic batches answer 200
+// with every result or fail as a whole with the status of the failing
+// operation; other batches answer 207 with a status per operation.
+func (s *Server) handleBatchTodos() http.HandlerFunc {
+	type request struct {
+		Atomic     bool                  `json:"atomic"`
+		Operations []todo.BatchOperation `json:"operations"`
+	}
+	type result struct {
+		Index  int        `json:"index"`
+		Status int        `json:"status"`
+		Todo   *todo.Todo `json:"todo,omitempty"`
+		Error  string     `json:"error,omitempty"`
+	}
+	type response struct {
+		Results []result `json:"results"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := s.decode(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		results, err := s.service.Batch(r.Context(), req.Operations, req.Atomic)
+		if err != nil {
+			http.Error(w, err.Error(), todoErrorStatus(err))
+			return
+		}
+
+		resp := response{Results: make([]result, len(results))}
+		for i, res := range results {
+			out := result{Index: i, Status: http.StatusOK, Todo: res.Todo}
+			switch {
+			case res.Err != nil:
+				out.Status = todoErrorStatus(res.Err)
+				out.Error = res.Err.Error()
+			case req.Operations[i].Op == todo.BatchCreate:
+				out.Status = http.StatusCreated
+			case req.Operations[i].Op == todo.BatchDelete:
+				out.Status = http.StatusNoContent
+			}
+			resp.Results[i] = out
+		}
+		status := http.StatusOK
+		if !req.Atomic {
+			status = http.StatusMultiStatus
+		}
+		s.encode(w, status, resp)
+	}
+}
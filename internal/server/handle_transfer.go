@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/transfer"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// handleExportTodos streams every todo in the format named by the format
+// query parameter, JSON by default, as a file download.
+func (s *Server) handleExportTodos() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := transfer.JSON
+		if v := r.URL.Query().Get("format"); v != "" {
+			f, err := transfer.ParseFormat(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			format = f
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename=todos."+string(format))
+		s.streamTodos(w, r, format)
+	}
+}
+
+// handleImportTodos imports the todos in the request body as it is read.
+// The format comes from the format query parameter or else the
+// Content-Type; ids (keep or new), on_conflict and dry_run map to
+// todo.ImportOptions. The response is the transfer.Report, also when a
+// conflict stops the import.
+func (s *Server) handleImportTodos() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format, ok := transfer.FormatOf(r.Header.Get("Content-Type"))
+		if v := query.Get("format"); v != "" {
+			f, err := transfer.ParseFormat(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			format, ok = f, true
+		}
+		if !ok {
+			http.Error(w, "unknown format: set format to json, ndjson or csv, or send a matching Content-Type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		opts := todo.ImportOptions{KeepIDs: true, OnConflict: todo.ConflictFail}
+		switch query.Get("ids") {
+		case "", "keep":
+		case "new":
+			opts.KeepIDs = false
+		default:
+			http.Error(w, "ids must be keep or new", http.StatusBadRequest)
+			return
+		}
+		if v := query.Get("on_conflict"); v != "" {
+			policy, err := todo.ParseConflictPolicy(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			opts.OnConflict = policy
+		}
+		if v := query.Get("dry_run"); v != "" {
+			dryRun, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+				return
+			}
+			opts.DryRun = dryRun
+		}
+
+		// The report only comes with the response, so progress is logged.
+		log := logger.FromContext(r.Context())
+		report, err := transfer.Import(r.Context(), s.service, transfer.NewDecoder(r.Body, format), opts, func(r transfer.Report) {
+			log.Info("importing todos", "read", r.Read, "failed", r.Failed)
+		})
+		switch {
+		case err == nil:
+			s.encode(w, http.StatusOK, report)
+		case errors.Is(err, todo.ErrConflict):
+			s.encode(w, http.StatusConflict, report)
+		case errors.Is(err, transfer.ErrInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			logger.FromContext(r.Context()).Error("import failed", "error", err, "read", report.Read)
+			http.Error(w, err.Error(), todoErrorStatus(err))
+		}
+	}
+}
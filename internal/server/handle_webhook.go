@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/webhook"
+)
+
+// webhookErrorStatus maps webhook service errors to HTTP status codes.
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, webhook.ErrInvalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *Server) handleCreateWebhook() http.HandlerFunc {
+	type request struct {
+		URL    string           `json:"url"`
+		Events []todo.EventType `json:"events"`
+		Secret string           `json:"secret"`
+	}
+	// The secret is only ever returned in the creation response.
+	type response struct {
+		webhook.Subscription
+		Secret string `json:"secret"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := s.decode(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sub, err := s.webhooks.Subscribe(r.Context(), req.URL, req.Events, req.Secret)
+		if err != nil {
+			http.Error(w, err.Error(), webhookErrorStatus(err))
+			return
+		}
+		s.encode(w, http.StatusCreated, response{Subscription: sub, Secret: sub.Secret})
+	}
+}
+
+func (s *Server) handleListWebhooks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subs, err := s.webhooks.List(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if subs == nil {
+			subs = []webhook.Subscription{}
+		}
+		s.encode(w, http.StatusOK, subs)
+	}
+}
+
+func (s *Server) handleGetWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, err := s.webhooks.Get(r.Context(), r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), webhookErrorStatus(err))
+			return
+		}
+		s.encode(w, http.StatusOK, sub)
+	}
+}
+
+func (s *Server) handleDeleteWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.webhooks.Unsubscribe(r.Context(), r.PathValue("id")); err != nil {
+			http.Error(w, err.Error(), webhookErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handleListWebhookDeliveries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deliveries, err := s.webhooks.Deliveries(r.Context(), r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), webhookErrorStatus(err))
+			return
+		}
+		if deliveries == nil {
+			deliveries = []webhook.Delivery{}
+		}
+		s.encode(w, http.StatusOK, deliveries)
+	}
+}
+
+func (s *Server) handleRedeliverWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d, err := s.webhooks.Redeliver(r.Context(), r.PathValue("id"), r.PathValue("deliveryID"))
+		if err != nil {
+			http.Error(w, err.Error(), webhookErrorStatus(err))
+			return
+		}
+		s.encode(w, http.StatusAccepted, d)
+	}
+}
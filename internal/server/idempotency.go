@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/idempotency"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyLockTimeout bounds how long an in-flight request holds its
+	// key, so that a key whose request died with the process is not stuck.
+	idempotencyLockTimeout = time.Minute
+	maxIdempotencyKeyLen   = 255
+	maxIdempotentBody      = 1 << 20
+)
+
+// idempotent makes next safe to retry when the client sends an
+// Idempotency-Key: the first response for a key is stored and replayed for
+// later requests with the same key and body. Requests without a key, or
+// made while no store is configured, are passed through.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	if s.idempotency == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.Header)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		log := logger.FromContext(ctx)
+		// Keys are chosen by clients, so scope them to the caller, as
+		// identified for rate limiting: anonymous callers must not be able
+		// to replay each other's responses.
+		var trusted []netip.Prefix
+		if policy := s.rateLimitPolicy.Load(); policy != nil {
+			trusted = policy.TrustedProxies
+		}
+		rec := idempotency.Record{
+			Key:         clientKey(r, trusted) + ":" + key,
+			Fingerprint: idempotency.Fingerprint(r.Method, r.URL.Path, body),
+			ExpiresAt:   time.Now().Add(idempotencyLockTimeout),
+		}
+		existing, reserved, err := s.idempotency.Reserve(ctx, rec)
+		if err != nil {
+			log.Error("failed to reserve idempotency key", "error", err)
+			http.Error(w, "failed to reserve idempotency key", http.StatusInternalServerError)
+			return
+		}
+		if !reserved {
+			switch {
+			case existing.Fingerprint != rec.Fingerprint:
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			case !existing.Completed():
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "a request with this Idempotency-Key is still being processed", http.StatusConflict)
+			default:
+				log.Info("replaying idempotent response", "status", existing.Status)
+				replay(w, existing)
+			}
+			return
+		}
+
+		// Release the key unless a response is stored, so that a request
+		// that failed or panicked can be retried with the same key.
+		stored := false
+		defer func() {
+			if !stored {
+				if err := s.idempotency.Release(context.WithoutCancel(ctx), rec.Key); err != nil {
+					log.Error("failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		// Headers set before next, such as the rate limit and CORS
+		// headers, describe this request rather than the response, so only
+		// those next sets are stored.
+		before := w.Header().Clone()
+		rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next(rw, r)
+		if rw.status >= http.StatusInternalServerError {
+			return
+		}
+
+		ttl := s.config.IdempotencyTTL
+		if ttl <= 0 {
+			ttl = defaultIdempotencyTTL
+		}
+		rec.Status = rw.status
+		rec.Header = headerChanges(before, rw.Header())
+		rec.Body = rw.body.Bytes()
+		rec.ExpiresAt = time.Now().Add(ttl)
+		if err := s.idempotency.Complete(context.WithoutCancel(ctx), rec); err != nil {
+			log.Error("failed to store idempotent response", "error", err)
+			return
+		}
+		stored = true
+	}
+}
+
+// replay writes a stored response, marking it as such.
+func replay(w http.ResponseWriter, rec idempotency.Record) {
+	for k, v := range rec.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(rec.Status)
+	_, _ = w.Write(rec.Body)
+}
+
+// headerChanges returns the headers in after that are not in before with
+// the same values.
+func headerChanges(before, after http.Header) http.Header {
+	changed := http.Header{}
+	for k, v := range after {
+		if !slices.Equal(before[k], v) {
+			changed[k] = slices.Clone(v)
+		}
+	}
+	return changed
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.status = code
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
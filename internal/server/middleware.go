@@ -1,12 +1,14 @@
 package server
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/jllovet/go-server-template/internal/todo"
 	"github.com/jllovet/go-server-template/logger"
 )
 
@@ -30,21 +32,69 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 			slog.String("request_id", reqID),
 		)
 
-		// Inject logger into context
+		// Inject logger and request ID into context
 		ctx := logger.WithContext(r.Context(), log)
+		ctx = logger.WithRequestID(ctx, reqID)
+		kind := new(routeKind)
+		ctx = context.WithValue(ctx, routeKindKey{}, kind)
 
 		// Wrap ResponseWriter to capture status code
 		ww := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 
 		next.ServeHTTP(ww, r.WithContext(ctx))
 
-		log.Info("req completed",
+		// Failed and slow requests are logged above info, where logs are
+		// never sampled, and even for quiet routes. Streams are open for as
+		// long as the client listens, so they are never slow.
+		duration := time.Since(start)
+		level := slog.LevelInfo
+		switch {
+		case ww.status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case ww.status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case !kind.stream && s.config.LogSlowRequest > 0 && duration >= s.config.LogSlowRequest:
+			level = slog.LevelWarn
+		case kind.quiet:
+			return
+		}
+		log.Log(ctx, level, "req completed",
 			slog.Int("status", ww.status),
-			slog.Duration("duration", time.Since(start)),
+			slog.Duration("duration", duration),
 		)
 	})
 }
 
+// routeKind tells loggingMiddleware how to log the requests of a route.
+type routeKind struct {
+	quiet, stream bool
+}
+
+// routeKindKey is the context key of the routeKind set by quiet and stream.
+type routeKindKey struct{}
+
+// quiet keeps loggingMiddleware from logging the requests of a route, such
+// as a health check probed every second, unless they fail or are slow.
+func quiet(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if k, ok := r.Context().Value(routeKindKey{}).(*routeKind); ok {
+			k.quiet = true
+		}
+		next(w, r)
+	}
+}
+
+// stream keeps loggingMiddleware from logging the requests of a long-lived
+// route, such as an event stream, as slow.
+func stream(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if k, ok := r.Context().Value(routeKindKey{}).(*routeKind); ok {
+			k.stream = true
+		}
+		next(w, r)
+	}
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	status      int
@@ -59,3 +109,17 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap exposes the underlying ResponseWriter so http.ResponseController can
+// reach optional interfaces such as http.Flusher.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// readYourWrites tracks the writes of each request so that repositories
+// reading from replicas send its later reads to the primary.
+func readYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(todo.TrackWrites(r.Context())))
+	})
+}
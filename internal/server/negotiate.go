@@ -0,0 +1,58 @@
+package server
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// negotiate returns the media type among offers that the Accept header
+// prefers, the first offer when there is no header, and "" when the
+// header accepts none of them. Ties go to the earlier offer.
+func negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality is the q value that accept gives offer, taken from its
+// most specific matching media range.
+func acceptQuality(accept, offer string) float64 {
+	offerType, _, _ := strings.Cut(offer, "/")
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		var s int
+		switch {
+		case mediaRange == offer:
+			s = 2
+		case mediaRange == offerType+"/*":
+			s = 1
+		case mediaRange == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+		rangeQ := 1.0
+		if v, ok := params["q"]; ok {
+			if rangeQ, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		q, specificity = rangeQ, s
+	}
+	return q
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/jllovet/go-server-template/internal/ratelimit"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// Route groups share a rate limit.
+const (
+	// RouteGroupRead covers requests that only read.
+	RouteGroupRead = "read"
+	// RouteGroupWrite covers requests that change something.
+	RouteGroupWrite = "write"
+	// RouteGroupStream covers opening event streams and WebSockets.
+	RouteGroupStream = "stream"
+)
+
+// rateLimited limits how often each client may call next, sharing a token
+// bucket across the routes of group. Clients are told their allowance in
+// RateLimit-* headers and refused with 429 Too Many Requests once it is
+// spent. Routes are unlimited while no store is configured or the group
+// has no limit.
+func (s *Server) rateLimited(group string, next http.HandlerFunc) http.HandlerFunc {
+	if s.rateLimits == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		policy := s.rateLimitPolicy.Load()
+		limit := policy.Limits[group]
+		if limit.Unlimited() {
+			next(w, r)
+			return
+		}
+		ctx := r.Context()
+		client := clientKey(r, policy.TrustedProxies)
+		res, err := s.rateLimits.Take(ctx, group+"|"+client, limit)
+		if err != nil {
+			// Failing open keeps the API up when a shared store is down.
+			logger.FromContext(ctx).Error("rate limit store failed", "group", group, "error", err)
+			next(w, r)
+			return
+		}
+		ratelimit.SetHeaders(w.Header(), limit, res)
+		if !res.Allowed {
+			logger.FromContext(ctx).Warn("rate limited", "group", group, "client", client)
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientKey identifies the caller of r, for rate limits and idempotency
+// keys: by authenticated subject, else by address. Credentials that have not been checked, such as an API
+// key header, are not used: a client could make up a new one, and so get a
+// fresh bucket, for every request.
+func clientKey(r *http.Request, trusted []netip.Prefix) string {
+	if actor := todo.ActorFromContext(r.Context()); actor != todo.AnonymousActor {
+		return "subject:" + actor
+	}
+	return "ip:" + ratelimit.ClientIP(r, trusted)
+}
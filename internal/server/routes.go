@@ -8,32 +8,60 @@ import (
 func (s *Server) routes() http.Handler {
 	mux := http.NewServeMux()
 
-	// Health endpoints
-	mux.Handle("GET /healthz", s.handleHealthCheck())
-	mux.Handle("GET /ready", s.handleReady())
+	// Health endpoints, probed too often to log
+	mux.Handle("GET /healthz", quiet(s.handleHealthCheck()))
+	mux.Handle("GET /ready", quiet(s.handleReady()))
 
 	// Versioned API example
-	mux.Handle("GET /api/v0/hello", s.handleHello())
+	mux.HandleFunc("GET /api/v0/hello", s.rateLimited(RouteGroupRead, s.handleHello()))
 
 	// Todo endpoints
-	mux.HandleFunc("POST /api/v1/todos", s.handleCreateTodo())
-	mux.HandleFunc("GET /api/v1/todos", s.handleListTodos())
-	mux.HandleFunc("GET /api/v1/todos/{id}", s.handleGetTodo())
-	mux.HandleFunc("PATCH /api/v1/todos/{id}", s.handleUpdateTodoTitle())
-	mux.HandleFunc("POST /api/v1/todos/{id}/complete", s.handleMarkTodoComplete())
-	mux.HandleFunc("POST /api/v1/todos/{id}/incomplete", s.handleMarkTodoIncomplete())
-	mux.HandleFunc("DELETE /api/v1/todos/{id}", s.handleDeleteTodo())
+	mux.HandleFunc("POST /api/v1/todos", s.rateLimited(RouteGroupWrite, s.idempotent(s.handleCreateTodo())))
+	mux.HandleFunc("GET /api/v1/todos", s.rateLimited(RouteGroupRead, s.handleListTodos()))
+	mux.HandleFunc("POST /api/v1/todos:batch", s.rateLimited(RouteGroupWrite, s.idempotent(s.handleBatchTodos())))
+	mux.HandleFunc("GET /api/v1/todos:export", s.rateLimited(RouteGroupRead, s.handleExportTodos()))
+	// The import body is streamed, so it cannot be replayed by idempotent.
+	mux.HandleFunc("POST /api/v1/todos:import", s.rateLimited(RouteGroupWrite, s.handleImportTodos()))
+	mux.HandleFunc("GET /api/v1/todos/search", s.rateLimited(RouteGroupRead, s.handleSearchTodos()))
+	mux.HandleFunc("GET /api/v1/todos/{id}", s.rateLimited(RouteGroupRead, s.handleGetTodo()))
+	mux.HandleFunc("PATCH /api/v1/todos/{id}", s.rateLimited(RouteGroupWrite, s.handleUpdateTodoTitle()))
+	mux.HandleFunc("POST /api/v1/todos/{id}/complete", s.rateLimited(RouteGroupWrite, s.idempotent(s.handleMarkTodoComplete())))
+	mux.HandleFunc("POST /api/v1/todos/{id}/incomplete", s.rateLimited(RouteGroupWrite, s.idempotent(s.handleMarkTodoIncomplete())))
+	mux.HandleFunc("DELETE /api/v1/todos/{id}", s.rateLimited(RouteGroupWrite, s.handleDeleteTodo()))
+	mux.HandleFunc("POST /api/v1/todos/{id}/restore", s.rateLimited(RouteGroupWrite, s.idempotent(s.handleRestoreTodo())))
+	mux.HandleFunc("GET /api/v1/todos/{id}/history", s.rateLimited(RouteGroupRead, s.handleTodoHistory()))
+	mux.HandleFunc("GET /api/v1/trash", s.rateLimited(RouteGroupRead, s.handleListTrash()))
+	if s.feed != nil {
+		mux.HandleFunc("GET /api/v1/todos/events", stream(s.rateLimited(RouteGroupStream, s.handleTodoEvents())))
+		mux.HandleFunc("GET /api/v1/todos/ws", stream(s.rateLimited(RouteGroupStream, s.handleTodoSocket())))
+	}
+
+	// Webhook endpoints
+	if s.webhooks != nil {
+		mux.HandleFunc("POST /api/v1/webhooks", s.rateLimited(RouteGroupWrite, s.idempotent(s.handleCreateWebhook())))
+		mux.HandleFunc("GET /api/v1/webhooks", s.rateLimited(RouteGroupRead, s.handleListWebhooks()))
+		mux.HandleFunc("GET /api/v1/webhooks/{id}", s.rateLimited(RouteGroupRead, s.handleGetWebhook()))
+		mux.HandleFunc("DELETE /api/v1/webhooks/{id}", s.rateLimited(RouteGroupWrite, s.handleDeleteWebhook()))
+		mux.HandleFunc("GET /api/v1/webhooks/{id}/deliveries", s.rateLimited(RouteGroupRead, s.handleListWebhookDeliveries()))
+		mux.HandleFunc("POST /api/v1/webhooks/{id}/deliveries/{deliveryID}/redeliver", s.rateLimited(RouteGroupWrite, s.idempotent(s.handleRedeliverWebhook())))
+	}
+
+	// Admin endpoints
+	if s.adminToken != "" {
+		mux.HandleFunc("GET /admin/log-levels", s.rateLimited(RouteGroupRead, s.adminOnly(s.handleGetLogLevels())))
+		mux.HandleFunc("PUT /admin/log-levels", s.rateLimited(RouteGroupWrite, s.adminOnly(s.handleSetLogLevel())))
+	}
 
 	// Default 404
 	mux.Handle("/", http.NotFoundHandler())
 
-	return s.loggingMiddleware(mux)
+	return s.loggingMiddleware(s.cors(readYourWrites(mux)))
 }
 
 func (s *Server) handleHello() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		s.encode(w, http.StatusOK, map[string]string{
-			"message": fmt.Sprintf("Hello from %s:%s", s.config.Host, s.config.Port),
+			"message": fmt.Sprintf("Hello from %s:%d", s.config.Host, s.config.Port),
 		})
 	}
 }
@@ -47,7 +75,16 @@ func (s *Server) handleHealthCheck() http.HandlerFunc {
 // Returns whether the server is ready
 func (s *Server) handleReady() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check dependencies here. If all ready return {"ready": true}
+		failed := map[string]string{}
+		for _, c := range s.readiness {
+			if err := c.check(r.Context()); err != nil {
+				failed[c.name] = err.Error()
+			}
+		}
+		if len(failed) > 0 {
+			s.encode(w, http.StatusServiceUnavailable, map[string]any{"ready": false, "checks": failed})
+			return
+		}
 		s.encode(w, http.StatusOK, map[string]any{"ready": true})
 	}
 }
@@ -1,25 +1,126 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/idempotency"
+	"github.com/jllovet/go-server-template/internal/ratelimit"
 	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/feed"
+	"github.com/jllovet/go-server-template/internal/webhook"
+	"github.com/jllovet/go-server-template/logger"
 )
 
 type Server struct {
-	service todo.Service
-	config  *config.Config
-	logger  *slog.Logger
+	service     todo.Service
+	webhooks    webhook.Service
+	feed        *feed.Broker
+	idempotency idempotency.Store
+	readiness   []readinessCheck
+	// Rate limiting is off while rateLimits is nil. The policy and the CORS
+	// origins can be swapped while the server runs.
+	rateLimits      ratelimit.Store
+	rateLimitPolicy atomic.Pointer[ratelimit.Policy]
+	corsOrigins     atomic.Pointer[[]string]
+	// The /admin endpoints are off while adminToken is empty.
+	adminToken string
+	logLevels  *logger.Levels
+	config     *config.Config
+	logger     *slog.Logger
 }
 
-func NewServer(service todo.Service, config *config.Config, logger *slog.Logger) *Server {
-	return &Server{
+// Option configures optional features of the Server. Routes for a feature
+// are only registered when its dependency has been provided.
+type Option func(*Server)
+
+// WithWebhooks enables the /api/v1/webhooks endpoints.
+func WithWebhooks(webhooks webhook.Service) Option {
+	return func(s *Server) {
+		s.webhooks = webhooks
+	}
+}
+
+// WithFeed enables the GET /api/v1/todos/events Server-Sent Events stream.
+func WithFeed(broker *feed.Broker) Option {
+	return func(s *Server) {
+		s.feed = broker
+	}
+}
+
+// WithIdempotency honours the Idempotency-Key header on POST endpoints,
+// keeping responses in store for config.IdempotencyTTL.
+func WithIdempotency(store idempotency.Store) Option {
+	return func(s *Server) {
+		s.idempotency = store
+	}
+}
+
+// WithRateLimit limits how often each client may call the API, per route
+// group, keeping token buckets in store.
+func WithRateLimit(store ratelimit.Store, policy ratelimit.Policy) Option {
+	return func(s *Server) {
+		s.rateLimits = store
+		s.rateLimitPolicy.Store(&policy)
+	}
+}
+
+// WithCORS lets browser scripts from origins call the API; "*" allows any
+// origin.
+func WithCORS(origins []string) Option {
+	return func(s *Server) {
+		s.SetCORSOrigins(origins)
+	}
+}
+
+// WithAdmin enables the /admin endpoints for requests bearing token, which
+// change the levels of the server's loggers at runtime.
+func WithAdmin(token string, levels *logger.Levels) Option {
+	return func(s *Server) {
+		s.adminToken = token
+		s.logLevels = levels
+	}
+}
+
+// readinessCheck is a named dependency check run by GET /ready.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// WithReadinessCheck makes GET /ready report the server as not ready while
+// check returns an error.
+func WithReadinessCheck(name string, check func(ctx context.Context) error) Option {
+	return func(s *Server) {
+		s.readiness = append(s.readiness, readinessCheck{name: name, check: check})
+	}
+}
+
+func NewServer(service todo.Service, config *config.Config, logger *slog.Logger, opts ...Option) *Server {
+	s := &Server{
 		service: service,
 		config:  config,
 		logger:  logger,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetRateLimitPolicy replaces the rate limits of a server created
+// WithRateLimit. Clients keep their buckets, refilled at the new rates.
+func (s *Server) SetRateLimitPolicy(policy ratelimit.Policy) {
+	s.rateLimitPolicy.Store(&policy)
+}
+
+// SetCORSOrigins replaces the origins allowed by WithCORS; none disables
+// CORS.
+func (s *Server) SetCORSOrigins(origins []string) {
+	s.corsOrigins.Store(&origins)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
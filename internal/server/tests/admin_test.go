@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// syncBuffer is a bytes.Buffer that the server may write to while the test
+// reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestIntegration_AdminLogLevels(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	var logs syncBuffer
+	levels := logger.NewLevels(slog.LevelInfo)
+	log := logger.NewWithOptions(&logs, "todo-service", logger.Options{Levels: levels}).With(logger.ComponentKey, "http")
+
+	service := todo.NewService(memory.New())
+	ts := httptest.NewServer(server.NewServer(service, cfg, log, server.WithAdmin("s3cret", levels)))
+	defer ts.Close()
+
+	type state struct {
+		Level      string            `json:"level"`
+		Components map[string]string `json:"components"`
+	}
+	call := func(method, token, body string) (*http.Response, state) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+"/admin/log-levels", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var s state
+		if resp.Header.Get("Content-Type") == "application/json" {
+			json.NewDecoder(resp.Body).Decode(&s)
+		}
+		return resp, s
+	}
+
+	t.Run("1. Requires the token", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			resp, _ := call(http.MethodGet, token, "")
+			if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("token %q: got %d, want 401 with a challenge", token, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("2. Turns on debug for a component", func(t *testing.T) {
+		resp, s := call(http.MethodPut, "s3cret", `{"component": "http", "level": "debug", "duration": "1h"}`)
+		if resp.StatusCode != http.StatusOK || s.Level != "info" || s.Components["http"] != "debug" {
+			t.Fatalf("got %d and %+v, want http at debug", resp.StatusCode, s)
+		}
+		log.Debug("now visible")
+		if !strings.Contains(logs.String(), "now visible") {
+			t.Errorf("debug record not logged: %q", logs.String())
+		}
+		if _, s := call(http.MethodGet, "s3cret", ""); s.Components["http"] != "debug" {
+			t.Errorf("got %+v, want the change listed", s)
+		}
+	})
+
+	t.Run("3. Removes an override", func(t *testing.T) {
+		resp, s := call(http.MethodPut, "s3cret", `{"component": "http"}`)
+		if resp.StatusCode != http.StatusOK || len(s.Components) != 0 {
+			t.Errorf("got %d and %+v, want no overrides", resp.StatusCode, s)
+		}
+		if resp, s := call(http.MethodPut, "s3cret", `{"level": "warn"}`); resp.StatusCode != http.StatusOK || s.Level != "warn" {
+			t.Errorf("got %d and %+v, want warn overall", resp.StatusCode, s)
+		}
+	})
+
+	t.Run("4. Bad requests", func(t *testing.T) {
+		for _, body := range []string{
+			`{"level": "loud"}`,
+			`{"level": "debug", "duration": "soon"}`,
+			`{"level": "debug", "duration": "-1m"}`,
+			`{}`,
+		} {
+			if resp, _ := call(http.MethodPut, "s3cret", body); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: got %d, want 400", body, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("5. Off without a token", func(t *testing.T) {
+		ts := httptest.NewServer(server.NewServer(service, cfg, slog.New(slog.NewTextHandler(io.Discard, nil))))
+		defer ts.Close()
+		resp, err := ts.Client().Get(ts.URL + "/admin/log-levels")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("got %d, want 404", resp.StatusCode)
+		}
+	})
+}
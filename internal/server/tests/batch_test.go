@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestIntegration_Batch(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	service := todo.NewService(memory.New(), todo.WithMaxBatchSize(5))
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger))
+	defer ts.Close()
+
+	type result struct {
+		Index  int        `json:"index"`
+		Status int        `json:"status"`
+		Todo   *todo.Todo `json:"todo"`
+		Error  string     `json:"error"`
+	}
+	batch := func(body string) (*http.Response, []result) {
+		t.Helper()
+		resp, err := ts.Client().Post(ts.URL+"/api/v1/todos:batch", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Results []result `json:"results"`
+		}
+		if resp.Header.Get("Content-Type") == "application/json" {
+			json.NewDecoder(resp.Body).Decode(&out)
+		}
+		return resp, out.Results
+	}
+
+	var ids []string
+	t.Run("1. Atomic create", func(t *testing.T) {
+		resp, results := batch(`{"atomic": true, "operations": [
+			{"op": "create", "title": "One"},
+			{"op": "create", "title": "Two"},
+			{"op": "create", "title": "Three"}
+		]}`)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		for _, r := range results {
+			if r.Status != http.StatusCreated || r.Todo == nil {
+				t.Fatalf("unexpected result %+v", r)
+			}
+			ids = append(ids, r.Todo.ID)
+		}
+	})
+
+	t.Run("2. Atomic failure applies nothing", func(t *testing.T) {
+		resp, _ := batch(`{"atomic": true, "operations": [
+			{"op": "complete", "id": "` + ids[0] + `"},
+			{"op": "delete", "id": "missing"}
+		]}`)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 Not Found, got %d", resp.StatusCode)
+		}
+		if got, _ := service.Get(t.Context(), ids[0]); got.Completed {
+			t.Error("expected completion to be rolled back")
+		}
+	})
+
+	t.Run("3. Partial batch reports per item", func(t *testing.T) {
+		resp, results := batch(`{"operations": [
+			{"op": "complete", "id": "` + ids[0] + `"},
+			{"op": "delete", "id": "` + ids[1] + `"},
+			{"op": "update", "id": "` + ids[2] + `", "title": ""},
+			{"op": "delete", "id": "missing"}
+		]}`)
+		if resp.StatusCode != http.StatusMultiStatus {
+			t.Fatalf("expected 207 Multi-Status, got %d", resp.StatusCode)
+		}
+		want := []int{http.StatusOK, http.StatusNoContent, http.StatusUnprocessableEntity, http.StatusNotFound}
+		for i, r := range results {
+			if r.Index != i || r.Status != want[i] {
+				t.Errorf("result %d: got index %d status %d, want status %d", i, r.Index, r.Status, want[i])
+			}
+		}
+		if results[2].Error == "" {
+			t.Error("expected error message for failed operation")
+		}
+	})
+
+	t.Run("4. Too many operations", func(t *testing.T) {
+		ops := strings.Repeat(`{"op": "create", "title": "x"},`, 6)
+		resp, _ := batch(`{"operations": [` + strings.TrimSuffix(ops, ",") + `]}`)
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected 413 Request Entity Too Large, got %d", resp.StatusCode)
+		}
+	})
+}
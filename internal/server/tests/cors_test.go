@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestIntegration_CORS(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	service := todo.NewService(memory.New())
+	srv := server.NewServer(service, cfg, logger,
+		server.WithCORS(server.ParseOrigins("https://app.example.com/, https://admin.example.com")),
+	)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	do := func(method, origin string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1/todos", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("1. Allowed origins are echoed", func(t *testing.T) {
+		resp := do(http.MethodGet, "https://app.example.com")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("got Access-Control-Allow-Origin %q, want the origin", got)
+		}
+		if got := resp.Header.Get("Vary"); got != "Origin" {
+			t.Errorf("got Vary %q, want Origin", got)
+		}
+	})
+
+	t.Run("2. Preflight requests are answered", func(t *testing.T) {
+		resp := do(http.MethodOptions, "https://admin.example.com")
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected 204 No Content, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Access-Control-Allow-Methods") == "" {
+			t.Error("expected Access-Control-Allow-Methods to be set")
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Idempotency-Key") || strings.Contains(got, "X-API-Key") {
+			t.Errorf("got Access-Control-Allow-Headers %q, want only headers the API reads", got)
+		}
+	})
+
+	t.Run("3. Other origins get no CORS headers", func(t *testing.T) {
+		resp := do(http.MethodGet, "https://evil.example.com")
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("got Access-Control-Allow-Origin %q, want none", got)
+		}
+	})
+
+	t.Run("4. Origins can be replaced while serving", func(t *testing.T) {
+		srv.SetCORSOrigins([]string{"*"})
+		resp := do(http.MethodGet, "https://evil.example.com")
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://evil.example.com" {
+			t.Errorf("got Access-Control-Allow-Origin %q, want the origin", got)
+		}
+		srv.SetCORSOrigins(nil)
+		resp = do(http.MethodGet, "https://app.example.com")
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("got Access-Control-Allow-Origin %q after disabling CORS", got)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+// unavailableRepo fails as a repository behind an open circuit breaker does.
+type unavailableRepo struct {
+	todo.Repository
+}
+
+func (unavailableRepo) FindByID(context.Context, string) (todo.Todo, error) {
+	return todo.Todo{}, todo.ErrUnavailable
+}
+
+func (unavailableRepo) FindAll(context.Context) ([]todo.Todo, error) {
+	return nil, todo.ErrUnavailable
+}
+
+func (unavailableRepo) FindDeleted(context.Context) ([]todo.Todo, error) {
+	return nil, todo.ErrUnavailable
+}
+
+func (unavailableRepo) WithinTx(context.Context, func(context.Context) error) error {
+	return todo.ErrUnavailable
+}
+
+func TestIntegration_ErrorStatus(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	service := todo.NewService(unavailableRepo{memory.New()}, todo.WithAudit(memory.NewAuditRepository()))
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/api/v1/todos", `{"title":"Write"}`},
+		{http.MethodGet, "/api/v1/todos", ""},
+		{http.MethodGet, "/api/v1/todos/1", ""},
+		{http.MethodPatch, "/api/v1/todos/1", `{"title":"Rewrite"}`},
+		{http.MethodPost, "/api/v1/todos/1/complete", ""},
+		{http.MethodPost, "/api/v1/todos/1/incomplete", ""},
+		{http.MethodGet, "/api/v1/trash", ""},
+		{http.MethodGet, "/api/v1/todos/1/history", ""},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("got %d, want 503 while storage is unavailable", resp.StatusCode)
+			}
+		})
+	}
+}
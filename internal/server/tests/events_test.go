@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/feed"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+// sseEvent is one parsed Server-Sent Event.
+type sseEvent struct {
+	id, event, data string
+}
+
+// readEvent reads the next event from an SSE stream, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if ev != (sseEvent{}) {
+				return ev
+			}
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestIntegration_TodoEvents(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080, SSEHeartbeat: 20 * time.Millisecond}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	broker := feed.New(100)
+	service := todo.NewService(memory.New(), todo.WithPublisher(broker))
+	srv := server.NewServer(service, cfg, logger, server.WithFeed(broker))
+
+	// Run a real http.Server so the graceful shutdown path can be exercised.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	httpServer := &http.Server{Handler: srv}
+	httpServer.RegisterOnShutdown(broker.Close)
+	go httpServer.Serve(ln)
+	defer httpServer.Close()
+	baseURL := "http://" + ln.Addr().String()
+
+	stream := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", baseURL+"/api/v1/todos/events", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp, bufio.NewReader(resp.Body)
+	}
+	create := func(title string) {
+		t.Helper()
+		if _, err := service.Create(context.Background(), title); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	var lastID string
+
+	t.Run("1. Streams changes", func(t *testing.T) {
+		resp, events := stream("")
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("expected text/event-stream, got %q", ct)
+		}
+
+		create("First")
+		ev := readEvent(t, events)
+		if ev.event != "todo.created" || ev.id == "" {
+			t.Fatalf("got %+v, want a todo.created event with an id", ev)
+		}
+		var e todo.Event
+		if err := json.Unmarshal([]byte(ev.data), &e); err != nil {
+			t.Fatalf("data is not an event: %v", err)
+		}
+		if e.Todo.Title != "First" {
+			t.Errorf("expected title First, got %q", e.Todo.Title)
+		}
+		lastID = ev.id
+		resp.Body.Close()
+	})
+
+	t.Run("2. Sends heartbeats", func(t *testing.T) {
+		_, events := stream("")
+		line, err := events.ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, ":") {
+			t.Errorf("expected heartbeat comment, got %q (%v)", line, err)
+		}
+	})
+
+	t.Run("3. Resumes from Last-Event-ID", func(t *testing.T) {
+		create("Second")
+		create("Third")
+
+		_, events := stream(lastID)
+		for _, want := range []string{"Second", "Third"} {
+			var e todo.Event
+			_ = json.Unmarshal([]byte(readEvent(t, events).data), &e)
+			if e.Todo.Title != want {
+				t.Errorf("replayed %q, want %q", e.Todo.Title, want)
+			}
+		}
+	})
+
+	t.Run("4. Unknown Last-Event-ID resets", func(t *testing.T) {
+		_, events := stream("from-another-process-1")
+		if ev := readEvent(t, events); ev.event != "reset" {
+			t.Errorf("expected reset event, got %+v", ev)
+		}
+	})
+
+	t.Run("5. Shutdown ends open streams", func(t *testing.T) {
+		_, events := stream("")
+		_, _ = events.ReadString('\n') // wait until the stream is being served
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			t.Fatalf("Shutdown() error = %v, streams kept the server alive", err)
+		}
+		if _, err := io.ReadAll(events); err != nil {
+			t.Errorf("expected stream to end cleanly, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestIntegration_TodoHistory(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	service := todo.NewService(memory.New(), todo.WithAudit(memory.NewAuditRepository()))
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger))
+	defer ts.Close()
+
+	request := func(method, path, requestID string, body any) *http.Response {
+		t.Helper()
+		var bodyReader io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			bodyReader = bytes.NewReader(b)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bodyReader)
+		req.Header.Set("X-Request-ID", requestID)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	var created todo.Todo
+	_ = json.NewDecoder(request("POST", "/api/v1/todos", "req-create", map[string]string{"title": "Audited"}).Body).Decode(&created)
+	request("PATCH", "/api/v1/todos/"+created.ID, "req-update", map[string]string{"title": "Audited twice"})
+	request("POST", "/api/v1/todos/"+created.ID+"/complete", "req-complete", nil)
+	request("DELETE", "/api/v1/todos/"+created.ID, "req-delete", nil)
+
+	resp := request("GET", "/api/v1/todos/"+created.ID+"/history", "req-history", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+	var history []todo.AuditEntry
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	want := []struct {
+		action    todo.EventType
+		requestID string
+	}{
+		{todo.EventCreated, "req-create"},
+		{todo.EventUpdated, "req-update"},
+		{todo.EventCompleted, "req-complete"},
+		{todo.EventDeleted, "req-delete"},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(history))
+	}
+	for i, w := range want {
+		e := history[i]
+		if e.Action != w.action || e.RequestID != w.requestID || e.Actor != todo.AnonymousActor {
+			t.Errorf("entry %d = {%s %s %s}, want {%s %s anonymous}", i, e.Action, e.RequestID, e.Actor, w.action, w.requestID)
+		}
+	}
+	if history[1].Before.Title != "Audited" || history[1].After.Title != "Audited twice" {
+		t.Errorf("update snapshots = %+v -> %+v", history[1].Before, history[1].After)
+	}
+
+	t.Run("Unknown todo is not found", func(t *testing.T) {
+		resp := request("GET", "/api/v1/todos/missing/history", "req-missing", nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("got %d, want 404", resp.StatusCode)
+		}
+	})
+
+	t.Run("Not implemented without an audit log", func(t *testing.T) {
+		ts := httptest.NewServer(server.NewServer(todo.NewService(memory.New()), cfg, logger))
+		defer ts.Close()
+		resp, err := ts.Client().Get(ts.URL + "/api/v1/todos/any/history")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotImplemented {
+			t.Errorf("got %d, want 501", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/idempotency"
+	idempotencymemory "github.com/jllovet/go-server-template/internal/idempotency/memory"
+	"github.com/jllovet/go-server-template/internal/ratelimit"
+	ratelimitmemory "github.com/jllovet/go-server-template/internal/ratelimit/memory"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestIntegration_Idempotency(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080, IdempotencyTTL: time.Hour}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store := idempotencymemory.New()
+	service := todo.NewService(memory.New())
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger, server.WithIdempotency(store)))
+	defer ts.Close()
+
+	post := func(key, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("POST", ts.URL+"/api/v1/todos", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	count := func() int {
+		t.Helper()
+		todos, err := service.List(context.Background())
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		return len(todos)
+	}
+
+	var first todo.Todo
+	t.Run("1. First request is applied", func(t *testing.T) {
+		resp := post("key-1", `{"title": "Buy milk"}`)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 Created, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Idempotent-Replayed") != "" {
+			t.Error("first response should not be marked as replayed")
+		}
+		json.NewDecoder(resp.Body).Decode(&first)
+	})
+
+	t.Run("2. Retry replays the response", func(t *testing.T) {
+		resp := post("key-1", `{"title": "Buy milk"}`)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 Created, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Idempotent-Replayed") != "true" {
+			t.Error("expected Idempotent-Replayed header")
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected replayed Content-Type, got %q", ct)
+		}
+		var replayed todo.Todo
+		json.NewDecoder(resp.Body).Decode(&replayed)
+		if replayed.ID != first.ID {
+			t.Errorf("expected replayed todo %s, got %s", first.ID, replayed.ID)
+		}
+		if n := count(); n != 1 {
+			t.Errorf("expected 1 todo, got %d", n)
+		}
+	})
+
+	t.Run("3. Key reused with a different body", func(t *testing.T) {
+		if resp := post("key-1", `{"title": "Buy bread"}`); resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected 422 Unprocessable Entity, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("4. Request in flight", func(t *testing.T) {
+		body := `{"title": "Slow"}`
+		store.Reserve(context.Background(), idempotency.Record{
+			Key:         "ip:127.0.0.1:busy",
+			Fingerprint: idempotency.Fingerprint("POST", "/api/v1/todos", []byte(body)),
+			ExpiresAt:   time.Now().Add(time.Minute),
+		})
+		resp := post("busy", body)
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 Conflict, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") == "" {
+			t.Error("expected Retry-After header")
+		}
+	})
+
+	t.Run("5. Client errors are replayed", func(t *testing.T) {
+		if resp := post("key-2", `not json`); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 Bad Request, got %d", resp.StatusCode)
+		}
+		if resp := post("key-2", `not json`); resp.Header.Get("Idempotent-Replayed") != "true" {
+			t.Error("expected stored 400 to be replayed")
+		}
+	})
+
+	t.Run("6. Requests without a key are not deduplicated", func(t *testing.T) {
+		before := count()
+		post("", `{"title": "Again"}`)
+		post("", `{"title": "Again"}`)
+		if n := count(); n != before+2 {
+			t.Errorf("expected %d todos, got %d", before+2, n)
+		}
+	})
+}
+
+func TestIntegration_IdempotencyScope(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080, IdempotencyTTL: time.Hour}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	trusted, _ := ratelimit.ParsePrefixes("127.0.0.1")
+	policy := ratelimit.Policy{
+		Limits:         map[string]ratelimit.Limit{server.RouteGroupWrite: {Requests: 5, Per: time.Minute}},
+		TrustedProxies: trusted,
+	}
+	service := todo.NewService(memory.New())
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger,
+		server.WithIdempotency(idempotencymemory.New()),
+		server.WithRateLimit(ratelimitmemory.New(), policy),
+		server.WithCORS([]string{"https://a.example.com", "https://b.example.com"}),
+	))
+	defer ts.Close()
+
+	// post is sent through the trusted proxy on behalf of client.
+	post := func(client, origin string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("POST", ts.URL+"/api/v1/todos", strings.NewReader(`{"title": "Buy milk"}`))
+		req.Header.Set(idempotency.Header, "key-1")
+		req.Header.Set("X-Forwarded-For", client)
+		req.Header.Set("Origin", origin)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("1. Replays carry the current request's headers", func(t *testing.T) {
+		if resp := post("203.0.113.1", "https://a.example.com"); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 Created, got %d", resp.StatusCode)
+		}
+		resp := post("203.0.113.1", "https://b.example.com")
+		if resp.Header.Get("Idempotent-Replayed") != "true" {
+			t.Fatal("expected Idempotent-Replayed header")
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://b.example.com" {
+			t.Errorf("got Access-Control-Allow-Origin %q, want this request's origin", got)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != "3" {
+			t.Errorf("got RateLimit-Remaining %q, want 3 after two requests", got)
+		}
+		if got := resp.Header.Values("Vary"); len(got) != 1 {
+			t.Errorf("got Vary %q, want it once", got)
+		}
+	})
+
+	t.Run("2. Anonymous clients do not share keys", func(t *testing.T) {
+		resp := post("203.0.113.2", "https://a.example.com")
+		if resp.StatusCode != http.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "" {
+			t.Errorf("got %d replayed %q, want a new todo", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+		}
+		todos, _ := service.List(context.Background())
+		if len(todos) != 2 {
+			t.Errorf("expected 2 todos, got %d", len(todos))
+		}
+	})
+}
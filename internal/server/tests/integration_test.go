@@ -20,7 +20,7 @@ func TestIntegration_TodoWorkflow(t *testing.T) {
 	// We use the real in-memory repository and service.
 	cfg := &config.Config{
 		Host: "localhost",
-		Port: "8080", // Not actually used by httptest, but required by struct
+		Port: 8080, // Not actually used by httptest, but required by struct
 	}
 	// Discard logs during tests to keep output clean
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
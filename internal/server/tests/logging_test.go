@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/feed"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+func TestIntegration_RequestLogging(t *testing.T) {
+	type record struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Path   string `json:"path"`
+		Status int    `json:"status"`
+	}
+	// start serves with requests logged to the returned function, which
+	// waits for the request to path to be logged and returns what was
+	// logged about requests until then.
+	start := func(t *testing.T, cfg *config.Config, opts logger.Options, ready error, extra ...server.Option) (*httptest.Server, func(path string) []record) {
+		t.Helper()
+		var logs syncBuffer
+		log := logger.NewWithOptions(&logs, "http", opts)
+		service := todo.NewService(memory.New())
+		options := append([]server.Option{
+			server.WithReadinessCheck("database", func(context.Context) error { return ready }),
+		}, extra...)
+		ts := httptest.NewServer(server.NewServer(service, cfg, log, options...))
+		t.Cleanup(ts.Close)
+
+		logged := func(path string) []record {
+			t.Helper()
+			// Requests are logged once handled, which may be after the
+			// client has the response.
+			for deadline := time.Now().Add(2 * time.Second); ; {
+				var records []record
+				for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+					var r record
+					if json.Unmarshal([]byte(line), &r) == nil && r.Msg == "req completed" {
+						records = append(records, r)
+					}
+				}
+				if len(records) > 0 && records[len(records)-1].Path == path {
+					return records
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("request to %s not logged: %s", path, logs.String())
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+		return ts, logged
+	}
+	get := func(t *testing.T, ts *httptest.Server, path string) int {
+		t.Helper()
+		resp, err := ts.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("1. Health checks are not logged", func(t *testing.T) {
+		ts, logged := start(t, &config.Config{}, logger.Options{}, nil)
+		get(t, ts, "/healthz")
+		get(t, ts, "/ready")
+		get(t, ts, "/api/v0/hello")
+		records := logged("/api/v0/hello")
+		if len(records) != 1 || records[0].Level != "INFO" {
+			t.Errorf("got %+v, want only the hello request at info", records)
+		}
+	})
+
+	t.Run("2. Failures are logged as errors, even for health checks", func(t *testing.T) {
+		ts, logged := start(t, &config.Config{}, logger.Options{}, errors.New("down"))
+		if status := get(t, ts, "/ready"); status != http.StatusServiceUnavailable {
+			t.Fatalf("got %d, want 503", status)
+		}
+		records := logged("/ready")
+		if len(records) != 1 || records[0].Level != "ERROR" || records[0].Status != http.StatusServiceUnavailable {
+			t.Errorf("got %+v, want the failed check at error", records)
+		}
+	})
+
+	t.Run("3. Client errors are logged as warnings", func(t *testing.T) {
+		ts, logged := start(t, &config.Config{}, logger.Options{}, nil)
+		if status := get(t, ts, "/api/v1/todos/missing"); status != http.StatusNotFound {
+			t.Fatalf("got %d, want 404", status)
+		}
+		records := logged("/api/v1/todos/missing")
+		if len(records) != 1 || records[0].Level != "WARN" {
+			t.Errorf("got %+v, want the failed request at warn", records)
+		}
+	})
+
+	t.Run("4. Slow requests are logged as warnings", func(t *testing.T) {
+		ts, logged := start(t, &config.Config{LogSlowRequest: time.Nanosecond}, logger.Options{}, nil)
+		get(t, ts, "/healthz")
+		records := logged("/healthz")
+		if len(records) != 1 || records[0].Level != "WARN" {
+			t.Errorf("got %+v, want the slow request at warn", records)
+		}
+	})
+
+	t.Run("5. Sampling keeps failures and slow requests", func(t *testing.T) {
+		opts := logger.Options{Sample: logger.SampleOptions{First: 2, Interval: time.Hour}}
+		ts, logged := start(t, &config.Config{}, opts, nil)
+		for range 5 {
+			get(t, ts, "/api/v0/hello")
+		}
+		if got := len(logged("/api/v0/hello")); got != 2 {
+			t.Errorf("got %d records, want the first 2", got)
+		}
+		for range 3 {
+			get(t, ts, "/api/v1/todos/missing")
+		}
+		if got := len(logged("/api/v1/todos/missing")); got != 5 {
+			t.Errorf("got %d records, want the first 2 and every failure", got)
+		}
+
+		cfg := &config.Config{LogSlowRequest: time.Nanosecond}
+		ts, logged = start(t, cfg, opts, errors.New("down"))
+		for range 3 {
+			get(t, ts, "/ready")
+			get(t, ts, "/api/v0/hello")
+		}
+		if got := len(logged("/api/v0/hello")); got != 6 {
+			t.Errorf("got %d records, want all 6", got)
+		}
+	})
+
+	t.Run("6. Streams are not logged as slow", func(t *testing.T) {
+		cfg := &config.Config{LogSlowRequest: 10 * time.Millisecond, SSEHeartbeat: time.Hour}
+		ts, logged := start(t, cfg, logger.Options{}, nil, server.WithFeed(feed.New(10)))
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v1/todos/events", nil)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		resp.Body.Close()
+		records := logged("/api/v1/todos/events")
+		if len(records) != 1 || records[0].Level != "INFO" {
+			t.Errorf("got %+v, want the stream at info", records)
+		}
+	})
+}
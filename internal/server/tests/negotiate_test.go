@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+// brokenList fails every ForEach.
+type brokenList struct {
+	todo.Repository
+}
+
+func (brokenList) ForEach(context.Context, func(todo.Todo) error) error {
+	return errors.New("connection lost")
+}
+
+func TestIntegration_ContentNegotiation(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repo := memory.New()
+	for _, td := range []todo.Todo{{ID: "b", Title: "Second"}, {ID: "a", Title: "First, really", Completed: true}} {
+		_ = repo.Save(context.Background(), td)
+	}
+	ts := httptest.NewServer(server.NewServer(todo.NewService(repo), cfg, logger))
+	defer ts.Close()
+
+	list := func(accept string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/todos", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	csv := "id,title,completed\na,\"First, really\",true\nb,Second,false\n"
+	ndjson := `{"id":"a","title":"First, really","completed":true}` + "\n" + `{"id":"b","title":"Second","completed":false}` + "\n"
+	for _, tc := range []struct {
+		accept, contentType, body string
+	}{
+		{"", "application/json", ""},
+		{"*/*", "application/json", ""},
+		{"text/csv", "text/csv", csv},
+		{"application/x-ndjson", "application/x-ndjson", ndjson},
+		{"application/*;q=0.2, text/csv;q=0.8", "text/csv", csv},
+		{"text/csv;q=0.5, application/json", "application/json", ""},
+	} {
+		resp, body := list(tc.accept)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != tc.contentType {
+			t.Errorf("Accept %q: got %d with %q, want 200 with %q", tc.accept, resp.StatusCode, resp.Header.Get("Content-Type"), tc.contentType)
+			continue
+		}
+		if resp.Header.Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: got Vary %q, want Accept", tc.accept, resp.Header.Get("Vary"))
+		}
+		if tc.body != "" && body != tc.body {
+			t.Errorf("Accept %q: got %q, want %q", tc.accept, body, tc.body)
+		}
+	}
+
+	for _, accept := range []string{"image/png", "text/csv;q=0"} {
+		if resp, _ := list(accept); resp.StatusCode != http.StatusNotAcceptable {
+			t.Errorf("Accept %q: got %d, want 406", accept, resp.StatusCode)
+		}
+	}
+
+	t.Run("Failure before the first todo", func(t *testing.T) {
+		ts := httptest.NewServer(server.NewServer(todo.NewService(brokenList{memory.New()}), cfg, logger))
+		defer ts.Close()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/todos", nil)
+		req.Header.Set("Accept", "text/csv")
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusInternalServerError || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") {
+			t.Errorf("got %d with %q, want a 500 error", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	})
+}
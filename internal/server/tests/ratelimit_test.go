@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/ratelimit"
+	ratelimitmemory "github.com/jllovet/go-server-template/internal/ratelimit/memory"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestIntegration_RateLimit(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	service := todo.NewService(memory.New())
+	policy := ratelimit.Policy{Limits: map[string]ratelimit.Limit{
+		server.RouteGroupRead:  {Requests: 2, Per: time.Minute},
+		server.RouteGroupWrite: {Requests: 1, Per: time.Minute},
+	}}
+	srv := server.NewServer(service, cfg, logger,
+		server.WithRateLimit(ratelimitmemory.New(), policy),
+	)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	do := func(method, path, apiKey string) *http.Response {
+		t.Helper()
+		var body io.Reader
+		if method == http.MethodPost {
+			body = strings.NewReader(`{"title": "Limited"}`)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, body)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("1. Allowance is reported", func(t *testing.T) {
+		resp := do(http.MethodGet, "/api/v1/todos", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("got RateLimit-Limit %q, want 2", got)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != "1" {
+			t.Errorf("got RateLimit-Remaining %q, want 1", got)
+		}
+		if got := resp.Header.Get("RateLimit-Policy"); got != "2;w=60" {
+			t.Errorf("got RateLimit-Policy %q, want 2;w=60", got)
+		}
+	})
+
+	t.Run("2. Requests beyond the limit are refused", func(t *testing.T) {
+		_ = do(http.MethodGet, "/api/v1/todos", "")
+		resp := do(http.MethodGet, "/api/v1/todos/search?q=x", "")
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected 429 Too Many Requests, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("Retry-After"); got != "30" {
+			t.Errorf("got Retry-After %q, want 30", got)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != "0" {
+			t.Errorf("got RateLimit-Remaining %q, want 0", got)
+		}
+	})
+
+	t.Run("3. Route groups have separate buckets", func(t *testing.T) {
+		if resp := do(http.MethodPost, "/api/v1/todos", ""); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 Created, got %d", resp.StatusCode)
+		}
+		if resp := do(http.MethodPost, "/api/v1/todos", ""); resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected 429 Too Many Requests, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("4. Unverified API keys do not get their own bucket", func(t *testing.T) {
+		for _, key := range []string{"key-1", "key-2"} {
+			if resp := do(http.MethodGet, "/api/v1/todos", key); resp.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("key %s: expected 429 Too Many Requests, got %d", key, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("5. Health checks are not limited", func(t *testing.T) {
+		for range 5 {
+			if resp := do(http.MethodGet, "/healthz", ""); resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("6. Groups without a limit are not limited", func(t *testing.T) {
+		writesOnly := ratelimit.Policy{Limits: map[string]ratelimit.Limit{
+			server.RouteGroupWrite: {Requests: 1, Per: time.Minute},
+		}}
+		ts := httptest.NewServer(server.NewServer(service, cfg, logger,
+			server.WithRateLimit(ratelimitmemory.New(), writesOnly),
+		))
+		defer ts.Close()
+		for range 5 {
+			resp, err := ts.Client().Get(ts.URL + "/api/v1/todos")
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("RateLimit-Limit") != "" {
+				t.Fatalf("expected unlimited 200 OK, got %d with %v", resp.StatusCode, resp.Header)
+			}
+		}
+	})
+	t.Run("7. The policy can be replaced while serving", func(t *testing.T) {
+		if resp := do(http.MethodGet, "/api/v1/todos", ""); resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected 429 Too Many Requests, got %d", resp.StatusCode)
+		}
+		srv.SetRateLimitPolicy(ratelimit.Policy{Limits: map[string]ratelimit.Limit{
+			server.RouteGroupRead: {Requests: 100, Per: time.Second},
+		}})
+		// The client keeps its empty bucket, now refilled at the new rate.
+		time.Sleep(50 * time.Millisecond)
+		resp := do(http.MethodGet, "/api/v1/todos", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("RateLimit-Limit"); got != "100" {
+			t.Errorf("got RateLimit-Limit %q, want 100", got)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestIntegration_Readiness(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var dbErr error
+	service := todo.NewService(memory.New())
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger,
+		server.WithReadinessCheck("database", func(context.Context) error { return dbErr }),
+	))
+	defer ts.Close()
+
+	ready := func() (int, map[string]any) {
+		t.Helper()
+		resp, err := ts.Client().Get(ts.URL + "/ready")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.StatusCode, body
+	}
+
+	t.Run("1. Ready while checks pass", func(t *testing.T) {
+		status, body := ready()
+		if status != http.StatusOK || body["ready"] != true {
+			t.Errorf("got %d %v, want 200 ready", status, body)
+		}
+	})
+
+	t.Run("2. Not ready while a check fails", func(t *testing.T) {
+		dbErr = errors.New("circuit breaker open")
+		defer func() { dbErr = nil }()
+		status, body := ready()
+		if status != http.StatusServiceUnavailable || body["ready"] != false {
+			t.Fatalf("got %d %v, want 503 not ready", status, body)
+		}
+		checks, _ := body["checks"].(map[string]any)
+		if checks["database"] != "circuit breaker open" {
+			t.Errorf("got checks %v, want the database failure", checks)
+		}
+	})
+}
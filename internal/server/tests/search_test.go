@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestIntegration_Search(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repo := memory.New()
+	service := todo.NewService(repo, todo.WithSearcher(repo))
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger))
+	defer ts.Close()
+
+	ctx := context.Background()
+	for _, title := range []string{"Write report", "Review reports before the report deadline", "Buy milk"} {
+		if _, err := service.Create(ctx, title); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	search := func(query string) (*http.Response, []todo.SearchResult) {
+		t.Helper()
+		resp, err := ts.Client().Get(ts.URL + "/api/v1/todos/search?" + query)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var results []todo.SearchResult
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return resp, results
+	}
+
+	t.Run("Ranked results", func(t *testing.T) {
+		resp, results := search("q=" + url.QueryEscape("reports"))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		if len(results) != 2 || results[0].Todo.Title != "Review reports before the report deadline" {
+			t.Errorf("unexpected results %+v", results)
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		if _, results := search("q=report&limit=1"); len(results) != 1 {
+			t.Errorf("expected 1 result, got %d", len(results))
+		}
+		if resp, _ := search("q=report&limit=zero"); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 Bad Request, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("No matches", func(t *testing.T) {
+		resp, results := search("q=vacation")
+		if resp.StatusCode != http.StatusOK || results == nil || len(results) != 0 {
+			t.Errorf("expected empty list, got %d %+v", resp.StatusCode, results)
+		}
+	})
+
+	t.Run("Missing query", func(t *testing.T) {
+		if resp, _ := search(""); resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected 422 Unprocessable Entity, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/feed"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+type socketMsg struct {
+	Type  string      `json:"type"`
+	ID    string      `json:"id"`
+	Todo  *todo.Todo  `json:"todo"`
+	Event *todo.Event `json:"event"`
+	Error string      `json:"error"`
+}
+
+type socketClient struct {
+	conn *websocket.Conn
+}
+
+func dialSocket(t *testing.T, baseURL string) *socketClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(baseURL, "http")+"/api/v1/todos/ws", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	return &socketClient{conn: conn}
+}
+
+func (c *socketClient) send(t *testing.T, cmd map[string]any) {
+	t.Helper()
+	data, _ := json.Marshal(cmd)
+	if err := c.conn.Write(context.Background(), websocket.MessageText, data); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+}
+
+func (c *socketClient) read(t *testing.T) socketMsg {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, data, err := c.conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	var msg socketMsg
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("bad message %q: %v", data, err)
+	}
+	return msg
+}
+
+// call sends a command and returns its reply.
+func (c *socketClient) call(t *testing.T, cmd map[string]any) socketMsg {
+	t.Helper()
+	c.send(t, cmd)
+	msg := c.read(t)
+	if msg.ID != cmd["id"] {
+		t.Fatalf("expected reply to %v, got %+v", cmd["id"], msg)
+	}
+	return msg
+}
+
+func TestIntegration_TodoSocket(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080, WSPingInterval: 20 * time.Millisecond}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	broker := feed.New(100)
+	service := todo.NewService(memory.New(), todo.WithPublisher(broker))
+	srv := server.NewServer(service, cfg, logger, server.WithFeed(broker))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	alice := dialSocket(t, ts.URL)
+	bob := dialSocket(t, ts.URL)
+	var created todo.Todo
+
+	t.Run("1. Commands go through the service and are broadcast", func(t *testing.T) {
+		alice.call(t, map[string]any{"id": "a1", "type": "subscribe"})
+		bob.call(t, map[string]any{"id": "b1", "type": "subscribe"})
+
+		reply := alice.call(t, map[string]any{"id": "a2", "type": "create", "title": "Pair on it"})
+		if reply.Type != "result" || reply.Todo == nil || reply.Todo.Title != "Pair on it" {
+			t.Fatalf("unexpected reply %+v", reply)
+		}
+		created = *reply.Todo
+
+		ev := bob.read(t)
+		if ev.Type != "event" || ev.Event.Type != todo.EventCreated || ev.Event.Todo.ID != created.ID {
+			t.Fatalf("bob expected created event, got %+v", ev)
+		}
+		if _, err := service.Get(context.Background(), created.ID); err != nil {
+			t.Errorf("todo not persisted: %v", err)
+		}
+	})
+
+	t.Run("2. Own changes are not echoed", func(t *testing.T) {
+		bob.call(t, map[string]any{"id": "b2", "type": "complete", "todo_id": created.ID, "completed": true})
+
+		// The next message alice sees is bob's change, not her own create.
+		ev := alice.read(t)
+		if ev.Type != "event" || ev.Event.Type != todo.EventCompleted {
+			t.Fatalf("alice expected completed event, got %+v", ev)
+		}
+	})
+
+	t.Run("3. Subscriptions filter by todo", func(t *testing.T) {
+		other, _ := service.Create(context.Background(), "Someone else's")
+		alice.read(t) // created event for other
+		bob.read(t)
+
+		bob.call(t, map[string]any{"id": "b3", "type": "unsubscribe"})
+		bob.call(t, map[string]any{"id": "b4", "type": "subscribe", "todo_ids": []string{created.ID}})
+
+		_, _ = service.Update(context.Background(), other.ID, "Not for bob")
+		_, _ = service.Update(context.Background(), created.ID, "For bob")
+		ev := bob.read(t)
+		if ev.Event == nil || ev.Event.Todo.ID != created.ID {
+			t.Fatalf("bob expected only events for %s, got %+v", created.ID, ev)
+		}
+	})
+
+	t.Run("4. Errors are reported per command", func(t *testing.T) {
+		if reply := bob.call(t, map[string]any{"id": "b5", "type": "update", "todo_id": "missing", "title": "x"}); reply.Type != "error" {
+			t.Errorf("expected error reply, got %+v", reply)
+		}
+		if reply := bob.call(t, map[string]any{"id": "b6", "type": "explode"}); reply.Type != "error" {
+			t.Errorf("expected error reply, got %+v", reply)
+		}
+	})
+
+	t.Run("5. Keepalive pings are answered", func(t *testing.T) {
+		// Reading services pongs; stay connected across several ping intervals.
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if _, _, err := bob.conn.Read(ctx); websocket.CloseStatus(err) != -1 {
+			t.Fatalf("connection closed during keepalive: %v", err)
+		}
+		bob = dialSocket(t, ts.URL) // the timed-out read closed the old connection
+		if reply := bob.call(t, map[string]any{"id": "b7", "type": "subscribe"}); reply.Type != "result" {
+			t.Errorf("expected result, got %+v", reply)
+		}
+	})
+
+	t.Run("6. Feed shutdown closes sockets", func(t *testing.T) {
+		carol := dialSocket(t, ts.URL)
+		carol.call(t, map[string]any{"id": "c1", "type": "subscribe"})
+		broker.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_, _, err := carol.conn.Read(ctx)
+		if got := websocket.CloseStatus(err); got != websocket.StatusGoingAway {
+			t.Errorf("expected going away close, got %v (%v)", got, err)
+		}
+	})
+}
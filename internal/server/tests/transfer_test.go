@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/internal/todo/transfer"
+)
+
+func TestIntegration_Transfer(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	service := todo.NewService(memory.New())
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger))
+	defer ts.Close()
+
+	importTodos := func(query, contentType, body string) (*http.Response, transfer.Report) {
+		t.Helper()
+		resp, err := ts.Client().Post(ts.URL+"/api/v1/todos:import"+query, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var report transfer.Report
+		if resp.Header.Get("Content-Type") == "application/json" {
+			json.NewDecoder(resp.Body).Decode(&report)
+		}
+		return resp, report
+	}
+
+	t.Run("1. Import CSV", func(t *testing.T) {
+		resp, report := importTodos("", "text/csv; charset=utf-8", "id,title,completed\na,First,false\nb,Second,true\n")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		if report.Created != 2 || report.Failed != 0 {
+			t.Errorf("got report %+v, want 2 created", report)
+		}
+	})
+
+	t.Run("2. Conflicts", func(t *testing.T) {
+		body := `{"id": "c", "title": "Third"}` + "\n" + `{"id": "a", "title": "First again"}` + "\n"
+		resp, report := importTodos("?format=ndjson&dry_run=true", "", body)
+		if resp.StatusCode != http.StatusConflict || report.Created != 1 || len(report.Errors) != 1 {
+			t.Errorf("got %d and %+v, want 409 after 1 todo", resp.StatusCode, report)
+		}
+		resp, report = importTodos("?format=ndjson&on_conflict=overwrite", "", body)
+		if resp.StatusCode != http.StatusOK || report.Created != 1 || report.Overwritten != 1 {
+			t.Errorf("got %d and %+v, want 1 created and 1 overwritten", resp.StatusCode, report)
+		}
+		if got, _ := service.Get(t.Context(), "a"); got.Title != "First again" {
+			t.Errorf("got title %q, want the overwritten one", got.Title)
+		}
+	})
+
+	t.Run("3. Bad requests", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			query, contentType, body string
+			want                     int
+		}{
+			"unknown format":  {"?format=xml", "", "", http.StatusBadRequest},
+			"no format":       {"", "text/plain", "", http.StatusUnsupportedMediaType},
+			"unknown policy":  {"?on_conflict=merge", "application/json", "[]", http.StatusBadRequest},
+			"malformed":       {"", "application/json", `[{"title": `, http.StatusBadRequest},
+			"not an array":    {"", "application/json", `{"title": "One"}`, http.StatusBadRequest},
+			"invalid dry run": {"?dry_run=maybe", "application/json", "[]", http.StatusBadRequest},
+			"invalid ids":     {"?ids=some", "application/json", "[]", http.StatusBadRequest},
+		} {
+			if resp, _ := importTodos(tc.query, tc.contentType, tc.body); resp.StatusCode != tc.want {
+				t.Errorf("%s: got %d, want %d", name, resp.StatusCode, tc.want)
+			}
+		}
+	})
+
+	t.Run("4. Export", func(t *testing.T) {
+		resp, err := ts.Client().Get(ts.URL + "/api/v1/todos:export?format=csv")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/csv" {
+			t.Fatalf("got %d with %q, want 200 with text/csv", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if got := resp.Header.Get("Content-Disposition"); got != "attachment; filename=todos.csv" {
+			t.Errorf("got Content-Disposition %q", got)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if lines := strings.Split(strings.TrimSpace(string(body)), "\n"); len(lines) != 4 || lines[0] != "id,title,completed" {
+			t.Errorf("got %q, want a header and 3 todos", body)
+		}
+
+		resp, err = ts.Client().Get(ts.URL + "/api/v1/todos:export?format=yaml")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for an unknown format, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("5. Import progress is logged", func(t *testing.T) {
+		body := strings.Repeat(`{"title": "Many"}`+"\n", transfer.ProgressInterval)
+		resp, report := importTodos("?format=ndjson&ids=new&dry_run=true", "", body)
+		if resp.StatusCode != http.StatusOK || report.Read != transfer.ProgressInterval {
+			t.Fatalf("got %d and %+v, want 200 after %d todos", resp.StatusCode, report, transfer.ProgressInterval)
+		}
+		progress := fmt.Sprintf("read=%d failed=0", transfer.ProgressInterval)
+		if out := logs.String(); !strings.Contains(out, `msg="importing todos"`) || !strings.Contains(out, progress) {
+			t.Errorf("logs do not report progress: %s", out)
+		}
+	})
+}
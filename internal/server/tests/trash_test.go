@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestIntegration_Trash(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	service := todo.NewService(memory.New())
+	ts := httptest.NewServer(server.NewServer(service, cfg, logger))
+	defer ts.Close()
+
+	request := func(method, path string, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	decode := func(resp *http.Response, v any) {
+		t.Helper()
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+
+	var created todo.Todo
+	decode(request("POST", "/api/v1/todos", `{"title": "Mistake"}`), &created)
+
+	t.Run("1. Delete moves to trash", func(t *testing.T) {
+		if resp := request("DELETE", "/api/v1/todos/"+created.ID, ""); resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected 204 No Content, got %d", resp.StatusCode)
+		}
+		if resp := request("GET", "/api/v1/todos/"+created.ID, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 Not Found, got %d", resp.StatusCode)
+		}
+		var todos []todo.Todo
+		decode(request("GET", "/api/v1/todos", ""), &todos)
+		if len(todos) != 0 {
+			t.Errorf("expected empty list, got %+v", todos)
+		}
+
+		var trash []todo.Todo
+		decode(request("GET", "/api/v1/trash", ""), &trash)
+		if len(trash) != 1 || trash[0].ID != created.ID || trash[0].DeletedAt == nil {
+			t.Errorf("expected deleted todo in trash, got %+v", trash)
+		}
+	})
+
+	t.Run("2. Deleting twice is not found", func(t *testing.T) {
+		if resp := request("DELETE", "/api/v1/todos/"+created.ID, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 Not Found, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("3. Restore", func(t *testing.T) {
+		resp := request("POST", "/api/v1/todos/"+created.ID+"/restore", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		var restored todo.Todo
+		decode(resp, &restored)
+		if restored.DeletedAt != nil || restored.Title != "Mistake" {
+			t.Errorf("unexpected restored todo %+v", restored)
+		}
+		if resp := request("GET", "/api/v1/todos/"+created.ID, ""); resp.StatusCode != http.StatusOK {
+			t.Errorf("expected 200 OK after restore, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("4. Restore outside trash", func(t *testing.T) {
+		if resp := request("POST", "/api/v1/todos/"+created.ID+"/restore", ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 Not Found, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/config"
+	"github.com/jllovet/go-server-template/internal/server"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/internal/webhook"
+	webhookmemory "github.com/jllovet/go-server-template/internal/webhook/memory"
+)
+
+func TestIntegration_Webhooks(t *testing.T) {
+	// Receiver standing in for another system that reacts to completed todos.
+	received := make(chan *http.Request, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		if !webhook.Verify("integration-secret", r.Header.Get(webhook.HeaderSignature), ts, body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		received <- r
+	}))
+	defer receiver.Close()
+
+	cfg := &config.Config{Host: "localhost", Port: 8080}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store := webhookmemory.New()
+	dispatcher := webhook.NewDispatcher(store, webhook.Options{PollInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	service := todo.NewService(memory.New(), todo.WithPublisher(dispatcher))
+	srv := server.NewServer(service, cfg, logger, server.WithWebhooks(webhook.NewService(store, dispatcher)))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	request := func(method, path string, body any) *http.Response {
+		t.Helper()
+		var bodyReader io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			bodyReader = bytes.NewReader(b)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bodyReader)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	var sub struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}
+	t.Run("1. Subscribe", func(t *testing.T) {
+		resp := request("POST", "/api/v1/webhooks", map[string]any{
+			"url":    receiver.URL,
+			"events": []string{"todo.completed"},
+			"secret": "integration-secret",
+		})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 Created, got %d", resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if sub.Secret != "integration-secret" {
+			t.Errorf("expected secret in creation response, got %q", sub.Secret)
+		}
+	})
+
+	t.Run("2. Secret is not listed", func(t *testing.T) {
+		resp := request("GET", "/api/v1/webhooks/"+sub.ID, nil)
+		var got map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&got)
+		if _, ok := got["secret"]; ok {
+			t.Errorf("secret leaked in GET response: %v", got)
+		}
+	})
+
+	t.Run("3. Invalid subscription", func(t *testing.T) {
+		resp := request("POST", "/api/v1/webhooks", map[string]any{"url": "not a url"})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 Bad Request, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("4. Completing a todo delivers the event", func(t *testing.T) {
+		resp := request("POST", "/api/v1/todos", map[string]string{"title": "Notify me"})
+		var created todo.Todo
+		_ = json.NewDecoder(resp.Body).Decode(&created)
+		request("POST", "/api/v1/todos/"+created.ID+"/complete", nil)
+
+		select {
+		case r := <-received:
+			if got := r.Header.Get(webhook.HeaderEvent); got != "todo.completed" {
+				t.Errorf("expected todo.completed delivery, got %q", got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for webhook delivery")
+		}
+	})
+
+	var deliveryID string
+	t.Run("5. Delivery history", func(t *testing.T) {
+		var deliveries []webhook.Delivery
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			resp := request("GET", "/api/v1/webhooks/"+sub.ID+"/deliveries", nil)
+			deliveries = nil
+			_ = json.NewDecoder(resp.Body).Decode(&deliveries)
+			if len(deliveries) == 1 && deliveries[0].Status == webhook.StatusSucceeded {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected one succeeded delivery, got %+v", deliveries)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		deliveryID = deliveries[0].ID
+	})
+
+	t.Run("6. Redeliver", func(t *testing.T) {
+		resp := request("POST", "/api/v1/webhooks/"+sub.ID+"/deliveries/"+deliveryID+"/redeliver", nil)
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("expected 202 Accepted, got %d", resp.StatusCode)
+		}
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for redelivery")
+		}
+	})
+
+	t.Run("7. Unsubscribe", func(t *testing.T) {
+		if resp := request("DELETE", "/api/v1/webhooks/"+sub.ID, nil); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("expected 204 No Content, got %d", resp.StatusCode)
+		}
+		if resp := request("GET", "/api/v1/webhooks/"+sub.ID, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 Not Found, got %d", resp.StatusCode)
+		}
+	})
+}
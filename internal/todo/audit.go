@@ -0,0 +1,55 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrAuditDisabled is returned by Service.History when the service was
+// built without an AuditRepository.
+var ErrAuditDisabled = errors.New("audit log is not enabled")
+
+// AnonymousActor is recorded when a change is made without an authenticated actor.
+const AnonymousActor = "anonymous"
+
+// AuditEntry records a single mutation made through the Service.
+type AuditEntry struct {
+	ID     string    `json:"id"`
+	TodoID string    `json:"todo_id"`
+	Action EventType `json:"action"`
+	// Actor is who made the change, or AnonymousActor.
+	Actor string `json:"actor"`
+	// RequestID correlates the entry with the request logs.
+	RequestID string `json:"request_id,omitempty"`
+	// Before is nil for creations; After is nil for purges.
+	Before *Todo     `json:"before"`
+	After  *Todo     `json:"after"`
+	At     time.Time `json:"at"`
+}
+
+// AuditRepository is an append-only store of AuditEntries.
+// In Hexagonal Architecture, this is a "Driven Port".
+type AuditRepository interface {
+	// Append takes part in the Repository unit of work of ctx, if any,
+	// when both come from the same adapter.
+	Append(ctx context.Context, e AuditEntry) error
+	// FindByTodoID returns the entries for a todo, oldest first.
+	FindByTodoID(ctx context.Context, todoID string) ([]AuditEntry, error)
+}
+
+type actorKey struct{}
+
+// WithActor records who is acting in ctx. Authentication middleware calls it
+// once the caller is known.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by WithActor, or AnonymousActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
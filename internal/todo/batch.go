@@ -0,0 +1,121 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// DefaultMaxBatchSize is the Batch size limit used unless WithMaxBatchSize
+// sets another.
+const DefaultMaxBatchSize = 100
+
+// ErrBatchTooLarge is returned when a Batch has more operations than allowed.
+var ErrBatchTooLarge = errors.New("batch too large")
+
+// BatchOp names the kind of a BatchOperation.
+type BatchOp string
+
+const (
+	BatchCreate   BatchOp = "create"
+	BatchUpdate   BatchOp = "update"
+	BatchComplete BatchOp = "complete"
+	BatchDelete   BatchOp = "delete"
+)
+
+// BatchOperation is one change in a Batch. Create uses Title; update uses
+// ID and Title; complete uses ID and Completed, which defaults to true;
+// delete uses ID.
+type BatchOperation struct {
+	Op        BatchOp `json:"op"`
+	ID        string  `json:"id,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	Completed *bool   `json:"completed,omitempty"`
+}
+
+// BatchResult is the outcome of one BatchOperation: the todo it produced,
+// if any, or the error that made it fail.
+type BatchResult struct {
+	Todo *Todo
+	Err  error
+}
+
+// BatchError reports which operation made an atomic Batch fail.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+func (s *service) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: batch has no operations", ErrInvalid)
+	}
+	if s.maxBatchSize > 0 && len(ops) > s.maxBatchSize {
+		return nil, fmt.Errorf("%w: %d operations, at most %d allowed", ErrBatchTooLarge, len(ops), s.maxBatchSize)
+	}
+
+	logger.FromContext(ctx).Info("applying batch", "operations", len(ops), "atomic", atomic)
+	if !atomic {
+		results := make([]BatchResult, len(ops))
+		for i, op := range ops {
+			results[i] = s.apply(ctx, op)
+		}
+		return results, nil
+	}
+
+	results := make([]BatchResult, len(ops))
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		for i, op := range ops {
+			results[i] = s.apply(ctx, op)
+			if results[i].Err != nil {
+				return &BatchError{Index: i, Err: results[i].Err}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.FromContext(ctx).Error("batch failed", "error", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// apply runs a single batch operation through the regular service methods.
+func (s *service) apply(ctx context.Context, op BatchOperation) BatchResult {
+	var (
+		t   Todo
+		err error
+	)
+	switch op.Op {
+	case BatchCreate:
+		t, err = s.Create(ctx, op.Title)
+	case BatchUpdate:
+		t, err = s.Update(ctx, op.ID, op.Title)
+	case BatchComplete:
+		completed := true
+		if op.Completed != nil {
+			completed = *op.Completed
+		}
+		t, err = s.SetCompleted(ctx, op.ID, completed)
+	case BatchDelete:
+		if err = s.Delete(ctx, op.ID); err == nil {
+			return BatchResult{}
+		}
+	default:
+		err = fmt.Errorf("%w: unknown operation %q", ErrInvalid, op.Op)
+	}
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+	return BatchResult{Todo: &t}
+}
@@ -0,0 +1,298 @@
+// Package cache provides a read-through caching decorator for any
+// todo.Repository.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// Options configures a Repository.
+type Options struct {
+	// Size is the most todos kept; the least recently used are evicted.
+	Size int
+	// TTL is how long a found todo is kept.
+	TTL time.Duration
+	// NegativeTTL is how long a not-found result is kept. Zero disables
+	// negative caching.
+	NegativeTTL time.Duration
+}
+
+// Stats are the cache's counters since it was created.
+type Stats struct {
+	Hits         uint64
+	NegativeHits uint64
+	Misses       uint64
+	Evictions    uint64
+	Size         int
+}
+
+// Repository caches FindByID in front of another todo.Repository. Writes
+// made through it invalidate the todos they touch; writes made elsewhere
+// (another instance, say) must be reported with Invalidate or Purge.
+// Everything but FindByID is passed straight through.
+type Repository struct {
+	next  todo.Repository
+	opts  Options
+	group singleflight.Group
+
+	// mu protects the LRU list, its index and gen.
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// gen counts invalidations. A load only stores its result if no
+	// invalidation happened while it ran, since it may have read a value
+	// that the invalidation was meant to remove.
+	gen uint64
+
+	hits, negativeHits, misses, evictions atomic.Uint64
+}
+
+// entry is a cached lookup: a todo, or its absence when found is false.
+type entry struct {
+	id      string
+	todo    todo.Todo
+	found   bool
+	expires time.Time
+}
+
+// New wraps next in a cache.
+func New(next todo.Repository, opts Options) *Repository {
+	if opts.Size <= 0 {
+		opts.Size = 1000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	return &Repository{
+		next:    next,
+		opts:    opts,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// txKey marks contexts inside WithinTx; its value collects the IDs written.
+type txKey struct{}
+
+type txWrites struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func writesFromContext(ctx context.Context) *txWrites {
+	w, _ := ctx.Value(txKey{}).(*txWrites)
+	return w
+}
+
+// WithinTx runs fn in a unit of work of the wrapped repository. Reads in it
+// bypass the cache, which cannot see uncommitted writes, and the todos it
+// writes are invalidated once it ends.
+func (r *Repository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if writesFromContext(ctx) != nil {
+		return r.next.WithinTx(ctx, fn)
+	}
+	writes := &txWrites{}
+	err := r.next.WithinTx(ctx, func(ctx context.Context) error {
+		return fn(context.WithValue(ctx, txKey{}, writes))
+	})
+	r.Invalidate(writes.ids...)
+	return err
+}
+
+// FindByID returns the cached todo or loads it, sharing one load between
+// concurrent callers.
+func (r *Repository) FindByID(ctx context.Context, id string) (todo.Todo, error) {
+	if writesFromContext(ctx) != nil {
+		return r.next.FindByID(ctx, id)
+	}
+	if e, ok := r.get(id); ok {
+		if !e.found {
+			r.negativeHits.Add(1)
+			return todo.Todo{}, todo.ErrNotFound
+		}
+		r.hits.Add(1)
+		return e.todo, nil
+	}
+	r.misses.Add(1)
+
+	v, err, _ := r.group.Do(id, func() (any, error) {
+		gen := r.generation()
+		// The load is shared, so it must not fail because the caller that
+		// happened to start it went away. It is kept for a while, so it
+		// must not come from a lagging replica either.
+		t, err := r.next.FindByID(todo.WithPrimaryReads(context.WithoutCancel(ctx)), id)
+		switch {
+		case err == nil:
+			r.put(gen, entry{id: id, todo: t, found: true, expires: time.Now().Add(r.opts.TTL)})
+		case errors.Is(err, todo.ErrNotFound) && r.opts.NegativeTTL > 0:
+			r.put(gen, entry{id: id, expires: time.Now().Add(r.opts.NegativeTTL)})
+		}
+		return t, err
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	return v.(todo.Todo), nil
+}
+
+// Save writes through and invalidates the todo.
+func (r *Repository) Save(ctx context.Context, t todo.Todo) error {
+	err := r.next.Save(ctx, t)
+	r.written(ctx, t.ID)
+	return err
+}
+
+// Delete writes through and invalidates the todo.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	err := r.next.Delete(ctx, id)
+	r.written(ctx, id)
+	return err
+}
+
+// PurgeDeleted writes through and invalidates the purged todos.
+func (r *Repository) PurgeDeleted(ctx context.Context, before time.Time) ([]todo.Todo, error) {
+	purged, err := r.next.PurgeDeleted(ctx, before)
+	for _, t := range purged {
+		r.written(ctx, t.ID)
+	}
+	return purged, err
+}
+
+func (r *Repository) FindAll(ctx context.Context) ([]todo.Todo, error) {
+	return r.next.FindAll(ctx)
+}
+
+func (r *Repository) ForEach(ctx context.Context, fn func(todo.Todo) error) error {
+	return r.next.ForEach(ctx, fn)
+}
+
+func (r *Repository) FindDeletedByID(ctx context.Context, id string) (todo.Todo, error) {
+	return r.next.FindDeletedByID(ctx, id)
+}
+
+func (r *Repository) FindDeleted(ctx context.Context) ([]todo.Todo, error) {
+	return r.next.FindDeleted(ctx)
+}
+
+// written invalidates id now, or when the unit of work of ctx ends.
+func (r *Repository) written(ctx context.Context, id string) {
+	if w := writesFromContext(ctx); w != nil {
+		w.mu.Lock()
+		w.ids = append(w.ids, id)
+		w.mu.Unlock()
+		return
+	}
+	r.Invalidate(id)
+}
+
+// Invalidate drops the given todos from the cache.
+func (r *Repository) Invalidate(ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	for _, id := range ids {
+		if el, ok := r.entries[id]; ok {
+			r.lru.Remove(el)
+			delete(r.entries, id)
+		}
+	}
+}
+
+// Purge empties the cache.
+func (r *Repository) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	r.lru.Init()
+	clear(r.entries)
+}
+
+// Stats returns the cache's counters.
+func (r *Repository) Stats() Stats {
+	r.mu.Lock()
+	size := r.lru.Len()
+	r.mu.Unlock()
+	return Stats{
+		Hits:         r.hits.Load(),
+		NegativeHits: r.negativeHits.Load(),
+		Misses:       r.misses.Load(),
+		Evictions:    r.evictions.Load(),
+		Size:         size,
+	}
+}
+
+// LogStats logs the cache's counters every interval until ctx is cancelled.
+func (r *Repository) LogStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s := r.Stats()
+			logger.FromContext(ctx).Info("todo cache stats",
+				"hits", s.Hits,
+				"negative_hits", s.NegativeHits,
+				"misses", s.Misses,
+				"evictions", s.Evictions,
+				"size", s.Size,
+			)
+		}
+	}
+}
+
+func (r *Repository) generation() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.gen
+}
+
+// get returns the unexpired entry for id, marking it recently used.
+func (r *Repository) get(id string) (entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	el, ok := r.entries[id]
+	if !ok {
+		return entry{}, false
+	}
+	e := el.Value.(entry)
+	if !time.Now().Before(e.expires) {
+		r.lru.Remove(el)
+		delete(r.entries, id)
+		return entry{}, false
+	}
+	r.lru.MoveToFront(el)
+	return e, true
+}
+
+// put stores e unless the cache was invalidated since gen was read.
+func (r *Repository) put(gen uint64, e entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gen != gen {
+		return
+	}
+	if el, ok := r.entries[e.id]; ok {
+		el.Value = e
+		r.lru.MoveToFront(el)
+		return
+	}
+	r.entries[e.id] = r.lru.PushFront(e)
+	for r.lru.Len() > r.opts.Size {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.entries, oldest.Value.(entry).id)
+		r.evictions.Add(1)
+	}
+}
@@ -0,0 +1,214 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/cache"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+// countingRepo counts the lookups that reach the underlying repository and
+// can hold them until release is closed.
+type countingRepo struct {
+	todo.Repository
+	finds   atomic.Int64
+	release chan struct{}
+}
+
+func (r *countingRepo) FindByID(ctx context.Context, id string) (todo.Todo, error) {
+	r.finds.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.Repository.FindByID(ctx, id)
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(opts cache.Options) (*cache.Repository, *countingRepo) {
+		next := &countingRepo{Repository: memory.New()}
+		_ = next.Save(ctx, todo.Todo{ID: "1", Title: "One"})
+		_ = next.Save(ctx, todo.Todo{ID: "2", Title: "Two"})
+		_ = next.Save(ctx, todo.Todo{ID: "3", Title: "Three"})
+		return cache.New(next, opts), next
+	}
+
+	t.Run("Hits and Misses", func(t *testing.T) {
+		repo, next := setup(cache.Options{})
+		for range 3 {
+			found, err := repo.FindByID(ctx, "1")
+			if err != nil {
+				t.Fatalf("FindByID() error = %v", err)
+			}
+			if found.Title != "One" {
+				t.Errorf("got title %q, want %q", found.Title, "One")
+			}
+		}
+		if n := next.finds.Load(); n != 1 {
+			t.Errorf("got %d underlying lookups, want 1", n)
+		}
+		if s := repo.Stats(); s.Hits != 2 || s.Misses != 1 || s.Size != 1 {
+			t.Errorf("got stats %+v, want 2 hits, 1 miss, size 1", s)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		repo, next := setup(cache.Options{TTL: 10 * time.Millisecond})
+		_, _ = repo.FindByID(ctx, "1")
+		time.Sleep(20 * time.Millisecond)
+		_, _ = repo.FindByID(ctx, "1")
+		if n := next.finds.Load(); n != 2 {
+			t.Errorf("got %d underlying lookups, want 2 after expiry", n)
+		}
+	})
+
+	t.Run("LRU Eviction", func(t *testing.T) {
+		repo, next := setup(cache.Options{Size: 2})
+		_, _ = repo.FindByID(ctx, "1")
+		_, _ = repo.FindByID(ctx, "2")
+		_, _ = repo.FindByID(ctx, "1") // 2 is now least recently used
+		_, _ = repo.FindByID(ctx, "3")
+		next.finds.Store(0)
+
+		_, _ = repo.FindByID(ctx, "1")
+		_, _ = repo.FindByID(ctx, "3")
+		if n := next.finds.Load(); n != 0 {
+			t.Errorf("got %d underlying lookups, want recently used todos cached", n)
+		}
+		_, _ = repo.FindByID(ctx, "2")
+		if n := next.finds.Load(); n != 1 {
+			t.Errorf("got %d underlying lookups, want evicted todo reloaded", n)
+		}
+		if s := repo.Stats(); s.Evictions != 2 || s.Size != 2 {
+			t.Errorf("got stats %+v, want 2 evictions, size 2", s)
+		}
+	})
+
+	t.Run("Negative Caching", func(t *testing.T) {
+		repo, next := setup(cache.Options{NegativeTTL: time.Minute})
+		for range 2 {
+			if _, err := repo.FindByID(ctx, "missing"); !errors.Is(err, todo.ErrNotFound) {
+				t.Fatalf("FindByID() error = %v, want %v", err, todo.ErrNotFound)
+			}
+		}
+		if n := next.finds.Load(); n != 1 {
+			t.Errorf("got %d underlying lookups, want 1", n)
+		}
+		if s := repo.Stats(); s.NegativeHits != 1 {
+			t.Errorf("got stats %+v, want 1 negative hit", s)
+		}
+
+		// Creating the todo replaces the cached miss.
+		_ = repo.Save(ctx, todo.Todo{ID: "missing", Title: "Found"})
+		if found, err := repo.FindByID(ctx, "missing"); err != nil || found.Title != "Found" {
+			t.Errorf("FindByID() = %+v, %v, want created todo", found, err)
+		}
+	})
+
+	t.Run("Negative Caching Disabled", func(t *testing.T) {
+		repo, next := setup(cache.Options{})
+		_, _ = repo.FindByID(ctx, "missing")
+		_, _ = repo.FindByID(ctx, "missing")
+		if n := next.finds.Load(); n != 2 {
+			t.Errorf("got %d underlying lookups, want 2", n)
+		}
+	})
+
+	t.Run("Invalidation", func(t *testing.T) {
+		repo, _ := setup(cache.Options{})
+		_, _ = repo.FindByID(ctx, "1")
+		if err := repo.Save(ctx, todo.Todo{ID: "1", Title: "Renamed"}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if found, _ := repo.FindByID(ctx, "1"); found.Title != "Renamed" {
+			t.Errorf("got title %q after Save, want %q", found.Title, "Renamed")
+		}
+		if err := repo.Delete(ctx, "1"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := repo.FindByID(ctx, "1"); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("FindByID() after Delete error = %v, want %v", err, todo.ErrNotFound)
+		}
+	})
+
+	t.Run("Invalidate and Purge", func(t *testing.T) {
+		repo, next := setup(cache.Options{})
+		_, _ = repo.FindByID(ctx, "1")
+		_, _ = repo.FindByID(ctx, "2")
+		repo.Invalidate("1")
+		if s := repo.Stats(); s.Size != 1 {
+			t.Errorf("got size %d after Invalidate, want 1", s.Size)
+		}
+		repo.Purge()
+		if s := repo.Stats(); s.Size != 0 {
+			t.Errorf("got size %d after Purge, want 0", s.Size)
+		}
+		next.finds.Store(0)
+		_, _ = repo.FindByID(ctx, "2")
+		if n := next.finds.Load(); n != 1 {
+			t.Errorf("got %d underlying lookups, want 1 after Purge", n)
+		}
+	})
+
+	t.Run("Singleflight", func(t *testing.T) {
+		repo, next := setup(cache.Options{})
+		next.release = make(chan struct{})
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := repo.FindByID(ctx, "1"); err != nil {
+					t.Errorf("FindByID() error = %v", err)
+				}
+			}()
+		}
+		// Give the callers time to join the first one's lookup.
+		time.Sleep(20 * time.Millisecond)
+		close(next.release)
+		wg.Wait()
+		if n := next.finds.Load(); n != 1 {
+			t.Errorf("got %d underlying lookups, want 1", n)
+		}
+	})
+
+	t.Run("WithinTx", func(t *testing.T) {
+		repo, _ := setup(cache.Options{})
+		_, _ = repo.FindByID(ctx, "1")
+		errRollback := errors.New("rollback")
+
+		err := repo.WithinTx(ctx, func(ctx context.Context) error {
+			if err := repo.Save(ctx, todo.Todo{ID: "1", Title: "Uncommitted"}); err != nil {
+				return err
+			}
+			// Reads inside the unit of work see its own writes.
+			if found, _ := repo.FindByID(ctx, "1"); found.Title != "Uncommitted" {
+				t.Errorf("got title %q inside tx, want %q", found.Title, "Uncommitted")
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("WithinTx() error = %v, want %v", err, errRollback)
+		}
+		if found, _ := repo.FindByID(ctx, "1"); found.Title != "One" {
+			t.Errorf("got title %q after rollback, want %q", found.Title, "One")
+		}
+
+		err = repo.WithinTx(ctx, func(ctx context.Context) error {
+			return repo.Save(ctx, todo.Todo{ID: "1", Title: "Committed"})
+		})
+		if err != nil {
+			t.Fatalf("WithinTx() error = %v", err)
+		}
+		if found, _ := repo.FindByID(ctx, "1"); found.Title != "Committed" {
+			t.Errorf("got title %q after commit, want %q", found.Title, "Committed")
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package todo
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Repositories may serve reads from replicas that lag behind the primary.
+// The helpers below let callers ask for consistent reads without knowing
+// whether a replica is in use.
+
+type writesKey struct{}
+
+type primaryKey struct{}
+
+// writeTracker remembers when a context last wrote.
+type writeTracker struct {
+	last atomic.Int64
+}
+
+// TrackWrites returns a context in which RecordWrite remembers writes, so
+// that reads made with it shortly after one see the written data. The HTTP
+// server installs one per request.
+func TrackWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(writesKey{}).(*writeTracker); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, writesKey{}, &writeTracker{})
+}
+
+// RecordWrite notes that ctx has just written, if it tracks writes.
+// Repositories call it.
+func RecordWrite(ctx context.Context) {
+	if w, ok := ctx.Value(writesKey{}).(*writeTracker); ok {
+		w.last.Store(time.Now().UnixNano())
+	}
+}
+
+// WithPrimaryReads returns a context whose reads must see every committed
+// write, such as one loading a todo that another instance just changed.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// NeedsPrimary reports whether reads made with ctx must go to the primary:
+// because it asked to with WithPrimaryReads, or wrote within window.
+func NeedsPrimary(ctx context.Context, window time.Duration) bool {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return true
+	}
+	w, ok := ctx.Value(writesKey{}).(*writeTracker)
+	if !ok {
+		return false
+	}
+	last := w.last.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < window
+}
@@ -0,0 +1,49 @@
+package todo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+func TestNeedsPrimary(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Untracked", func(t *testing.T) {
+		todo.RecordWrite(ctx)
+		if todo.NeedsPrimary(ctx, time.Minute) {
+			t.Error("NeedsPrimary() = true for a context that does not track writes")
+		}
+	})
+
+	t.Run("Read Your Writes", func(t *testing.T) {
+		ctx := todo.TrackWrites(ctx)
+		if todo.NeedsPrimary(ctx, time.Minute) {
+			t.Error("NeedsPrimary() = true before any write")
+		}
+		todo.RecordWrite(ctx)
+		if !todo.NeedsPrimary(ctx, time.Minute) {
+			t.Error("NeedsPrimary() = false right after a write")
+		}
+		time.Sleep(10 * time.Millisecond)
+		if todo.NeedsPrimary(ctx, 5*time.Millisecond) {
+			t.Error("NeedsPrimary() = true after the window")
+		}
+
+		// Derived contexts share the tracker.
+		child, cancel := context.WithCancel(todo.TrackWrites(ctx))
+		defer cancel()
+		todo.RecordWrite(child)
+		if !todo.NeedsPrimary(ctx, time.Minute) {
+			t.Error("NeedsPrimary() = false after a write through a derived context")
+		}
+	})
+
+	t.Run("Primary Reads", func(t *testing.T) {
+		if !todo.NeedsPrimary(todo.WithPrimaryReads(ctx), 0) {
+			t.Error("NeedsPrimary() = false with WithPrimaryReads")
+		}
+	})
+}
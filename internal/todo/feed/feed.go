@@ -0,0 +1,181 @@
+// Package feed provides an in-process stream of todo change events that any
+// number of subscribers can follow, with a bounded replay buffer so that a
+// subscriber that briefly disconnects can resume where it left off.
+package feed
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// ErrClosed is returned by Subscribe once the Broker has been closed.
+var ErrClosed = errors.New("feed closed")
+
+// subscriberBuffer is how many entries a subscriber may fall behind before it
+// is disconnected. A disconnected subscriber can resume from the replay buffer.
+const subscriberBuffer = 64
+
+// Entry is an event together with its position in the feed.
+type Entry struct {
+	// ID identifies the entry's position. It is opaque to clients and only
+	// meaningful when handed back to Subscribe.
+	ID    string
+	Event todo.Event
+	// Origin is the value given to WithOrigin on the context that published
+	// the event, if any.
+	Origin string
+}
+
+type originKey struct{}
+
+// WithOrigin tags changes made with ctx as coming from origin, which lets a
+// subscriber that also makes changes recognise its own events in the feed.
+func WithOrigin(ctx context.Context, origin string) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// Broker fans events out to subscribers and remembers the most recent ones.
+type Broker struct {
+	// epoch distinguishes this process's IDs from those of a previous run,
+	// whose positions cannot be resumed.
+	epoch string
+
+	mu     sync.Mutex
+	seq    uint64
+	buf    []Entry // ring buffer of the last cap(buf) entries
+	next   int     // index in buf of the next write
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// New creates a Broker that keeps the last size events for replay.
+func New(size int) *Broker {
+	if size <= 0 {
+		size = 1
+	}
+	return &Broker{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		buf:   make([]Entry, 0, size),
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish implements todo.Publisher. Subscribers that are too far behind to
+// accept the event are disconnected rather than allowed to block publishers.
+func (b *Broker) Publish(ctx context.Context, e todo.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	origin, _ := ctx.Value(originKey{}).(string)
+	entry := Entry{
+		ID:     b.epoch + "-" + strconv.FormatUint(b.seq, 10),
+		Event:  e,
+		Origin: origin,
+	}
+	if len(b.buf) < cap(b.buf) {
+		b.buf = append(b.buf, entry)
+	} else {
+		b.buf[b.next] = entry
+	}
+	b.next = (b.next + 1) % cap(b.buf)
+
+	for sub := range b.subs {
+		select {
+		case sub.c <- entry:
+		default:
+			b.removeLocked(sub)
+		}
+	}
+	return nil
+}
+
+// Subscribe starts following the feed. When lastID is the ID of a previously
+// received entry, the entries published since are returned in Replay. If they
+// are no longer buffered, Reset is set instead and the subscriber should
+// reload its state before relying on the feed.
+func (b *Broker) Subscribe(lastID string) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	sub := &Subscription{b: b, c: make(chan Entry, subscriberBuffer)}
+	if lastID != "" {
+		sub.Replay, sub.Reset = b.sinceLocked(lastID)
+	}
+	b.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Close disconnects every subscriber and rejects new ones. Publishing still
+// succeeds so that the service is unaffected during shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.removeLocked(sub)
+	}
+}
+
+// sinceLocked returns the buffered entries after lastID, oldest first, or
+// reset=true when the position cannot be resumed.
+func (b *Broker) sinceLocked(lastID string) (entries []Entry, reset bool) {
+	epoch, rawSeq, ok := strings.Cut(lastID, "-")
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if !ok || err != nil || epoch != b.epoch || seq > b.seq {
+		return nil, true
+	}
+	missed := b.seq - seq
+	if missed > uint64(len(b.buf)) {
+		return nil, true
+	}
+	for i := len(b.buf) - int(missed); i < len(b.buf); i++ {
+		// Oldest entry lives at next once the ring has wrapped, at 0 before.
+		idx := i
+		if len(b.buf) == cap(b.buf) {
+			idx = (b.next + i) % cap(b.buf)
+		}
+		entries = append(entries, b.buf[idx])
+	}
+	return entries, false
+}
+
+func (b *Broker) removeLocked(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+// Subscription is a subscriber's view of the feed.
+type Subscription struct {
+	// Replay holds the entries missed since the ID passed to Subscribe.
+	Replay []Entry
+	// Reset reports that missed entries could not be replayed.
+	Reset bool
+
+	b *Broker
+	c chan Entry
+}
+
+// C delivers entries published after the subscription started. It is closed
+// when the subscriber falls too far behind, calls Close, or the Broker closes.
+func (s *Subscription) C() <-chan Entry {
+	return s.c
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.removeLocked(s)
+}
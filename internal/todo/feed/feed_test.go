@@ -0,0 +1,151 @@
+package feed_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/feed"
+)
+
+func publish(t *testing.T, b *feed.Broker, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		e := todo.Event{ID: fmt.Sprintf("evt-%d", i), Type: todo.EventCreated}
+		if err := b.Publish(context.Background(), e); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+}
+
+func eventIDs(entries []feed.Entry) []string {
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.Event.ID
+	}
+	return ids
+}
+
+func TestBroker(t *testing.T) {
+	t.Run("Delivers live events", func(t *testing.T) {
+		b := feed.New(10)
+		sub, err := b.Subscribe("")
+		if err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		defer sub.Close()
+
+		publish(t, b, 2)
+		for _, want := range []string{"evt-0", "evt-1"} {
+			if got := (<-sub.C()).Event.ID; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		}
+		if len(sub.Replay) != 0 || sub.Reset {
+			t.Errorf("fresh subscription got replay=%v reset=%v", sub.Replay, sub.Reset)
+		}
+	})
+
+	t.Run("Replays events after last ID", func(t *testing.T) {
+		b := feed.New(10)
+		first, _ := b.Subscribe("")
+		publish(t, b, 5)
+		second := <-first.C()
+		first.Close()
+
+		resumed, err := b.Subscribe(second.ID)
+		if err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		defer resumed.Close()
+		if resumed.Reset {
+			t.Fatal("unexpected reset")
+		}
+		got := fmt.Sprint(eventIDs(resumed.Replay))
+		if want := "[evt-1 evt-2 evt-3 evt-4]"; got != want {
+			t.Errorf("replay = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Replays across ring wraparound", func(t *testing.T) {
+		b := feed.New(3)
+		sub, _ := b.Subscribe("")
+		publish(t, b, 4)
+		var entries []feed.Entry
+		for i := 0; i < 4; i++ {
+			entries = append(entries, <-sub.C())
+		}
+		sub.Close()
+
+		resumed, _ := b.Subscribe(entries[1].ID)
+		defer resumed.Close()
+		got := fmt.Sprint(eventIDs(resumed.Replay))
+		if want := "[evt-2 evt-3]"; got != want {
+			t.Errorf("replay = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Resets when position is no longer buffered", func(t *testing.T) {
+		b := feed.New(2)
+		sub, _ := b.Subscribe("")
+		publish(t, b, 1)
+		oldest := <-sub.C()
+		sub.Close()
+		publish(t, b, 5)
+
+		for _, id := range []string{oldest.ID, "previous-process-7", "garbage"} {
+			resumed, _ := b.Subscribe(id)
+			if !resumed.Reset || len(resumed.Replay) != 0 {
+				t.Errorf("Subscribe(%q) got reset=%v replay=%d, want reset and no replay", id, resumed.Reset, len(resumed.Replay))
+			}
+			resumed.Close()
+		}
+	})
+
+	t.Run("Disconnects slow subscribers", func(t *testing.T) {
+		b := feed.New(1000)
+		slow, _ := b.Subscribe("")
+		publish(t, b, 500)
+
+		n := 0
+		for range slow.C() {
+			n++
+		}
+		if n == 0 || n >= 500 {
+			t.Errorf("slow subscriber received %d events before disconnect", n)
+		}
+	})
+
+	t.Run("Records origin", func(t *testing.T) {
+		b := feed.New(10)
+		sub, _ := b.Subscribe("")
+		defer sub.Close()
+
+		_ = b.Publish(feed.WithOrigin(context.Background(), "conn-1"), todo.Event{ID: "mine"})
+		_ = b.Publish(context.Background(), todo.Event{ID: "theirs"})
+
+		if got := (<-sub.C()).Origin; got != "conn-1" {
+			t.Errorf("origin = %q, want %q", got, "conn-1")
+		}
+		if got := (<-sub.C()).Origin; got != "" {
+			t.Errorf("origin = %q, want empty", got)
+		}
+	})
+
+	t.Run("Close ends subscriptions", func(t *testing.T) {
+		b := feed.New(10)
+		sub, _ := b.Subscribe("")
+		b.Close()
+
+		if _, ok := <-sub.C(); ok {
+			t.Error("expected closed channel")
+		}
+		sub.Close()
+		if _, err := b.Subscribe(""); !errors.Is(err, feed.ErrClosed) {
+			t.Errorf("Subscribe() after Close() error = %v, want ErrClosed", err)
+		}
+		publish(t, b, 1)
+	})
+}
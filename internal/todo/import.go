@@ -0,0 +1,158 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jllovet/go-server-template/logger"
+	"github.com/segmentio/ksuid"
+)
+
+// ErrConflict is returned by Import when a todo's ID is taken and the
+// ConflictPolicy is ConflictFail.
+var ErrConflict = errors.New("todo already exists")
+
+// ConflictPolicy decides what Import does with a todo whose ID is taken,
+// whether by a todo in the list or in the trash.
+type ConflictPolicy string
+
+const (
+	// ConflictFail refuses the todo with ErrConflict.
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictSkip leaves the existing todo alone.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the existing todo, taking it out of the
+	// trash if need be.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// ParseConflictPolicy parses the name of a ConflictPolicy.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: unknown conflict policy %q, want fail, skip or overwrite", ErrInvalid, s)
+}
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// KeepIDs imports todos under their own IDs, which must be set.
+	// Otherwise each todo gets a new ID, so there are no conflicts.
+	KeepIDs bool
+	// OnConflict applies when KeepIDs is set; it defaults to ConflictFail.
+	OnConflict ConflictPolicy
+	// DryRun validates the todo and reports what would happen without
+	// changing anything.
+	DryRun bool
+}
+
+// ImportOutcome is what Import did with a todo.
+type ImportOutcome string
+
+const (
+	ImportCreated     ImportOutcome = "created"
+	ImportOverwritten ImportOutcome = "overwritten"
+	ImportSkipped     ImportOutcome = "skipped"
+)
+
+// maxIDLength bounds the IDs kept by Import.
+const maxIDLength = 64
+
+// validID reports whether id can be kept by Import: up to maxIDLength
+// letters, digits and the punctuation - _ . :, which covers the IDs of
+// every store.
+func validID(id string) bool {
+	if id == "" || len(id) > maxIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Import adds t as it is, under opts, rather than as a new todo. It is
+// how todos are moved between stores: the todo is created or overwritten
+// with its title and completion, outside the trash.
+func (s *service) Import(ctx context.Context, t Todo, opts ImportOptions) (Todo, ImportOutcome, error) {
+	if t.Title == "" {
+		return Todo{}, "", fmt.Errorf("%w: title cannot be empty", ErrInvalid)
+	}
+	policy := opts.OnConflict
+	if policy == "" {
+		policy = ConflictFail
+	}
+	if _, err := ParseConflictPolicy(string(policy)); err != nil {
+		return Todo{}, "", err
+	}
+	t.DeletedAt = nil
+	if !opts.KeepIDs {
+		t.ID = ksuid.New().String()
+	} else if !validID(t.ID) {
+		return Todo{}, "", fmt.Errorf("%w: id %q must be 1 to %d letters, digits, '-', '_', '.' or ':'", ErrInvalid, t.ID, maxIDLength)
+	}
+
+	var outcome ImportOutcome
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		outcome = ImportCreated
+		var before *Todo
+		if opts.KeepIDs {
+			existing, err := s.findAny(ctx, t.ID)
+			switch {
+			case errors.Is(err, ErrNotFound):
+			case err != nil:
+				logger.FromContext(ctx).Error("failed to find todo for import", "id", t.ID, "error", err)
+				return fmt.Errorf("failed to find todo %q for import: %w", t.ID, err)
+			case policy == ConflictSkip:
+				outcome = ImportSkipped
+				return nil
+			case policy == ConflictFail:
+				return fmt.Errorf("%w: %q", ErrConflict, t.ID)
+			default:
+				outcome = ImportOverwritten
+				before = &existing
+			}
+		}
+		if opts.DryRun {
+			return nil
+		}
+
+		logger.FromContext(ctx).Info("importing todo", "id", t.ID, "outcome", outcome)
+		if err := s.repo.Save(ctx, t); err != nil {
+			logger.FromContext(ctx).Error("failed to save imported todo", "id", t.ID, "error", err)
+			return fmt.Errorf("failed to save imported todo: %w", err)
+		}
+		action := EventCreated
+		if before != nil {
+			action = EventUpdated
+		}
+		if err := s.record(ctx, action, t.ID, before, &t); err != nil {
+			return err
+		}
+		s.publish(ctx, action, t)
+		return nil
+	})
+	if err != nil {
+		return Todo{}, "", err
+	}
+	if outcome == ImportSkipped {
+		return Todo{}, outcome, nil
+	}
+	return t, outcome, nil
+}
+
+// findAny finds a todo whether or not it is in the trash.
+func (s *service) findAny(ctx context.Context, id string) (Todo, error) {
+	t, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return s.repo.FindDeletedByID(ctx, id)
+	}
+	return t, err
+}
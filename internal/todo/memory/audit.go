@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// AuditRepository is an in-memory implementation of todo.AuditRepository.
+type AuditRepository struct {
+	// mu protects entries from concurrent access.
+	mu      sync.RWMutex
+	entries []todo.AuditEntry
+}
+
+// NewAuditRepository creates a new in-memory audit log.
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+// Append adds an entry to the log. Inside a Repository transaction the
+// entry is removed again if the transaction is rolled back.
+func (r *AuditRepository) Append(ctx context.Context, e todo.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, copyEntry(e))
+	onRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.entries = slices.DeleteFunc(r.entries, func(x todo.AuditEntry) bool { return x.ID == e.ID })
+	})
+	return nil
+}
+
+// FindByTodoID retrieves the entries for a todo in the order they were appended.
+func (r *AuditRepository) FindByTodoID(ctx context.Context, todoID string) ([]todo.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var entries []todo.AuditEntry
+	for _, e := range r.entries {
+		if e.TodoID == todoID {
+			entries = append(entries, copyEntry(e))
+		}
+	}
+	return entries, nil
+}
+
+// copyEntry detaches the snapshots so that callers cannot rewrite history.
+func copyEntry(e todo.AuditEntry) todo.AuditEntry {
+	if e.Before != nil {
+		before := *e.Before
+		e.Before = &before
+	}
+	if e.After != nil {
+		after := *e.After
+		e.After = &after
+	}
+	return e
+}
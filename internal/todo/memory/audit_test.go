@@ -0,0 +1,45 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+)
+
+func TestAuditRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Append and FindByTodoID", func(t *testing.T) {
+		repo := memory.NewAuditRepository()
+		after := todo.Todo{ID: "1", Title: "First"}
+		_ = repo.Append(ctx, todo.AuditEntry{ID: "a", TodoID: "1", Action: todo.EventCreated, After: &after, At: time.Now()})
+		_ = repo.Append(ctx, todo.AuditEntry{ID: "b", TodoID: "2", Action: todo.EventCreated, At: time.Now()})
+		_ = repo.Append(ctx, todo.AuditEntry{ID: "c", TodoID: "1", Action: todo.EventDeleted, Before: &after, At: time.Now()})
+
+		entries, err := repo.FindByTodoID(ctx, "1")
+		if err != nil {
+			t.Fatalf("FindByTodoID() error = %v", err)
+		}
+		if len(entries) != 2 || entries[0].ID != "a" || entries[1].ID != "c" {
+			t.Fatalf("got %+v, want entries a and c in order", entries)
+		}
+	})
+
+	t.Run("Snapshots cannot be rewritten", func(t *testing.T) {
+		repo := memory.NewAuditRepository()
+		after := todo.Todo{ID: "1", Title: "Original"}
+		_ = repo.Append(ctx, todo.AuditEntry{ID: "a", TodoID: "1", After: &after})
+
+		after.Title = "Tampered"
+		entries, _ := repo.FindByTodoID(ctx, "1")
+		entries[0].After.Title = "Tampered again"
+
+		entries, _ = repo.FindByTodoID(ctx, "1")
+		if entries[0].After.Title != "Original" {
+			t.Errorf("got title %q, want %q", entries[0].After.Title, "Original")
+		}
+	})
+}
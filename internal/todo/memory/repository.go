@@ -2,63 +2,165 @@ package memory
 
 import (
 	"context"
-	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jllovet/go-server-template/internal/todo"
 )
 
 // Repository is an in-memory implementation of todo.Repository.
 type Repository struct {
-	// mu protects the todos map from concurrent access.
+	// mu protects the todos map and its search index from concurrent access.
 	mu    sync.RWMutex
 	todos map[string]todo.Todo
+	index *index
 }
 
 // New creates a new in-memory repository.
 func New() *Repository {
 	return &Repository{
 		todos: make(map[string]todo.Todo),
+		index: newIndex(),
 	}
 }
 
-// Save stores the todo item.
-func (r *Repository) Save(ctx context.Context, t todo.Todo) error {
+// WithinTx runs fn with the repository locked, so that the calls fn makes
+// with its context are not interleaved with any others. If fn fails, the
+// todos and any audit entries appended in the meantime are restored.
+func (r *Repository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if t := txFromContext(ctx); t != nil && t.repo == r {
+		return fn(ctx)
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	snapshot := maps.Clone(r.todos)
+	t := &tx{repo: r}
+	if err := fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		r.todos = snapshot
+		r.index = buildIndex(snapshot)
+		for _, undo := range t.undo {
+			undo()
+		}
+		return err
+	}
+	return nil
+}
+
+// lock takes the write lock, unless ctx is in a transaction that holds it.
+func (r *Repository) lock(ctx context.Context) (unlock func()) {
+	if t := txFromContext(ctx); t != nil && t.repo == r {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+// rlock is like lock for reads.
+func (r *Repository) rlock(ctx context.Context) (unlock func()) {
+	if t := txFromContext(ctx); t != nil && t.repo == r {
+		return func() {}
+	}
+	r.mu.RLock()
+	return r.mu.RUnlock
+}
+
+// Save stores the todo item.
+func (r *Repository) Save(ctx context.Context, t todo.Todo) error {
+	defer r.lock(ctx)()
 	r.todos[t.ID] = t
+	r.index.add(t)
 	return nil
 }
 
-// FindByID retrieves a todo by its ID.
+// FindByID retrieves a todo by its ID, unless it is in the trash.
 func (r *Repository) FindByID(ctx context.Context, id string) (todo.Todo, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	defer r.rlock(ctx)()
 	t, ok := r.todos[id]
-	if !ok {
-		return todo.Todo{}, fmt.Errorf("todo not found")
+	if !ok || t.DeletedAt != nil {
+		return todo.Todo{}, todo.ErrNotFound
 	}
 	return t, nil
 }
 
-// FindAll retrieves all todos.
+// FindAll retrieves all todos that are not in the trash.
 func (r *Repository) FindAll(ctx context.Context) ([]todo.Todo, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	defer r.rlock(ctx)()
 	todos := make([]todo.Todo, 0, len(r.todos))
 	for _, t := range r.todos {
-		todos = append(todos, t)
+		if t.DeletedAt == nil {
+			todos = append(todos, t)
+		}
 	}
 	return todos, nil
 }
 
-// Delete removes a todo by its ID.
+// ForEach calls fn with a snapshot of the todos that are not in the trash,
+// taken so that a slow fn does not hold up writers.
+func (r *Repository) ForEach(ctx context.Context, fn func(todo.Todo) error) error {
+	todos, err := r.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(todos, func(a, b todo.Todo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	for _, t := range todos {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete permanently removes a todo by its ID.
 func (r *Repository) Delete(ctx context.Context, id string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	defer r.lock(ctx)()
 	if _, ok := r.todos[id]; !ok {
-		return fmt.Errorf("todo not found")
+		return todo.ErrNotFound
 	}
 	delete(r.todos, id)
+	r.index.remove(id)
 	return nil
 }
+
+// FindDeletedByID retrieves a todo by its ID if it is in the trash.
+func (r *Repository) FindDeletedByID(ctx context.Context, id string) (todo.Todo, error) {
+	defer r.rlock(ctx)()
+	t, ok := r.todos[id]
+	if !ok || t.DeletedAt == nil {
+		return todo.Todo{}, todo.ErrNotFound
+	}
+	return t, nil
+}
+
+// FindDeleted retrieves the todos in the trash, most recently deleted first.
+func (r *Repository) FindDeleted(ctx context.Context) ([]todo.Todo, error) {
+	defer r.rlock(ctx)()
+	var todos []todo.Todo
+	for _, t := range r.todos {
+		if t.DeletedAt != nil {
+			todos = append(todos, t)
+		}
+	}
+	slices.SortFunc(todos, func(a, b todo.Todo) int {
+		return b.DeletedAt.Compare(*a.DeletedAt)
+	})
+	return todos, nil
+}
+
+// PurgeDeleted permanently removes todos deleted before the given time.
+func (r *Repository) PurgeDeleted(ctx context.Context, before time.Time) ([]todo.Todo, error) {
+	defer r.lock(ctx)()
+	var purged []todo.Todo
+	for id, t := range r.todos {
+		if t.DeletedAt != nil && t.DeletedAt.Before(before) {
+			purged = append(purged, t)
+			delete(r.todos, id)
+			r.index.remove(id)
+		}
+	}
+	return purged, nil
+}
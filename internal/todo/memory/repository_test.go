@@ -2,9 +2,12 @@ package memory_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/jllovet/go-server-template/internal/todo"
 	"github.com/jllovet/go-server-template/internal/todo/memory"
@@ -74,6 +77,37 @@ func TestRepository(t *testing.T) {
 		}
 	})
 
+	t.Run("ForEach", func(t *testing.T) {
+		repo := memory.New()
+		deletedAt := time.Now()
+		for _, item := range []todo.Todo{
+			{ID: "b", Title: "B"},
+			{ID: "a", Title: "A"},
+			{ID: "c", Title: "C", DeletedAt: &deletedAt},
+		} {
+			_ = repo.Save(ctx, item)
+		}
+
+		var ids []string
+		err := repo.ForEach(ctx, func(t todo.Todo) error {
+			ids = append(ids, t.ID)
+			return nil
+		})
+		if err != nil || !slices.Equal(ids, []string{"a", "b"}) {
+			t.Errorf("ForEach() = %v after %v, want a then b", err, ids)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = repo.ForEach(ctx, func(todo.Todo) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("ForEach() = %v after %d calls, want fn's error after 1", err, calls)
+		}
+	})
+
 	t.Run("Delete", func(t *testing.T) {
 		repo := memory.New()
 		item := todo.Todo{ID: "3", Title: "To Delete"}
@@ -96,6 +130,92 @@ func TestRepository(t *testing.T) {
 		}
 	})
 
+	t.Run("Trash", func(t *testing.T) {
+		repo := memory.New()
+		now := time.Now()
+		earlier := now.Add(-time.Hour)
+		_ = repo.Save(ctx, todo.Todo{ID: "active", Title: "Active"})
+		_ = repo.Save(ctx, todo.Todo{ID: "old", Title: "Old", DeletedAt: &earlier})
+		_ = repo.Save(ctx, todo.Todo{ID: "new", Title: "New", DeletedAt: &now})
+
+		all, _ := repo.FindAll(ctx)
+		if len(all) != 1 || all[0].ID != "active" {
+			t.Errorf("FindAll() got %+v, want only active todo", all)
+		}
+		if _, err := repo.FindByID(ctx, "old"); err == nil {
+			t.Error("FindByID() expected error for trashed item, got nil")
+		}
+		if _, err := repo.FindDeletedByID(ctx, "active"); err == nil {
+			t.Error("FindDeletedByID() expected error for active item, got nil")
+		}
+
+		trash, err := repo.FindDeleted(ctx)
+		if err != nil {
+			t.Fatalf("FindDeleted() error = %v", err)
+		}
+		if len(trash) != 2 || trash[0].ID != "new" || trash[1].ID != "old" {
+			t.Errorf("FindDeleted() got %+v, want new then old", trash)
+		}
+
+		purged, err := repo.PurgeDeleted(ctx, now.Add(-time.Minute))
+		if err != nil {
+			t.Fatalf("PurgeDeleted() error = %v", err)
+		}
+		if len(purged) != 1 || purged[0].ID != "old" {
+			t.Errorf("PurgeDeleted() got %+v, want old", purged)
+		}
+		if _, err := repo.FindDeletedByID(ctx, "old"); err == nil {
+			t.Error("FindDeletedByID() expected error after purge, got nil")
+		}
+	})
+
+	t.Run("WithinTx", func(t *testing.T) {
+		repo := memory.New()
+		audit := memory.NewAuditRepository()
+		errRollback := errors.New("rollback")
+
+		err := repo.WithinTx(ctx, func(ctx context.Context) error {
+			if err := repo.Save(ctx, todo.Todo{ID: "discarded", Title: "Discarded"}); err != nil {
+				return err
+			}
+			if err := audit.Append(ctx, todo.AuditEntry{ID: "a1", TodoID: "discarded"}); err != nil {
+				return err
+			}
+			// Nested units of work join the outer one.
+			return repo.WithinTx(ctx, func(ctx context.Context) error {
+				if _, err := repo.FindByID(ctx, "discarded"); err != nil {
+					return err
+				}
+				return errRollback
+			})
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("WithinTx() error = %v, want %v", err, errRollback)
+		}
+		if _, err := repo.FindByID(ctx, "discarded"); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("expected rolled back todo to be missing, got %v", err)
+		}
+		if entries, _ := audit.FindByTodoID(ctx, "discarded"); len(entries) != 0 {
+			t.Errorf("expected rolled back audit entry to be missing, got %+v", entries)
+		}
+
+		err = repo.WithinTx(ctx, func(ctx context.Context) error {
+			if err := repo.Save(ctx, todo.Todo{ID: "kept", Title: "Kept"}); err != nil {
+				return err
+			}
+			return audit.Append(ctx, todo.AuditEntry{ID: "a2", TodoID: "kept"})
+		})
+		if err != nil {
+			t.Fatalf("WithinTx() error = %v", err)
+		}
+		if _, err := repo.FindByID(ctx, "kept"); err != nil {
+			t.Errorf("expected committed todo, got %v", err)
+		}
+		if entries, _ := audit.FindByTodoID(ctx, "kept"); len(entries) != 1 {
+			t.Errorf("expected committed audit entry, got %+v", entries)
+		}
+	})
+
 	t.Run("Concurrent Access", func(t *testing.T) {
 		// This test verifies that the repository is thread-safe.
 		// Go maps are not safe for concurrent use, so this test would panic
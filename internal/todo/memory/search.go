@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// index is an inverted index from terms to the todos containing them, with
+// the number of occurrences in each.
+type index struct {
+	postings map[string]map[string]int
+	// terms remembers what each todo was indexed under, for removal.
+	terms map[string][]string
+}
+
+func newIndex() *index {
+	return &index{
+		postings: make(map[string]map[string]int),
+		terms:    make(map[string][]string),
+	}
+}
+
+// buildIndex indexes every todo.
+func buildIndex(todos map[string]todo.Todo) *index {
+	idx := newIndex()
+	for _, t := range todos {
+		idx.add(t)
+	}
+	return idx
+}
+
+func (idx *index) add(t todo.Todo) {
+	idx.remove(t.ID)
+	terms := analyze(t.Title)
+	for _, term := range terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]int)
+		}
+		idx.postings[term][t.ID]++
+	}
+	idx.terms[t.ID] = terms
+}
+
+func (idx *index) remove(id string) {
+	for _, term := range idx.terms[id] {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.terms, id)
+}
+
+// Search finds the todos containing every term of the query, ranked by how
+// often the terms occur in their titles.
+func (r *Repository) Search(ctx context.Context, query string, limit int) ([]todo.SearchResult, error) {
+	defer r.rlock(ctx)()
+
+	terms := analyze(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	var scores map[string]int
+	for i, term := range slices.Compact(slices.Sorted(slices.Values(terms))) {
+		postings := r.index.postings[term]
+		if i == 0 {
+			scores = make(map[string]int, len(postings))
+			for id, n := range postings {
+				scores[id] = n
+			}
+			continue
+		}
+		for id := range scores {
+			if n, ok := postings[id]; ok {
+				scores[id] += n
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]todo.SearchResult, 0, len(scores))
+	for id, score := range scores {
+		if t := r.todos[id]; t.DeletedAt == nil {
+			results = append(results, todo.SearchResult{Todo: t, Rank: float64(score)})
+		}
+	}
+	slices.SortFunc(results, func(a, b todo.SearchResult) int {
+		if a.Rank != b.Rank {
+			if a.Rank > b.Rank {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Todo.ID, b.Todo.ID)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// analyze splits text into lower-case words, drops stop words and reduces
+// the rest to their stems.
+func analyze(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if stopWords[w] {
+			continue
+		}
+		terms = append(terms, stem(w))
+	}
+	return terms
+}
+
+// stem strips common English suffixes. It is far cruder than a real
+// stemmer, but maps the usual inflections of a word to the same term:
+// "reports" and "report", "running" and "run", "groceries" and "grocery".
+func stem(w string) string {
+	if len(w) <= 3 {
+		return w
+	}
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		w = w[:len(w)-3] + "i"
+	case strings.HasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ss"):
+	case strings.HasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		if s, ok := strings.CutSuffix(w, suffix); ok && len(s) >= 3 {
+			w = s
+			// "running" -> "runn" -> "run"
+			if n := len(w); w[n-1] == w[n-2] && !strings.ContainsRune("aeioulsz", rune(w[n-1])) {
+				w = w[:n-1]
+			}
+			break
+		}
+	}
+	if n := len(w); n > 3 && w[n-1] == 'y' && !strings.ContainsRune("aeiou", rune(w[n-2])) {
+		w = w[:n-1] + "i"
+	}
+	return strings.TrimSuffix(w, "e")
+}
+
+// stopWords are too common to be worth indexing.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "my": true, "no": true,
+	"not": true, "of": true, "on": true, "or": true, "our": true, "so": true,
+	"that": true, "the": true, "their": true, "then": true, "there": true,
+	"these": true, "they": true, "this": true, "to": true, "was": true,
+	"we": true, "will": true, "with": true, "you": true, "your": true,
+}
@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/internal/todo/todotest"
+)
+
+func TestRepository_Search(t *testing.T) {
+	todotest.TestSearcher(t, func(t *testing.T) todotest.SearchRepository {
+		return memory.New()
+	})
+}
@@ -0,0 +1,27 @@
+package memory
+
+import "context"
+
+// txKey is the context key under which WithinTx stores its transaction.
+type txKey struct{}
+
+// tx is an open transaction on a Repository.
+type tx struct {
+	repo *Repository
+	// undo holds what other adapters in this package must revert if the
+	// transaction is rolled back, most recent first.
+	undo []func()
+}
+
+func txFromContext(ctx context.Context) *tx {
+	t, _ := ctx.Value(txKey{}).(*tx)
+	return t
+}
+
+// onRollback registers f to run if the transaction of ctx is rolled back.
+// Outside a transaction it does nothing.
+func onRollback(ctx context.Context, f func()) {
+	if t := txFromContext(ctx); t != nil {
+		t.undo = append([]func(){f}, t.undo...)
+	}
+}
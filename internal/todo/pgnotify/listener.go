@@ -0,0 +1,143 @@
+// Package pgnotify turns the notifications sent by the todos table trigger
+// (see ../postgres/schema.sql) into an in-process feed of changes, so that
+// an instance learns about changes made by other instances sharing the
+// database.
+package pgnotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// Channel is the channel the todos trigger notifies.
+const Channel = "todo_changes"
+
+// Change is a change to a todo committed by some server instance.
+type Change struct {
+	Type   todo.EventType `json:"type"`
+	TodoID string         `json:"id"`
+	// Origin is the application_name of the session that made the change.
+	Origin string `json:"origin"`
+	// Reset is set, with no other fields, after the listener reconnects:
+	// changes made while it was disconnected were missed, and subscribers
+	// that keep derived state should discard it.
+	Reset bool `json:"-"`
+}
+
+// Handler is called for every Change, in order, from the listener goroutine.
+type Handler func(ctx context.Context, c Change)
+
+// Options configures a Listener.
+type Options struct {
+	// Origin is the application_name this instance's own database sessions
+	// use. Changes from it are skipped, since they are already published
+	// in-process.
+	Origin string
+	// MinBackoff and MaxBackoff bound the delay between reconnection
+	// attempts, which doubles after each failure.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Listener keeps a connection LISTENing on Channel and hands the changes it
+// receives to its subscribers, reconnecting whenever the connection is lost.
+type Listener struct {
+	dsn  string
+	opts Options
+
+	mu       sync.Mutex
+	handlers []Handler
+}
+
+// NewListener creates a Listener that connects to dsn when it is run.
+func NewListener(dsn string, opts Options) *Listener {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	return &Listener{dsn: dsn, opts: opts}
+}
+
+// Subscribe registers h to receive every change from now on.
+func (l *Listener) Subscribe(h Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers = append(l.handlers, h)
+}
+
+// Run listens until ctx is cancelled.
+func (l *Listener) Run(ctx context.Context) {
+	log := logger.FromContext(ctx)
+	backoff := l.opts.MinBackoff
+	connected := false
+	for {
+		err := l.listen(ctx, func() {
+			if connected {
+				l.dispatch(ctx, Change{Reset: true})
+			}
+			connected = true
+			backoff = l.opts.MinBackoff
+			log.Info("listening for todo changes", "channel", Channel)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("todo change listener disconnected", "error", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, l.opts.MaxBackoff)
+	}
+}
+
+// listen runs one connection until it fails, calling onConnect once it is
+// listening.
+func (l *Listener) listen(ctx context.Context, onConnect func()) error {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	onConnect()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+		var c Change
+		if err := json.Unmarshal([]byte(n.Payload), &c); err != nil {
+			logger.FromContext(ctx).Error("malformed todo change notification", "payload", n.Payload, "error", err)
+			continue
+		}
+		if c.Origin != "" && c.Origin == l.opts.Origin {
+			continue
+		}
+		l.dispatch(ctx, c)
+	}
+}
+
+func (l *Listener) dispatch(ctx context.Context, c Change) {
+	l.mu.Lock()
+	handlers := l.handlers
+	l.mu.Unlock()
+	for _, h := range handlers {
+		h(ctx, c)
+	}
+}
@@ -0,0 +1,108 @@
+package pgnotify_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/pgnotify"
+)
+
+func TestListener(t *testing.T) {
+	// Skip if TEST_DATABASE_URL is not set.
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("Skipping listener tests: TEST_DATABASE_URL not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// connect opens a session that identifies itself as origin.
+	connect := func(origin string) *pgx.Conn {
+		cfg, err := pgx.ParseConfig(dbURL)
+		if err != nil {
+			t.Fatalf("failed to parse url: %v", err)
+		}
+		cfg.RuntimeParams["application_name"] = origin
+		conn, err := pgx.ConnectConfig(ctx, cfg)
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		t.Cleanup(func() { conn.Close(context.Background()) })
+		return conn
+	}
+	self := connect("listener-test/self")
+	other := connect("listener-test/other")
+
+	schema, err := os.ReadFile("../postgres/schema.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := self.Exec(ctx, string(schema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+	if _, err := self.Exec(ctx, "TRUNCATE TABLE todos"); err != nil {
+		t.Fatalf("failed to truncate table: %v", err)
+	}
+
+	changes := make(chan pgnotify.Change, 10)
+	listener := pgnotify.NewListener(dbURL, pgnotify.Options{Origin: "listener-test/self"})
+	listener.Subscribe(func(_ context.Context, c pgnotify.Change) { changes <- c })
+	go listener.Run(ctx)
+
+	// Wait until the listener is up by repeating a change until one arrives,
+	// then let any stragglers through.
+	deadline := time.After(5 * time.Second)
+	for up := false; !up; {
+		other.Exec(ctx, "INSERT INTO todos (id, title) VALUES ('probe', 'Probe') ON CONFLICT (id) DO UPDATE SET title = todos.title || '.'")
+		select {
+		case <-changes:
+			up = true
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("listener never received a change")
+		}
+	}
+	for drained := false; !drained; {
+		select {
+		case <-changes:
+		case <-time.After(200 * time.Millisecond):
+			drained = true
+		}
+	}
+
+	// Changes made by this instance are skipped.
+	if _, err := self.Exec(ctx, "INSERT INTO todos (id, title) VALUES ('mine', 'Mine')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	steps := []struct {
+		sql  string
+		want todo.EventType
+	}{
+		{"INSERT INTO todos (id, title) VALUES ('t1', 'One')", todo.EventCreated},
+		{"UPDATE todos SET title = 'Uno' WHERE id = 't1'", todo.EventUpdated},
+		{"UPDATE todos SET completed = TRUE WHERE id = 't1'", todo.EventCompleted},
+		{"UPDATE todos SET deleted_at = now() WHERE id = 't1'", todo.EventDeleted},
+		{"UPDATE todos SET deleted_at = NULL WHERE id = 't1'", todo.EventRestored},
+		{"DELETE FROM todos WHERE id = 't1'", todo.EventPurged},
+	}
+	for _, step := range steps {
+		if _, err := other.Exec(ctx, step.sql); err != nil {
+			t.Fatalf("%s: %v", step.sql, err)
+		}
+		select {
+		case c := <-changes:
+			if c.Type != step.want || c.TodoID != "t1" || c.Origin != "listener-test/other" {
+				t.Errorf("%s: got %+v, want %s for t1", step.sql, c, step.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no change received", step.sql)
+		}
+	}
+}
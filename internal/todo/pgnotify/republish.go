@@ -0,0 +1,47 @@
+package pgnotify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/segmentio/ksuid"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// Republish returns a Handler that turns changes into todo.Events for pub,
+// typically the feed.Broker behind the event stream, so that clients of
+// this instance see changes made through any instance. The todo is loaded
+// from repo; when it is gone (purged, or changed again since) the event
+// only carries its ID.
+func Republish(repo todo.Repository, pub todo.Publisher) Handler {
+	return func(ctx context.Context, c Change) {
+		if c.Reset || !c.Type.Valid() {
+			return
+		}
+		// The change is committed on the primary but may not have reached
+		// a replica yet.
+		ctx = todo.WithPrimaryReads(ctx)
+		t, err := repo.FindByID(ctx, c.TodoID)
+		if errors.Is(err, todo.ErrNotFound) {
+			t, err = repo.FindDeletedByID(ctx, c.TodoID)
+		}
+		if err != nil {
+			if !errors.Is(err, todo.ErrNotFound) {
+				logger.FromContext(ctx).Error("failed to load changed todo", "id", c.TodoID, "error", err)
+			}
+			t = todo.Todo{ID: c.TodoID}
+		}
+		e := todo.Event{
+			ID:         ksuid.New().String(),
+			Type:       c.Type,
+			Todo:       t,
+			OccurredAt: time.Now().UTC(),
+		}
+		if err := pub.Publish(ctx, e); err != nil {
+			logger.FromContext(ctx).Error("failed to republish todo change", "type", c.Type, "id", c.TodoID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package pgnotify_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/internal/todo/pgnotify"
+)
+
+type recordingPublisher struct {
+	events []todo.Event
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, e todo.Event) error {
+	p.events = append(p.events, e)
+	return nil
+}
+
+func TestRepublish(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.New()
+	deletedAt := time.Now()
+	_ = repo.Save(ctx, todo.Todo{ID: "active", Title: "Active"})
+	_ = repo.Save(ctx, todo.Todo{ID: "trashed", Title: "Trashed", DeletedAt: &deletedAt})
+
+	pub := &recordingPublisher{}
+	handle := pgnotify.Republish(repo, pub)
+	handle(ctx, pgnotify.Change{Type: todo.EventUpdated, TodoID: "active"})
+	handle(ctx, pgnotify.Change{Type: todo.EventDeleted, TodoID: "trashed"})
+	handle(ctx, pgnotify.Change{Type: todo.EventPurged, TodoID: "gone"})
+	handle(ctx, pgnotify.Change{Reset: true})
+	handle(ctx, pgnotify.Change{Type: "bogus", TodoID: "active"})
+
+	if len(pub.events) != 3 {
+		t.Fatalf("published %d events, want 3", len(pub.events))
+	}
+	if e := pub.events[0]; e.Type != todo.EventUpdated || e.Todo.Title != "Active" || e.ID == "" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e := pub.events[1]; e.Type != todo.EventDeleted || e.Todo.Title != "Trashed" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e := pub.events[2]; e.Type != todo.EventPurged || e.Todo.ID != "gone" {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
@@ -0,0 +1,61 @@
+package pgxstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// AuditRepository implements todo.AuditRepository on a pgx connection pool.
+// The todo_audit table rejects updates and deletes, see schema.sql.
+type AuditRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewAuditRepository creates a new pgx-backed audit log.
+func NewAuditRepository(pool *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{pool: pool}
+}
+
+// Append inserts an entry into the log, as part of the transaction of ctx
+// if there is one. The snapshots are encoded as JSONB by pgx, and a nil
+// snapshot as NULL.
+func (r *AuditRepository) Append(ctx context.Context, e todo.AuditEntry) error {
+	query := `
+		INSERT INTO todo_audit (id, todo_id, action, actor, request_id, before, after, at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, e.ID, e.TodoID, string(e.Action), e.Actor, e.RequestID, e.Before, e.After, e.At)
+	if err != nil {
+		return fmt.Errorf("pgx audit append: %w", err)
+	}
+	return nil
+}
+
+// FindByTodoID retrieves the entries for a todo, oldest first.
+func (r *AuditRepository) FindByTodoID(ctx context.Context, todoID string) ([]todo.AuditEntry, error) {
+	query := `
+		SELECT id, todo_id, action, actor, request_id, before, after, at
+		FROM todo_audit WHERE todo_id = $1 ORDER BY at, id
+	`
+	rows, err := conn(ctx, r.pool).Query(ctx, query, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("pgx audit find: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []todo.AuditEntry
+	for rows.Next() {
+		var e todo.AuditEntry
+		var action string
+		if err := rows.Scan(&e.ID, &e.TodoID, &action, &e.Actor, &e.RequestID, &e.Before, &e.After, &e.At); err != nil {
+			return nil, fmt.Errorf("pgx scan: %w", err)
+		}
+		e.Action = todo.EventType(action)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
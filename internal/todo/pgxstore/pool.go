@@ -0,0 +1,119 @@
+// Package pgxstore implements the todo ports on a native pgx connection
+// pool, as an alternative to the database/sql adapter in package postgres.
+// Both share the schema in ../postgres/schema.sql.
+package pgxstore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// PoolOptions tunes the connection pool. Zero values keep pgx's defaults,
+// which can also be set through the connection string (pool_max_conns and
+// friends).
+type PoolOptions struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	// StatementCacheMode is how queries are prepared: "cache_statement",
+	// "cache_describe", "describe_exec", "exec" or "simple_protocol".
+	StatementCacheMode string
+	// StatementCacheCapacity is the number of statements (or descriptions)
+	// cached per connection.
+	StatementCacheCapacity int
+	// ApplicationName identifies the pool's sessions to the database, and
+	// is what the change notifications report as their origin.
+	ApplicationName string
+}
+
+var execModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// NewPool connects a pool to the database at dsn and checks that it is
+// reachable.
+func NewPool(ctx context.Context, dsn string, opts PoolOptions) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse database url: %w", err)
+	}
+	if opts.MaxConns > 0 {
+		cfg.MaxConns = opts.MaxConns
+	}
+	if opts.MinConns > 0 {
+		cfg.MinConns = opts.MinConns
+	}
+	if opts.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = opts.MaxConnLifetime
+	}
+	if opts.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = opts.MaxConnIdleTime
+	}
+	if opts.HealthCheckPeriod > 0 {
+		cfg.HealthCheckPeriod = opts.HealthCheckPeriod
+	}
+	if opts.StatementCacheMode != "" {
+		mode, ok := execModes[opts.StatementCacheMode]
+		if !ok {
+			return nil, fmt.Errorf("unknown statement cache mode %q", opts.StatementCacheMode)
+		}
+		cfg.ConnConfig.DefaultQueryExecMode = mode
+	}
+	if opts.StatementCacheCapacity > 0 {
+		cfg.ConnConfig.StatementCacheCapacity = opts.StatementCacheCapacity
+		cfg.ConnConfig.DescriptionCacheCapacity = opts.StatementCacheCapacity
+	}
+
+	if opts.ApplicationName != "" {
+		cfg.ConnConfig.RuntimeParams["application_name"] = opts.ApplicationName
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ping pool: %w", err)
+	}
+	return pool, nil
+}
+
+// LogStats logs the pool's statistics every interval until ctx is cancelled.
+func LogStats(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s := pool.Stat()
+			logger.FromContext(ctx).Info("database pool stats",
+				slog.Int("total_conns", int(s.TotalConns())),
+				slog.Int("idle_conns", int(s.IdleConns())),
+				slog.Int("acquired_conns", int(s.AcquiredConns())),
+				slog.Int("max_conns", int(s.MaxConns())),
+				slog.Int64("acquire_count", s.AcquireCount()),
+				slog.Duration("acquire_duration", s.AcquireDuration()),
+				slog.Int64("empty_acquire_count", s.EmptyAcquireCount()),
+				slog.Int64("canceled_acquire_count", s.CanceledAcquireCount()),
+				slog.Int64("max_lifetime_destroy_count", s.MaxLifetimeDestroyCount()),
+				slog.Int64("max_idle_destroy_count", s.MaxIdleDestroyCount()),
+			)
+		}
+	}
+}
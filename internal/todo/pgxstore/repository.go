@@ -0,0 +1,184 @@
+package pgxstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// Repository implements todo.Repository on a pgx connection pool.
+type Repository struct {
+	pool *pgxpool.Pool
+}
+
+// New creates a new pgx-backed repository.
+func New(pool *pgxpool.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+// WithinTx runs fn in a single transaction. Repository and audit log calls
+// made with the context passed to fn take part in it.
+func (r *Repository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.pool, fn)
+}
+
+// Save creates or updates a todo.
+func (r *Repository) Save(ctx context.Context, t todo.Todo) error {
+	query := `
+		INSERT INTO todos (id, title, completed, deleted_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE
+		SET title = EXCLUDED.title, completed = EXCLUDED.completed, deleted_at = EXCLUDED.deleted_at
+	`
+	if _, err := conn(ctx, r.pool).Exec(ctx, query, t.ID, t.Title, t.Completed, t.DeletedAt); err != nil {
+		return fmt.Errorf("pgx save: %w", err)
+	}
+	return nil
+}
+
+// FindByID retrieves a todo by ID, unless it is in the trash. Inside a
+// transaction the row stays locked until it ends.
+func (r *Repository) FindByID(ctx context.Context, id string) (todo.Todo, error) {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE id = $1 AND deleted_at IS NULL`
+	return r.findOne(ctx, query, id)
+}
+
+// FindAll retrieves all todos that are not in the trash.
+func (r *Repository) FindAll(ctx context.Context) ([]todo.Todo, error) {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE deleted_at IS NULL`
+	return r.findMany(ctx, query)
+}
+
+// ForEach calls fn with each todo that is not in the trash as its row is
+// read.
+func (r *Repository) ForEach(ctx context.Context, fn func(todo.Todo) error) error {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE deleted_at IS NULL ORDER BY id`
+	rows, err := conn(ctx, r.pool).Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("pgx find all: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return fmt.Errorf("pgx scan: %w", err)
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Delete permanently removes a todo by ID.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	tag, err := conn(ctx, r.pool).Exec(ctx, `DELETE FROM todos WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("pgx delete: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return todo.ErrNotFound
+	}
+	return nil
+}
+
+// FindDeletedByID retrieves a todo by ID if it is in the trash, locking it
+// like FindByID.
+func (r *Repository) FindDeletedByID(ctx context.Context, id string) (todo.Todo, error) {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE id = $1 AND deleted_at IS NOT NULL`
+	return r.findOne(ctx, query, id)
+}
+
+// FindDeleted retrieves the todos in the trash, most recently deleted first.
+func (r *Repository) FindDeleted(ctx context.Context) ([]todo.Todo, error) {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC`
+	return r.findMany(ctx, query)
+}
+
+// PurgeDeleted permanently removes todos deleted before the given time.
+func (r *Repository) PurgeDeleted(ctx context.Context, before time.Time) ([]todo.Todo, error) {
+	query := `DELETE FROM todos WHERE deleted_at < $1 RETURNING id, title, completed, deleted_at`
+	return r.findMany(ctx, query, before)
+}
+
+// searchQuery ranks active todos against a websearch-style query.
+const searchQuery = `
+	SELECT id, title, completed, deleted_at, ts_rank(search, query)::float8 AS rank
+	FROM todos, websearch_to_tsquery('english', $1) AS query
+	WHERE deleted_at IS NULL AND search @@ query
+	ORDER BY rank DESC, id
+	LIMIT $2
+`
+
+// Search finds todos using the generated search column and its GIN index,
+// ranked with ts_rank.
+func (r *Repository) Search(ctx context.Context, query string, limit int) ([]todo.SearchResult, error) {
+	// LIMIT NULL means no limit.
+	var n any
+	if limit > 0 {
+		n = limit
+	}
+	rows, err := conn(ctx, r.pool).Query(ctx, searchQuery, query, n)
+	if err != nil {
+		return nil, fmt.Errorf("pgx search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []todo.SearchResult
+	for rows.Next() {
+		var res todo.SearchResult
+		if err := rows.Scan(&res.Todo.ID, &res.Todo.Title, &res.Todo.Completed, &res.Todo.DeletedAt, &res.Rank); err != nil {
+			return nil, fmt.Errorf("pgx scan: %w", err)
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+func (r *Repository) findOne(ctx context.Context, query string, args ...any) (todo.Todo, error) {
+	if inTx(ctx) {
+		query += " FOR UPDATE"
+	}
+	t, err := scanTodo(conn(ctx, r.pool).QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return todo.Todo{}, todo.ErrNotFound
+		}
+		return todo.Todo{}, fmt.Errorf("pgx find by id: %w", err)
+	}
+	return t, nil
+}
+
+func (r *Repository) findMany(ctx context.Context, query string, args ...any) ([]todo.Todo, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("pgx find all: %w", err)
+	}
+	defer rows.Close()
+
+	var todos []todo.Todo
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("pgx scan: %w", err)
+		}
+		todos = append(todos, t)
+	}
+	return todos, rows.Err()
+}
+
+// scanTodo reads a todo from a pgx.Row, which pgx.Rows also satisfies.
+func scanTodo(row pgx.Row) (todo.Todo, error) {
+	var t todo.Todo
+	if err := row.Scan(&t.ID, &t.Title, &t.Completed, &t.DeletedAt); err != nil {
+		return todo.Todo{}, err
+	}
+	return t, nil
+}
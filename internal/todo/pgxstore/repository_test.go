@@ -0,0 +1,188 @@
+package pgxstore_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/pgxstore"
+)
+
+// newPool connects to TEST_DATABASE_URL and applies the shared schema, or
+// skips the test when no database is configured.
+func newPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("Skipping pgx repository tests: TEST_DATABASE_URL not set")
+	}
+
+	pool, err := pgxstore.NewPool(context.Background(), dbURL, pgxstore.PoolOptions{MaxConns: 4, StatementCacheMode: "cache_statement"})
+	if err != nil {
+		t.Fatalf("failed to open pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	schema, err := os.ReadFile("../postgres/schema.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := pool.Exec(context.Background(), string(schema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+	return pool
+}
+
+func TestRepository(t *testing.T) {
+	pool := newPool(t)
+	ctx := context.Background()
+
+	cleanDB := func() {
+		if _, err := pool.Exec(ctx, "TRUNCATE TABLE todos"); err != nil {
+			t.Fatalf("failed to truncate table: %v", err)
+		}
+	}
+
+	t.Run("Save, FindByID and FindAll", func(t *testing.T) {
+		cleanDB()
+		repo := pgxstore.New(pool)
+		if err := repo.Save(ctx, todo.Todo{ID: "1", Title: "Original"}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if err := repo.Save(ctx, todo.Todo{ID: "1", Title: "Updated", Completed: true}); err != nil {
+			t.Fatalf("Save() update error = %v", err)
+		}
+		_ = repo.Save(ctx, todo.Todo{ID: "2", Title: "Other"})
+
+		found, err := repo.FindByID(ctx, "1")
+		if err != nil {
+			t.Fatalf("FindByID() error = %v", err)
+		}
+		if found.Title != "Updated" || !found.Completed || found.DeletedAt != nil {
+			t.Errorf("FindByID() got %+v", found)
+		}
+		if _, err := repo.FindByID(ctx, "missing"); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("FindByID() error = %v, want ErrNotFound", err)
+		}
+		if all, _ := repo.FindAll(ctx); len(all) != 2 {
+			t.Errorf("FindAll() got %d items, want 2", len(all))
+		}
+		var ids []string
+		_ = repo.ForEach(ctx, func(t todo.Todo) error {
+			ids = append(ids, t.ID)
+			return nil
+		})
+		if len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+			t.Errorf("ForEach() got %v, want 1 then 2", ids)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		cleanDB()
+		repo := pgxstore.New(pool)
+		_ = repo.Save(ctx, todo.Todo{ID: "3", Title: "To Delete"})
+		if err := repo.Delete(ctx, "3"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if err := repo.Delete(ctx, "3"); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("Delete() error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		cleanDB()
+		repo := pgxstore.New(pool)
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		earlier := now.Add(-time.Hour)
+		_ = repo.Save(ctx, todo.Todo{ID: "active", Title: "Active"})
+		_ = repo.Save(ctx, todo.Todo{ID: "old", Title: "Old", DeletedAt: &earlier})
+		_ = repo.Save(ctx, todo.Todo{ID: "new", Title: "New", DeletedAt: &now})
+
+		if all, _ := repo.FindAll(ctx); len(all) != 1 || all[0].ID != "active" {
+			t.Errorf("FindAll() got %+v, want only active todo", all)
+		}
+		found, err := repo.FindDeletedByID(ctx, "new")
+		if err != nil || found.DeletedAt == nil || !found.DeletedAt.Equal(now) {
+			t.Errorf("FindDeletedByID() got %+v (err=%v)", found, err)
+		}
+		if trash, _ := repo.FindDeleted(ctx); len(trash) != 2 || trash[0].ID != "new" {
+			t.Errorf("FindDeleted() got %+v, want new then old", trash)
+		}
+		purged, err := repo.PurgeDeleted(ctx, now.Add(-time.Minute))
+		if err != nil || len(purged) != 1 || purged[0].ID != "old" {
+			t.Errorf("PurgeDeleted() got %+v (err=%v), want old", purged, err)
+		}
+	})
+
+	t.Run("WithinTx", func(t *testing.T) {
+		cleanDB()
+		repo := pgxstore.New(pool)
+		audit := pgxstore.NewAuditRepository(pool)
+		errRollback := errors.New("rollback")
+
+		err := repo.WithinTx(ctx, func(ctx context.Context) error {
+			if err := repo.Save(ctx, todo.Todo{ID: "discarded", Title: "Discarded"}); err != nil {
+				return err
+			}
+			if err := audit.Append(ctx, todo.AuditEntry{ID: "pgx-tx-a1", TodoID: "discarded", Action: todo.EventCreated, At: time.Now()}); err != nil {
+				return err
+			}
+			return repo.WithinTx(ctx, func(ctx context.Context) error {
+				if _, err := repo.FindByID(ctx, "discarded"); err != nil {
+					return err
+				}
+				return errRollback
+			})
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("WithinTx() error = %v, want %v", err, errRollback)
+		}
+		if _, err := repo.FindByID(ctx, "discarded"); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("expected rolled back todo to be missing, got %v", err)
+		}
+		if entries, _ := audit.FindByTodoID(ctx, "discarded"); len(entries) != 0 {
+			t.Errorf("expected rolled back audit entry to be missing, got %+v", entries)
+		}
+	})
+}
+
+func TestAuditRepository(t *testing.T) {
+	pool := newPool(t)
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, "TRUNCATE TABLE todo_audit"); err != nil {
+		t.Fatalf("failed to truncate table: %v", err)
+	}
+
+	repo := pgxstore.NewAuditRepository(pool)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	before := todo.Todo{ID: "1", Title: "Before"}
+	after := todo.Todo{ID: "1", Title: "After", Completed: true}
+	entries := []todo.AuditEntry{
+		{ID: "a", TodoID: "1", Action: todo.EventCreated, Actor: "alice", RequestID: "req-1", After: &before, At: now},
+		{ID: "b", TodoID: "1", Action: todo.EventCompleted, Actor: "bob", Before: &before, After: &after, At: now.Add(time.Second)},
+	}
+	for _, e := range entries {
+		if err := repo.Append(ctx, e); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	found, err := repo.FindByTodoID(ctx, "1")
+	if err != nil {
+		t.Fatalf("FindByTodoID() error = %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("got %d entries, want 2", len(found))
+	}
+	if found[0].Before != nil || found[0].After.Title != "Before" || found[0].Action != todo.EventCreated {
+		t.Errorf("first entry = %+v", found[0])
+	}
+	if found[1].Before.Title != "Before" || !found[1].After.Completed || found[1].Actor != "bob" {
+		t.Errorf("second entry = %+v", found[1])
+	}
+}
@@ -0,0 +1,19 @@
+package pgxstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jllovet/go-server-template/internal/todo/pgxstore"
+	"github.com/jllovet/go-server-template/internal/todo/todotest"
+)
+
+func TestRepository_Search(t *testing.T) {
+	pool := newPool(t)
+	todotest.TestSearcher(t, func(t *testing.T) todotest.SearchRepository {
+		if _, err := pool.Exec(context.Background(), "TRUNCATE TABLE todos"); err != nil {
+			t.Fatalf("failed to truncate table: %v", err)
+		}
+		return pgxstore.New(pool)
+	})
+}
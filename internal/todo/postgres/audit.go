@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// AuditRepository implements todo.AuditRepository using PostgreSQL.
+// The todo_audit table rejects updates and deletes, see schema.sql.
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new Postgres audit log.
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Append inserts an entry into the log, as part of the transaction of ctx
+// if there is one.
+func (r *AuditRepository) Append(ctx context.Context, e todo.AuditEntry) error {
+	before, err := marshalSnapshot(e.Before)
+	if err != nil {
+		return fmt.Errorf("postgres audit append: %w", err)
+	}
+	after, err := marshalSnapshot(e.After)
+	if err != nil {
+		return fmt.Errorf("postgres audit append: %w", err)
+	}
+	query := `
+		INSERT INTO todo_audit (id, todo_id, action, actor, request_id, before, after, at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err = conn(ctx, r.db).ExecContext(ctx, query, e.ID, e.TodoID, string(e.Action), e.Actor, e.RequestID, before, after, e.At)
+	if err != nil {
+		return fmt.Errorf("postgres audit append: %w", err)
+	}
+	return nil
+}
+
+// FindByTodoID retrieves the entries for a todo, oldest first.
+func (r *AuditRepository) FindByTodoID(ctx context.Context, todoID string) ([]todo.AuditEntry, error) {
+	query := `
+		SELECT id, todo_id, action, actor, request_id, before, after, at
+		FROM todo_audit WHERE todo_id = $1 ORDER BY at, id
+	`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres audit find: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []todo.AuditEntry
+	for rows.Next() {
+		var e todo.AuditEntry
+		var action string
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.TodoID, &action, &e.Actor, &e.RequestID, &before, &after, &e.At); err != nil {
+			return nil, fmt.Errorf("postgres scan: %w", err)
+		}
+		e.Action = todo.EventType(action)
+		if e.Before, err = unmarshalSnapshot(before); err != nil {
+			return nil, fmt.Errorf("postgres scan: %w", err)
+		}
+		if e.After, err = unmarshalSnapshot(after); err != nil {
+			return nil, fmt.Errorf("postgres scan: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// marshalSnapshot encodes a snapshot as JSON text, or NULL when absent.
+func marshalSnapshot(t *todo.Todo) (any, error) {
+	if t == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func unmarshalSnapshot(b []byte) (*todo.Todo, error) {
+	if b == nil {
+		return nil, nil
+	}
+	var t todo.Todo
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
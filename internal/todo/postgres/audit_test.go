@@ -0,0 +1,80 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/postgres"
+)
+
+func TestAuditRepository(t *testing.T) {
+	// Skip if TEST_DATABASE_URL is not set.
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("Skipping postgres audit tests: TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("pgx", dbURL)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+	if _, err := db.Exec("TRUNCATE TABLE todo_audit"); err != nil {
+		t.Fatalf("failed to truncate table: %v", err)
+	}
+
+	ctx := context.Background()
+	repo := postgres.NewAuditRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	before := todo.Todo{ID: "1", Title: "Before"}
+	after := todo.Todo{ID: "1", Title: "After", Completed: true}
+
+	t.Run("Append and FindByTodoID", func(t *testing.T) {
+		entries := []todo.AuditEntry{
+			{ID: "a", TodoID: "1", Action: todo.EventCreated, Actor: "alice", RequestID: "req-1", After: &before, At: now},
+			{ID: "b", TodoID: "1", Action: todo.EventCompleted, Actor: "bob", Before: &before, After: &after, At: now.Add(time.Second)},
+			{ID: "c", TodoID: "2", Action: todo.EventCreated, Actor: "alice", At: now},
+		}
+		for _, e := range entries {
+			if err := repo.Append(ctx, e); err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+		}
+
+		found, err := repo.FindByTodoID(ctx, "1")
+		if err != nil {
+			t.Fatalf("FindByTodoID() error = %v", err)
+		}
+		if len(found) != 2 {
+			t.Fatalf("got %d entries, want 2", len(found))
+		}
+		if found[0].Before != nil || found[0].After.Title != "Before" || found[0].RequestID != "req-1" {
+			t.Errorf("first entry = %+v", found[0])
+		}
+		if found[1].Before.Title != "Before" || !found[1].After.Completed || found[1].Actor != "bob" {
+			t.Errorf("second entry = %+v", found[1])
+		}
+	})
+
+	t.Run("Entries cannot be modified", func(t *testing.T) {
+		if _, err := db.Exec("UPDATE todo_audit SET actor = 'mallory'"); err == nil {
+			t.Error("expected UPDATE to be rejected")
+		}
+		if _, err := db.Exec("DELETE FROM todo_audit"); err == nil {
+			t.Error("expected DELETE to be rejected")
+		}
+	})
+}
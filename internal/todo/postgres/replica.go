@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// DefaultReadYourWritesWindow is how long after a write the reads of the
+// same context keep going to the primary when WithReplica is given no window.
+const DefaultReadYourWritesWindow = 5 * time.Second
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithReplica sends FindByID and FindAll to replica, except inside a
+// transaction, for todo.WithPrimaryReads contexts, and for window after
+// a context tracked by todo.TrackWrites last wrote, so that a client sees
+// its own changes despite replication lag. Writes always go to the primary.
+func WithReplica(replica *sql.DB, window time.Duration) Option {
+	return func(r *Repository) {
+		if window <= 0 {
+			window = DefaultReadYourWritesWindow
+		}
+		r.replica = replica
+		r.window = window
+		r.replicaHealthy.Store(true)
+	}
+}
+
+// ReplicaHealthy reports whether reads are currently sent to the replica.
+// It is always false without one.
+func (r *Repository) ReplicaHealthy() bool {
+	return r.replica != nil && r.replicaHealthy.Load()
+}
+
+// MonitorReplica pings the replica every interval until ctx is cancelled.
+// A failed ping, or a failed read, sends reads to the primary until a ping
+// succeeds again.
+func (r *Repository) MonitorReplica(ctx context.Context, interval time.Duration) {
+	if r.replica == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			err := r.replica.PingContext(pingCtx)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				r.setReplicaHealthy(ctx, false, err)
+				continue
+			}
+			r.setReplicaHealthy(ctx, true, nil)
+		}
+	}
+}
+
+func (r *Repository) setReplicaHealthy(ctx context.Context, healthy bool, err error) {
+	if r.replicaHealthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		logger.FromContext(ctx).Info("replica recovered, reading from it again")
+		return
+	}
+	logger.FromContext(ctx).Warn("replica unhealthy, reading from primary", "error", err)
+}
+
+// partialError is a failure of a read that has already handed out some of
+// its rows, so that it cannot be run again without handing them out twice.
+type partialError struct {
+	err error
+}
+
+func (e *partialError) Error() string {
+	return e.err.Error()
+}
+
+// read runs fn against the replica when ctx may use it, and against the
+// primary (or the transaction of ctx) otherwise. A replica failure that is
+// not the caller's doing marks it unhealthy and retries on the primary.
+func (r *Repository) read(ctx context.Context, fn func(q querier) error) error {
+	if r.replica == nil || inTx(ctx) || !r.replicaHealthy.Load() || todo.NeedsPrimary(ctx, r.window) {
+		return fn(conn(ctx, r.db))
+	}
+	err := fn(r.replica)
+	var partial *partialError
+	if err == nil || errors.Is(err, todo.ErrNotFound) || errors.As(err, &partial) || ctx.Err() != nil {
+		return err
+	}
+	r.setReplicaHealthy(ctx, false, err)
+	return fn(r.db)
+}
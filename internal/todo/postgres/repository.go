@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/jllovet/go-server-template/internal/todo"
 )
@@ -12,47 +14,194 @@ import (
 // Repository implements todo.Repository using PostgreSQL.
 type Repository struct {
 	db *sql.DB
+
+	// Optional read replica; see WithReplica.
+	replica        *sql.DB
+	window         time.Duration
+	replicaHealthy atomic.Bool
 }
 
 // New creates a new Postgres repository.
-func New(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func New(db *sql.DB, opts ...Option) *Repository {
+	r := &Repository{db: db}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithinTx runs fn in a single SQL transaction. Repository and audit log
+// calls made with the context passed to fn take part in it.
+func (r *Repository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.db, fn)
 }
 
 // Save creates or updates a todo.
 func (r *Repository) Save(ctx context.Context, t todo.Todo) error {
 	query := `
-		INSERT INTO todos (id, title, completed)
-		VALUES ($1, $2, $3)
+		INSERT INTO todos (id, title, completed, deleted_at)
+		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (id) DO UPDATE
-		SET title = EXCLUDED.title, completed = EXCLUDED.completed
+		SET title = EXCLUDED.title, completed = EXCLUDED.completed, deleted_at = EXCLUDED.deleted_at
 	`
-	_, err := r.db.ExecContext(ctx, query, t.ID, t.Title, t.Completed)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, t.ID, t.Title, t.Completed, t.DeletedAt)
 	if err != nil {
 		return fmt.Errorf("postgres save: %w", err)
 	}
+	todo.RecordWrite(ctx)
 	return nil
 }
 
-// FindByID retrieves a todo by ID.
+// FindByID retrieves a todo by ID, unless it is in the trash. Inside a
+// transaction the row stays locked until it ends, so that a read followed
+// by a Save cannot lose a concurrent update.
 func (r *Repository) FindByID(ctx context.Context, id string) (todo.Todo, error) {
-	query := `SELECT id, title, completed FROM todos WHERE id = $1`
-	row := r.db.QueryRowContext(ctx, query, id)
-
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE id = $1 AND deleted_at IS NULL`
 	var t todo.Todo
-	if err := row.Scan(&t.ID, &t.Title, &t.Completed); err != nil {
+	err := r.read(ctx, func(q querier) (err error) {
+		t, err = r.findOne(ctx, q, query, id)
+		return err
+	})
+	return t, err
+}
+
+// FindAll retrieves all todos that are not in the trash.
+func (r *Repository) FindAll(ctx context.Context) ([]todo.Todo, error) {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE deleted_at IS NULL`
+	var todos []todo.Todo
+	err := r.read(ctx, func(q querier) (err error) {
+		todos, err = r.findMany(ctx, q, query)
+		return err
+	})
+	return todos, err
+}
+
+// ForEach calls fn with each todo that is not in the trash as its row is
+// read, from the replica like FindAll. Once fn has been called a failure
+// is returned rather than retried on the primary, which would call fn
+// with the same todos again.
+func (r *Repository) ForEach(ctx context.Context, fn func(todo.Todo) error) error {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE deleted_at IS NULL ORDER BY id`
+	err := r.read(ctx, func(q querier) error {
+		rows, err := q.QueryContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("postgres find all: %w", err)
+		}
+		defer rows.Close()
+
+		started := false
+		for rows.Next() {
+			t, err := scanTodo(rows)
+			if err != nil {
+				return &partialError{fmt.Errorf("postgres scan: %w", err)}
+			}
+			started = true
+			if err := fn(t); err != nil {
+				return &partialError{err}
+			}
+		}
+		if err := rows.Err(); err != nil && started {
+			return &partialError{err}
+		}
+		return rows.Err()
+	})
+	var partial *partialError
+	if errors.As(err, &partial) {
+		return partial.err
+	}
+	return err
+}
+
+// Delete permanently removes a todo by ID.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM todos WHERE id = $1`
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("postgres delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return todo.ErrNotFound
+	}
+	todo.RecordWrite(ctx)
+	return nil
+}
+
+// FindDeletedByID retrieves a todo by ID if it is in the trash, locking it
+// like FindByID.
+func (r *Repository) FindDeletedByID(ctx context.Context, id string) (todo.Todo, error) {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE id = $1 AND deleted_at IS NOT NULL`
+	return r.findOne(ctx, conn(ctx, r.db), query, id)
+}
+
+// FindDeleted retrieves the todos in the trash, most recently deleted first.
+func (r *Repository) FindDeleted(ctx context.Context) ([]todo.Todo, error) {
+	query := `SELECT id, title, completed, deleted_at FROM todos WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC`
+	return r.findMany(ctx, conn(ctx, r.db), query)
+}
+
+// PurgeDeleted permanently removes todos deleted before the given time.
+func (r *Repository) PurgeDeleted(ctx context.Context, before time.Time) ([]todo.Todo, error) {
+	query := `DELETE FROM todos WHERE deleted_at < $1 RETURNING id, title, completed, deleted_at`
+	purged, err := r.findMany(ctx, conn(ctx, r.db), query, before)
+	if err == nil {
+		todo.RecordWrite(ctx)
+	}
+	return purged, err
+}
+
+// searchQuery ranks active todos against a websearch-style query.
+const searchQuery = `
+	SELECT id, title, completed, deleted_at, ts_rank(search, query)::float8 AS rank
+	FROM todos, websearch_to_tsquery('english', $1) AS query
+	WHERE deleted_at IS NULL AND search @@ query
+	ORDER BY rank DESC, id
+	LIMIT $2
+`
+
+// Search finds todos using the generated search column and its GIN index,
+// ranked with ts_rank.
+func (r *Repository) Search(ctx context.Context, query string, limit int) ([]todo.SearchResult, error) {
+	// LIMIT NULL means no limit.
+	var n any
+	if limit > 0 {
+		n = limit
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, searchQuery, query, n)
+	if err != nil {
+		return nil, fmt.Errorf("postgres search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []todo.SearchResult
+	for rows.Next() {
+		var res todo.SearchResult
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&res.Todo.ID, &res.Todo.Title, &res.Todo.Completed, &deletedAt, &res.Rank); err != nil {
+			return nil, fmt.Errorf("postgres scan: %w", err)
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+func (r *Repository) findOne(ctx context.Context, q querier, query string, args ...any) (todo.Todo, error) {
+	if inTx(ctx) {
+		query += " FOR UPDATE"
+	}
+	t, err := scanTodo(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return todo.Todo{}, fmt.Errorf("todo not found")
+			return todo.Todo{}, todo.ErrNotFound
 		}
 		return todo.Todo{}, fmt.Errorf("postgres find by id: %w", err)
 	}
 	return t, nil
 }
 
-// FindAll retrieves all todos.
-func (r *Repository) FindAll(ctx context.Context) ([]todo.Todo, error) {
-	query := `SELECT id, title, completed FROM todos`
-	rows, err := r.db.QueryContext(ctx, query)
+func (r *Repository) findMany(ctx context.Context, q querier, query string, args ...any) ([]todo.Todo, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("postgres find all: %w", err)
 	}
@@ -60,27 +209,28 @@ func (r *Repository) FindAll(ctx context.Context) ([]todo.Todo, error) {
 
 	var todos []todo.Todo
 	for rows.Next() {
-		var t todo.Todo
-		if err := rows.Scan(&t.ID, &t.Title, &t.Completed); err != nil {
+		t, err := scanTodo(rows)
+		if err != nil {
 			return nil, fmt.Errorf("postgres scan: %w", err)
 		}
 		todos = append(todos, t)
 	}
-	return todos, nil
+	return todos, rows.Err()
 }
 
-// Delete removes a todo by ID.
-func (r *Repository) Delete(ctx context.Context, id string) error {
-	query := `DELETE FROM todos WHERE id = $1`
-	res, err := r.db.ExecContext(ctx, query, id)
-	if err != nil {
-		return fmt.Errorf("postgres delete: %w", err)
-	}
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
 
-	n, err := res.RowsAffected()
-	if err != nil || n == 0 {
-		return fmt.Errorf("todo not found")
+func scanTodo(row scanner) (todo.Todo, error) {
+	var t todo.Todo
+	var deletedAt sql.NullTime
+	if err := row.Scan(&t.ID, &t.Title, &t.Completed, &deletedAt); err != nil {
+		return todo.Todo{}, err
 	}
-
-	return nil
+	if deletedAt.Valid {
+		t.DeletedAt = &deletedAt.Time
+	}
+	return t, nil
 }
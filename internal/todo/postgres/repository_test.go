@@ -3,8 +3,10 @@ package postgres_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jllovet/go-server-template/internal/todo"
@@ -28,15 +30,12 @@ func TestRepository(t *testing.T) {
 		t.Fatalf("failed to ping db: %v", err)
 	}
 
-	// Ensure table exists for tests
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS todos (
-			id TEXT PRIMARY KEY,
-			title TEXT NOT NULL,
-			completed BOOLEAN NOT NULL DEFAULT FALSE
-		);
-	`)
+	// Ensure tables exist for tests
+	schema, err := os.ReadFile("schema.sql")
 	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
 		t.Fatalf("failed to create table: %v", err)
 	}
 
@@ -124,6 +123,15 @@ func TestRepository(t *testing.T) {
 		if len(all) != 2 {
 			t.Errorf("got %d items, want 2", len(all))
 		}
+
+		var ids []string
+		err = repo.ForEach(ctx, func(t todo.Todo) error {
+			ids = append(ids, t.ID)
+			return nil
+		})
+		if err != nil || len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+			t.Errorf("ForEach() = %v after %v, want 1 then 2", err, ids)
+		}
 	})
 
 	t.Run("Delete", func(t *testing.T) {
@@ -149,4 +157,124 @@ func TestRepository(t *testing.T) {
 			t.Error("Delete() expected error for non-existent item, got nil")
 		}
 	})
+	t.Run("Trash", func(t *testing.T) {
+		cleanDB()
+		repo := postgres.New(db)
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		earlier := now.Add(-time.Hour)
+		_ = repo.Save(ctx, todo.Todo{ID: "active", Title: "Active"})
+		_ = repo.Save(ctx, todo.Todo{ID: "old", Title: "Old", DeletedAt: &earlier})
+		_ = repo.Save(ctx, todo.Todo{ID: "new", Title: "New", DeletedAt: &now})
+
+		all, _ := repo.FindAll(ctx)
+		if len(all) != 1 || all[0].ID != "active" {
+			t.Errorf("FindAll() got %+v, want only active todo", all)
+		}
+		if _, err := repo.FindByID(ctx, "old"); err == nil {
+			t.Error("FindByID() expected error for trashed item, got nil")
+		}
+
+		found, err := repo.FindDeletedByID(ctx, "new")
+		if err != nil {
+			t.Fatalf("FindDeletedByID() error = %v", err)
+		}
+		if found.DeletedAt == nil || !found.DeletedAt.Equal(now) {
+			t.Errorf("got deleted_at %v, want %v", found.DeletedAt, now)
+		}
+
+		trash, _ := repo.FindDeleted(ctx)
+		if len(trash) != 2 || trash[0].ID != "new" {
+			t.Errorf("FindDeleted() got %+v, want new then old", trash)
+		}
+
+		purged, err := repo.PurgeDeleted(ctx, now.Add(-time.Minute))
+		if err != nil {
+			t.Fatalf("PurgeDeleted() error = %v", err)
+		}
+		if len(purged) != 1 || purged[0].ID != "old" {
+			t.Errorf("PurgeDeleted() got %+v, want old", purged)
+		}
+
+		// Restoring is a Save with DeletedAt cleared.
+		found.DeletedAt = nil
+		_ = repo.Save(ctx, found)
+		if _, err := repo.FindByID(ctx, "new"); err != nil {
+			t.Errorf("FindByID() after restore error = %v", err)
+		}
+	})
+	t.Run("WithinTx", func(t *testing.T) {
+		cleanDB()
+		repo := postgres.New(db)
+		audit := postgres.NewAuditRepository(db)
+		errRollback := errors.New("rollback")
+
+		err := repo.WithinTx(ctx, func(ctx context.Context) error {
+			if err := repo.Save(ctx, todo.Todo{ID: "discarded", Title: "Discarded"}); err != nil {
+				return err
+			}
+			if err := audit.Append(ctx, todo.AuditEntry{ID: "tx-a1", TodoID: "discarded", Action: todo.EventCreated, At: time.Now()}); err != nil {
+				return err
+			}
+			// Nested units of work join the outer one.
+			return repo.WithinTx(ctx, func(ctx context.Context) error {
+				if _, err := repo.FindByID(ctx, "discarded"); err != nil {
+					return err
+				}
+				return errRollback
+			})
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("WithinTx() error = %v, want %v", err, errRollback)
+		}
+		if _, err := repo.FindByID(ctx, "discarded"); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("expected rolled back todo to be missing, got %v", err)
+		}
+		if entries, _ := audit.FindByTodoID(ctx, "discarded"); len(entries) != 0 {
+			t.Errorf("expected rolled back audit entry to be missing, got %+v", entries)
+		}
+
+		err = repo.WithinTx(ctx, func(ctx context.Context) error {
+			return repo.Save(ctx, todo.Todo{ID: "kept", Title: "Kept"})
+		})
+		if err != nil {
+			t.Fatalf("WithinTx() error = %v", err)
+		}
+		if _, err := repo.FindByID(ctx, "kept"); err != nil {
+			t.Errorf("expected committed todo, got %v", err)
+		}
+	})
+	t.Run("Replica", func(t *testing.T) {
+		cleanDB()
+		// A closed handle stands in for a replica that has gone away.
+		replica, err := sql.Open("pgx", dbURL)
+		if err != nil {
+			t.Fatalf("failed to open replica: %v", err)
+		}
+		replica.Close()
+		repo := postgres.New(db, postgres.WithReplica(replica, time.Minute))
+
+		// Reads after a write in the same context go to the primary.
+		tracked := todo.TrackWrites(ctx)
+		if err := repo.Save(tracked, todo.Todo{ID: "1", Title: "Written"}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if _, err := repo.FindByID(tracked, "1"); err != nil {
+			t.Fatalf("FindByID() error = %v", err)
+		}
+		if !repo.ReplicaHealthy() {
+			t.Fatal("expected read-your-writes read to skip the replica")
+		}
+
+		// Other reads try the replica, then fall back to the primary.
+		all, err := repo.FindAll(ctx)
+		if err != nil {
+			t.Fatalf("FindAll() error = %v", err)
+		}
+		if len(all) != 1 {
+			t.Errorf("got %d items, want 1", len(all))
+		}
+		if repo.ReplicaHealthy() {
+			t.Error("expected failed replica to be marked unhealthy")
+		}
+	})
 }
@@ -0,0 +1,10 @@
+package postgres
+
+import _ "embed"
+
+// Schema creates or updates the todos and todo_audit tables used by
+// Repository and AuditRepository, and the triggers behind change
+// notifications. Applying it again changes nothing.
+//
+//go:embed schema.sql
+var Schema string
@@ -2,4 +2,81 @@ CREATE TABLE IF NOT EXISTS todos (
     id TEXT PRIMARY KEY,
     title TEXT NOT NULL,
     completed BOOLEAN NOT NULL DEFAULT FALSE
-);
\ No newline at end of file
+);
+
+-- Todos with deleted_at set are in the trash.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS todos_deleted_at_idx ON todos (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Full-text search over titles.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS search TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('english', title)) STORED;
+CREATE INDEX IF NOT EXISTS todos_search_idx ON todos USING GIN (search);
+
+CREATE TABLE IF NOT EXISTS todo_audit (
+    id TEXT PRIMARY KEY,
+    todo_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS todo_audit_todo_idx ON todo_audit (todo_id, at);
+
+-- The audit log is append-only: reject any attempt to rewrite it.
+CREATE OR REPLACE FUNCTION todo_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'todo_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS todo_audit_append_only ON todo_audit;
+CREATE TRIGGER todo_audit_append_only
+    BEFORE UPDATE OR DELETE ON todo_audit
+    FOR EACH ROW EXECUTE FUNCTION todo_audit_append_only();
+
+-- Every committed change to a todo is announced on the todo_changes channel
+-- so that other server instances can react to it. The payload names the
+-- event type and todo, and the application_name of the session that made
+-- the change so an instance can skip its own.
+CREATE OR REPLACE FUNCTION todo_notify_change() RETURNS trigger AS $$
+DECLARE
+    rec todos;
+    event TEXT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        rec := NEW;
+        event := 'todo.created';
+    ELSIF TG_OP = 'DELETE' THEN
+        rec := OLD;
+        event := 'todo.purged';
+    ELSE
+        rec := NEW;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            event := 'todo.deleted';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            event := 'todo.restored';
+        ELSIF OLD.completed IS DISTINCT FROM NEW.completed THEN
+            event := CASE WHEN NEW.completed THEN 'todo.completed' ELSE 'todo.reopened' END;
+        ELSIF OLD.title IS DISTINCT FROM NEW.title THEN
+            event := 'todo.updated';
+        ELSE
+            RETURN NULL;
+        END IF;
+    END IF;
+    PERFORM pg_notify('todo_changes', json_build_object(
+        'type', event,
+        'id', rec.id,
+        'origin', current_setting('application_name')
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS todos_notify_change ON todos;
+CREATE TRIGGER todos_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON todos
+    FOR EACH ROW EXECUTE FUNCTION todo_notify_change();
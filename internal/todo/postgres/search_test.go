@@ -0,0 +1,40 @@
+package postgres_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jllovet/go-server-template/internal/todo/postgres"
+	"github.com/jllovet/go-server-template/internal/todo/todotest"
+)
+
+func TestRepository_Search(t *testing.T) {
+	// Skip if TEST_DATABASE_URL is not set.
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("Skipping postgres search tests: TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("pgx", dbURL)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	todotest.TestSearcher(t, func(t *testing.T) todotest.SearchRepository {
+		if _, err := db.Exec("TRUNCATE TABLE todos"); err != nil {
+			t.Fatalf("failed to truncate table: %v", err)
+		}
+		return postgres.New(db)
+	})
+}
@@ -0,0 +1,41 @@
+package todo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// PurgerActor is the actor recorded for todos purged from the trash.
+const PurgerActor = "system:purger"
+
+// Purger permanently removes todos that have been in the trash for longer
+// than the retention period.
+type Purger struct {
+	service   Service
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewPurger creates a Purger that checks the trash every interval.
+func NewPurger(service Service, retention, interval time.Duration) *Purger {
+	return &Purger{service: service, retention: retention, interval: interval}
+}
+
+// Run purges the trash immediately and then every interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ctx = WithActor(ctx, PurgerActor)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.service.PurgeTrash(ctx, time.Now().Add(-p.retention)); err != nil && ctx.Err() == nil {
+			logger.FromContext(ctx).Error("trash purge failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package todo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+func TestPurger(t *testing.T) {
+	repo := newMockRepository()
+	audit := &mockAuditRepository{}
+	service := todo.NewService(repo, todo.WithAudit(audit))
+	ctx := context.Background()
+
+	created, _ := service.Create(ctx, "Short-lived")
+	_ = service.Delete(ctx, created.ID)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		todo.NewPurger(service, time.Millisecond, 5*time.Millisecond).Run(runCtx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if trash, _ := service.ListTrash(ctx); len(trash) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for purge")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	history, _ := service.History(ctx, created.ID)
+	if last := history[len(history)-1]; last.Action != todo.EventPurged || last.Actor != todo.PurgerActor {
+		t.Errorf("last history entry = %+v, want purge by %s", last, todo.PurgerActor)
+	}
+}
@@ -0,0 +1,127 @@
+package resilience
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// ErrCircuitOpen is returned without calling the database while the
+// circuit breaker is open.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker open", todo.ErrUnavailable)
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open fails every call until the cooldown has passed.
+	Open
+	// HalfOpen lets a single trial call through to decide whether to close
+	// again.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// breaker opens after threshold consecutive failures and lets a trial call
+// through once cooldown has passed.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	// onChange is called, without the lock held, on every state change.
+	onChange func(from, to State)
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// allow reports whether a call may go ahead. While half-open only the
+// first caller is allowed; it must report its outcome with done.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case Closed:
+		b.mu.Unlock()
+		return true
+	case Open:
+		if time.Since(b.openedAt) < b.cooldown {
+			b.mu.Unlock()
+			return false
+		}
+		b.state = HalfOpen
+	}
+	if b.trial {
+		b.mu.Unlock()
+		return false
+	}
+	b.trial = true
+	to := b.state
+	b.mu.Unlock()
+	b.changed(from, to)
+	return true
+}
+
+// done records the outcome of an allowed call.
+func (b *breaker) done(failed bool) {
+	b.mu.Lock()
+	from := b.state
+	b.trial = false
+	switch {
+	case !failed:
+		b.state = Closed
+		b.failures = 0
+	case b.state == HalfOpen:
+		b.state = Open
+		b.openedAt = time.Now()
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state = Open
+			b.openedAt = time.Now()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.changed(from, to)
+}
+
+// release ends an allowed call without recording an outcome, letting
+// another trial through while half-open.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *breaker) current() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && time.Since(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *breaker) changed(from, to State) {
+	if from != to && b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
@@ -0,0 +1,265 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// Options configures a Repository. Zero values use the defaults.
+type Options struct {
+	// MaxAttempts is how many times an operation is tried, 3 by default.
+	MaxAttempts int
+	// Backoff is the base delay before a retry, 50ms by default. Each
+	// retry waits a random time up to Backoff doubled per attempt, capped
+	// at MaxBackoff (1s by default).
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// FailureThreshold is how many operations in a row must fail with a
+	// retryable error, after their retries, for the breaker to open; 5 by
+	// default.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before letting a trial
+	// operation through, 30s by default.
+	Cooldown time.Duration
+	// Logger receives breaker state changes. Optional.
+	Logger *slog.Logger
+}
+
+// Repository retries the transient failures of another todo.Repository
+// and guards it with a circuit breaker.
+//
+// Operations outside a unit of work are retried on their own. Inside one
+// the database has already aborted the transaction, so the error is passed
+// up and the whole unit of work retried by the outermost WithinTx.
+// Operations that fail for other reasons, such as a missing todo, neither
+// retry nor count against the breaker.
+type Repository struct {
+	next    todo.Repository
+	opts    Options
+	breaker *breaker
+}
+
+// New wraps next.
+func New(next todo.Repository, opts Options) *Repository {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 50 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Second
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 5
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+	r := &Repository{next: next, opts: opts}
+	r.breaker = &breaker{
+		threshold: opts.FailureThreshold,
+		cooldown:  opts.Cooldown,
+		onChange: func(from, to State) {
+			if opts.Logger != nil {
+				opts.Logger.Warn("todo repository circuit breaker changed state", "from", from, "to", to)
+			}
+		},
+	}
+	return r
+}
+
+// State returns the state of the circuit breaker.
+func (r *Repository) State() State {
+	return r.breaker.current()
+}
+
+// Ready returns ErrCircuitOpen while the breaker is open, for readiness
+// checks.
+func (r *Repository) Ready(ctx context.Context) error {
+	if r.State() == Open {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// txKey marks contexts inside WithinTx.
+type txKey struct{}
+
+func inTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(bool)
+	return ok
+}
+
+// do runs op with retries behind the breaker, unless ctx is inside a unit
+// of work, whose outermost WithinTx does that for it.
+func (r *Repository) do(ctx context.Context, op func(ctx context.Context) error) error {
+	if inTx(ctx) {
+		return op(ctx)
+	}
+	if !r.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = op(ctx)
+		if !Retryable(err) || attempt+1 >= r.opts.MaxAttempts || !r.sleep(ctx, attempt) {
+			break
+		}
+	}
+	failed := Retryable(err)
+	var final *finalError
+	if errors.As(err, &final) {
+		err, failed = final.err, final.counts && Retryable(final.err)
+	}
+	if ctx.Err() != nil {
+		// A caller giving up says nothing about the database.
+		r.breaker.release()
+	} else {
+		r.breaker.done(failed)
+	}
+	return err
+}
+
+// finalError keeps do from retrying err, which still counts against the
+// breaker if counts is set.
+type finalError struct {
+	err    error
+	counts bool
+}
+
+func (e *finalError) Error() string {
+	return e.err.Error()
+}
+
+// sleep waits before retry number attempt+1 and reports whether to go on:
+// it gives up early rather than wait past the deadline of ctx.
+func (r *Repository) sleep(ctx context.Context, attempt int) bool {
+	ceiling := r.opts.Backoff << attempt
+	if ceiling <= 0 || ceiling > r.opts.MaxBackoff {
+		ceiling = r.opts.MaxBackoff
+	}
+	d := rand.N(ceiling) + 1
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// WithinTx runs fn in a unit of work of the wrapped repository, running it
+// again in a fresh one after a transient failure. A commit that may have
+// happened is not run again, lest the unit of work be applied twice.
+func (r *Repository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if inTx(ctx) {
+		return r.next.WithinTx(ctx, fn)
+	}
+	return r.do(ctx, func(ctx context.Context) error {
+		committing := false
+		err := r.next.WithinTx(ctx, func(ctx context.Context) error {
+			err := fn(context.WithValue(ctx, txKey{}, true))
+			committing = err == nil
+			return err
+		})
+		if err != nil && committing && commitUnknown(err) {
+			return &finalError{err: err, counts: true}
+		}
+		return err
+	})
+}
+
+// commitUnknown reports whether a commit that failed with err may still
+// have happened: unless the server answered with an error, which rolls the
+// transaction back, or the COMMIT was never sent, the connection may have
+// been lost after the server committed.
+func commitUnknown(err error) bool {
+	var pgErr *pgconn.PgError
+	return !errors.As(err, &pgErr) && !pgconn.SafeToRetry(err)
+}
+
+func (r *Repository) Save(ctx context.Context, t todo.Todo) error {
+	return r.do(ctx, func(ctx context.Context) error {
+		return r.next.Save(ctx, t)
+	})
+}
+
+func (r *Repository) FindByID(ctx context.Context, id string) (todo.Todo, error) {
+	var t todo.Todo
+	err := r.do(ctx, func(ctx context.Context) (err error) {
+		t, err = r.next.FindByID(ctx, id)
+		return err
+	})
+	return t, err
+}
+
+func (r *Repository) FindAll(ctx context.Context) ([]todo.Todo, error) {
+	var todos []todo.Todo
+	err := r.do(ctx, func(ctx context.Context) (err error) {
+		todos, err = r.next.FindAll(ctx)
+		return err
+	})
+	return todos, err
+}
+
+// ForEach is only retried until fn has been called: a later failure is
+// returned as it is, so that fn is not given the same todos twice, and does
+// not count against the breaker.
+func (r *Repository) ForEach(ctx context.Context, fn func(todo.Todo) error) error {
+	return r.do(ctx, func(ctx context.Context) error {
+		started := false
+		err := r.next.ForEach(ctx, func(t todo.Todo) error {
+			started = true
+			return fn(t)
+		})
+		if err != nil && started {
+			return &finalError{err: err}
+		}
+		return err
+	})
+}
+
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	return r.do(ctx, func(ctx context.Context) error {
+		return r.next.Delete(ctx, id)
+	})
+}
+
+func (r *Repository) FindDeletedByID(ctx context.Context, id string) (todo.Todo, error) {
+	var t todo.Todo
+	err := r.do(ctx, func(ctx context.Context) (err error) {
+		t, err = r.next.FindDeletedByID(ctx, id)
+		return err
+	})
+	return t, err
+}
+
+func (r *Repository) FindDeleted(ctx context.Context) ([]todo.Todo, error) {
+	var todos []todo.Todo
+	err := r.do(ctx, func(ctx context.Context) (err error) {
+		todos, err = r.next.FindDeleted(ctx)
+		return err
+	})
+	return todos, err
+}
+
+func (r *Repository) PurgeDeleted(ctx context.Context, before time.Time) ([]todo.Todo, error) {
+	var purged []todo.Todo
+	err := r.do(ctx, func(ctx context.Context) (err error) {
+		purged, err = r.next.PurgeDeleted(ctx, before)
+		return err
+	})
+	return purged, err
+}
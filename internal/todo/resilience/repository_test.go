@@ -0,0 +1,266 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/internal/todo/resilience"
+)
+
+var errSerialization = &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+
+// flakyRepo fails the next `failures` calls with err.
+type flakyRepo struct {
+	todo.Repository
+	failures atomic.Int64
+	err      error
+	calls    atomic.Int64
+}
+
+func (r *flakyRepo) fail() error {
+	r.calls.Add(1)
+	if r.failures.Add(-1) >= 0 {
+		return r.err
+	}
+	return nil
+}
+
+func (r *flakyRepo) FindByID(ctx context.Context, id string) (todo.Todo, error) {
+	if err := r.fail(); err != nil {
+		return todo.Todo{}, err
+	}
+	return r.Repository.FindByID(ctx, id)
+}
+
+func (r *flakyRepo) Save(ctx context.Context, t todo.Todo) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.Repository.Save(ctx, t)
+}
+
+// ForEach fails after passing on every todo.
+func (r *flakyRepo) ForEach(ctx context.Context, fn func(todo.Todo) error) error {
+	if err := r.Repository.ForEach(ctx, fn); err != nil {
+		return err
+	}
+	return r.fail()
+}
+
+// commitFailRepo fails the commit of every unit of work with err, after
+// fn has run.
+type commitFailRepo struct {
+	todo.Repository
+	err error
+}
+
+func (r *commitFailRepo) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := r.Repository.WithinTx(ctx, fn); err != nil {
+		return err
+	}
+	return r.err
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errSerialization, true},
+		{&pgconn.PgError{Code: "40P01"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{fmt.Errorf("postgres find: %w", syscall.ECONNRESET), true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{todo.ErrNotFound, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := resilience.Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	opts := resilience.Options{
+		MaxAttempts:      3,
+		Backoff:          time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		FailureThreshold: 2,
+		Cooldown:         20 * time.Millisecond,
+	}
+	setup := func(err error, failures int64) (*resilience.Repository, *flakyRepo) {
+		next := &flakyRepo{Repository: memory.New(), err: err}
+		_ = next.Repository.Save(ctx, todo.Todo{ID: "1", Title: "One"})
+		next.failures.Store(failures)
+		return resilience.New(next, opts), next
+	}
+
+	t.Run("Retries Transient Failures", func(t *testing.T) {
+		repo, next := setup(errSerialization, 2)
+		if _, err := repo.FindByID(ctx, "1"); err != nil {
+			t.Fatalf("FindByID() error = %v", err)
+		}
+		if n := next.calls.Load(); n != 3 {
+			t.Errorf("got %d calls, want 3", n)
+		}
+	})
+
+	t.Run("Gives Up After MaxAttempts", func(t *testing.T) {
+		repo, next := setup(errSerialization, 5)
+		if _, err := repo.FindByID(ctx, "1"); !errors.Is(err, errSerialization) {
+			t.Fatalf("FindByID() error = %v, want %v", err, errSerialization)
+		}
+		if n := next.calls.Load(); n != 3 {
+			t.Errorf("got %d calls, want 3", n)
+		}
+	})
+
+	t.Run("Does Not Retry Other Errors", func(t *testing.T) {
+		repo, next := setup(errors.New("boom"), 1)
+		if _, err := repo.FindByID(ctx, "1"); err == nil {
+			t.Fatal("FindByID() expected error, got nil")
+		}
+		if _, err := repo.FindByID(ctx, "missing"); !errors.Is(err, todo.ErrNotFound) {
+			t.Fatalf("FindByID() error = %v, want %v", err, todo.ErrNotFound)
+		}
+		if n := next.calls.Load(); n != 2 {
+			t.Errorf("got %d calls, want 2", n)
+		}
+		if s := repo.State(); s != resilience.Closed {
+			t.Errorf("got state %v, want closed", s)
+		}
+	})
+
+	t.Run("Respects Deadline", func(t *testing.T) {
+		repo, next := setup(errSerialization, 5)
+		slow := opts
+		slow.Backoff, slow.MaxBackoff = time.Hour, time.Hour
+		repo = resilience.New(next, slow)
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if _, err := repo.FindByID(ctx, "1"); !errors.Is(err, errSerialization) {
+			t.Fatalf("FindByID() error = %v, want %v", err, errSerialization)
+		}
+		if time.Since(start) > 40*time.Millisecond {
+			t.Error("expected to give up instead of sleeping past the deadline")
+		}
+	})
+
+	t.Run("Retries Whole Unit of Work", func(t *testing.T) {
+		repo, next := setup(errSerialization, 1)
+		attempts := 0
+		err := repo.WithinTx(ctx, func(ctx context.Context) error {
+			attempts++
+			return repo.Save(ctx, todo.Todo{ID: "2", Title: "Two"})
+		})
+		if err != nil {
+			t.Fatalf("WithinTx() error = %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("got %d attempts, want 2", attempts)
+		}
+		if _, err := next.Repository.FindByID(ctx, "2"); err != nil {
+			t.Errorf("expected committed todo, got %v", err)
+		}
+	})
+
+	t.Run("Does Not Retry Ambiguous Commit", func(t *testing.T) {
+		repo := resilience.New(&commitFailRepo{Repository: memory.New(), err: syscall.ECONNRESET}, opts)
+		attempts := 0
+		err := repo.WithinTx(ctx, func(ctx context.Context) error {
+			attempts++
+			return nil
+		})
+		if !errors.Is(err, syscall.ECONNRESET) || attempts != 1 {
+			t.Errorf("WithinTx() = %v after %d attempts, want %v after 1", err, attempts, syscall.ECONNRESET)
+		}
+
+		// The server refusing the commit rolls it back, so it is retried.
+		repo = resilience.New(&commitFailRepo{Repository: memory.New(), err: errSerialization}, opts)
+		attempts = 0
+		_ = repo.WithinTx(ctx, func(ctx context.Context) error {
+			attempts++
+			return nil
+		})
+		if attempts != opts.MaxAttempts {
+			t.Errorf("got %d attempts, want %d", attempts, opts.MaxAttempts)
+		}
+	})
+
+	t.Run("ForEach Retries Until Started", func(t *testing.T) {
+		repo, next := setup(errSerialization, 1)
+		var seen []string
+		err := repo.ForEach(ctx, func(t todo.Todo) error {
+			seen = append(seen, t.ID)
+			return nil
+		})
+		if !errors.Is(err, errSerialization) || len(seen) != 1 {
+			t.Errorf("ForEach() = %v after %v, want %v after passing on todo 1 once", err, seen, errSerialization)
+		}
+
+		// With nothing passed on yet, the failure is retried.
+		repo, next = setup(errSerialization, 1)
+		_ = next.Repository.Delete(ctx, "1")
+		if err := repo.ForEach(ctx, func(todo.Todo) error { return nil }); err != nil {
+			t.Errorf("ForEach() error = %v", err)
+		}
+		if n := next.calls.Load(); n != 2 {
+			t.Errorf("got %d calls, want 2", n)
+		}
+	})
+
+	t.Run("Circuit Breaker", func(t *testing.T) {
+		repo, next := setup(syscall.ECONNREFUSED, 100)
+		for range 2 {
+			_, _ = repo.FindByID(ctx, "1")
+		}
+		if s := repo.State(); s != resilience.Open {
+			t.Fatalf("got state %v, want open", s)
+		}
+		if err := repo.Ready(ctx); !errors.Is(err, todo.ErrUnavailable) {
+			t.Errorf("Ready() error = %v, want %v", err, todo.ErrUnavailable)
+		}
+
+		calls := next.calls.Load()
+		if _, err := repo.FindByID(ctx, "1"); !errors.Is(err, resilience.ErrCircuitOpen) {
+			t.Fatalf("FindByID() error = %v, want %v", err, resilience.ErrCircuitOpen)
+		}
+		if next.calls.Load() != calls {
+			t.Error("expected open circuit to fail fast")
+		}
+
+		// A trial call given up by its caller decides nothing.
+		time.Sleep(opts.Cooldown)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, _ = repo.FindByID(cancelled, "1")
+		if s := repo.State(); s != resilience.HalfOpen {
+			t.Fatalf("got state %v after a cancelled trial, want half-open", s)
+		}
+
+		// Another trial call closes it again.
+		next.failures.Store(0)
+		if _, err := repo.FindByID(ctx, "1"); err != nil {
+			t.Fatalf("FindByID() error = %v", err)
+		}
+		if s := repo.State(); s != resilience.Closed {
+			t.Errorf("got state %v, want closed", s)
+		}
+		if err := repo.Ready(ctx); err != nil {
+			t.Errorf("Ready() error = %v", err)
+		}
+	})
+}
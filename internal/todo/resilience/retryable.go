@@ -0,0 +1,43 @@
+// Package resilience provides a todo.Repository decorator that retries
+// transient failures and stops calling a failing database altogether.
+package resilience
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Retryable reports whether err is a transient failure after which the
+// same operation may succeed: a serialization failure or deadlock, a lost
+// or refused connection, or a server that is shutting down or starting up.
+// Context cancellation is never retryable.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08: connection exceptions.
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+	return pgconn.SafeToRetry(err) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -2,7 +2,10 @@ package todo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jllovet/go-server-template/logger"
 	"github.com/segmentio/ksuid"
@@ -11,18 +14,138 @@ import (
 // service implements the Service interface.
 // It holds a reference to the Repository Port.
 type service struct {
-	repo Repository
+	repo         Repository
+	audit        AuditRepository
+	searcher     Searcher
+	publishers   []Publisher
+	maxBatchSize int
+}
+
+// Option configures optional collaborators of the Todo service.
+type Option func(*service)
+
+// WithPublisher registers p to be notified of every change made through the service.
+// It may be given more than once; publishers are called in registration order.
+func WithPublisher(p Publisher) Option {
+	return func(s *service) {
+		s.publishers = append(s.publishers, p)
+	}
+}
+
+// WithAudit records an AuditEntry in audit for every change made through the
+// service. A change whose entry cannot be recorded is reported as failed.
+func WithAudit(audit AuditRepository) Option {
+	return func(s *service) {
+		s.audit = audit
+	}
+}
+
+// WithSearcher enables Search.
+func WithSearcher(searcher Searcher) Option {
+	return func(s *service) {
+		s.searcher = searcher
+	}
+}
+
+// WithMaxBatchSize limits how many operations a Batch may contain.
+func WithMaxBatchSize(n int) Option {
+	return func(s *service) {
+		s.maxBatchSize = n
+	}
 }
 
 // NewService creates a new Todo service.
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, opts ...Option) Service {
+	s := &service{repo: repo, maxBatchSize: DefaultMaxBatchSize}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type pendingKey struct{}
+
+// pendingEvents collects the events of a unit of work until it commits.
+type pendingEvents struct {
+	events []Event
+}
+
+// withinTx runs fn as a unit of work of the repository and publishes the
+// events it produced once it has committed. Nested calls join the
+// outermost unit of work, which publishes for all of them. The repository
+// may run fn more than once, so only the events of the last attempt count.
+func (s *service) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, nested := ctx.Value(pendingKey{}).(*pendingEvents); nested {
+		return s.repo.WithinTx(ctx, fn)
+	}
+	var pending *pendingEvents
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		pending = &pendingEvents{}
+		return fn(context.WithValue(ctx, pendingKey{}, pending))
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range pending.events {
+		s.notify(ctx, e)
+	}
+	return nil
+}
+
+// record appends an audit entry for a change, if auditing is enabled.
+func (s *service) record(ctx context.Context, action EventType, todoID string, before, after *Todo) error {
+	if s.audit == nil {
+		return nil
+	}
+	e := AuditEntry{
+		ID:        ksuid.New().String(),
+		TodoID:    todoID,
+		Action:    action,
+		Actor:     ActorFromContext(ctx),
+		RequestID: logger.RequestIDFromContext(ctx),
+		Before:    before,
+		After:     after,
+		At:        time.Now().UTC(),
+	}
+	if err := s.audit.Append(ctx, e); err != nil {
+		logger.FromContext(ctx).Error("failed to record audit entry", "id", todoID, "action", action, "error", err)
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// publish notifies every registered Publisher of a change. Within a unit of
+// work the event is held back until it commits. Publishing is best effort:
+// the change has already been persisted, so failures are only logged.
+func (s *service) publish(ctx context.Context, typ EventType, t Todo) {
+	if len(s.publishers) == 0 {
+		return
+	}
+	e := Event{
+		ID:         ksuid.New().String(),
+		Type:       typ,
+		Todo:       t,
+		OccurredAt: time.Now().UTC(),
+	}
+	if pending, ok := ctx.Value(pendingKey{}).(*pendingEvents); ok {
+		pending.events = append(pending.events, e)
+		return
+	}
+	s.notify(ctx, e)
+}
+
+func (s *service) notify(ctx context.Context, e Event) {
+	for _, p := range s.publishers {
+		if err := p.Publish(ctx, e); err != nil {
+			logger.FromContext(ctx).Error("failed to publish todo event", "type", e.Type, "id", e.Todo.ID, "error", err)
+		}
+	}
 }
 
 // Create applies business logic to create a new Todo.
 func (s *service) Create(ctx context.Context, title string) (Todo, error) {
 	if title == "" {
-		return Todo{}, fmt.Errorf("title cannot be empty")
+		return Todo{}, fmt.Errorf("%w: title cannot be empty", ErrInvalid)
 	}
 
 	t := Todo{
@@ -32,11 +155,20 @@ func (s *service) Create(ctx context.Context, title string) (Todo, error) {
 
 	logger.FromContext(ctx).Info("creating todo", "id", t.ID)
 
-	if err := s.repo.Save(ctx, t); err != nil {
-		logger.FromContext(ctx).Error("failed to save todo", "error", err)
-		return Todo{}, fmt.Errorf("failed to save todo: %w", err)
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Save(ctx, t); err != nil {
+			logger.FromContext(ctx).Error("failed to save todo", "error", err)
+			return fmt.Errorf("failed to save todo: %w", err)
+		}
+		if err := s.record(ctx, EventCreated, t.ID, nil, &t); err != nil {
+			return err
+		}
+		s.publish(ctx, EventCreated, t)
+		return nil
+	})
+	if err != nil {
+		return Todo{}, err
 	}
-
 	return t, nil
 }
 
@@ -49,6 +181,23 @@ func (s *service) List(ctx context.Context) ([]Todo, error) {
 	return todos, nil
 }
 
+func (s *service) ForEach(ctx context.Context, fn func(Todo) error) error {
+	// An error of fn is the caller's, not a failure of the list.
+	var fnErr error
+	err := s.repo.ForEach(ctx, func(t Todo) error {
+		fnErr = fn(t)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to list todos", "error", err)
+		return fmt.Errorf("failed to list todos: %w", err)
+	}
+	return nil
+}
+
 func (s *service) Get(ctx context.Context, id string) (Todo, error) {
 	t, err := s.repo.FindByID(ctx, id)
 	if err != nil {
@@ -59,51 +208,217 @@ func (s *service) Get(ctx context.Context, id string) (Todo, error) {
 }
 
 func (s *service) Update(ctx context.Context, id string, title string) (Todo, error) {
-	t, err := s.repo.FindByID(ctx, id)
-	if err != nil {
-		logger.FromContext(ctx).Error("failed to find todo for update", "id", id, "error", err)
-		return Todo{}, fmt.Errorf("failed to find todo for update: %w", err)
-	}
+	var t Todo
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		t, err = s.repo.FindByID(ctx, id)
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to find todo for update", "id", id, "error", err)
+			return fmt.Errorf("failed to find todo for update: %w", err)
+		}
 
-	if title == "" {
-		return Todo{}, fmt.Errorf("title cannot be empty")
-	}
-	t.Title = title
+		if title == "" {
+			return fmt.Errorf("%w: title cannot be empty", ErrInvalid)
+		}
+		before := t
+		t.Title = title
 
-	logger.FromContext(ctx).Info("updating todo", "id", id)
+		logger.FromContext(ctx).Info("updating todo", "id", id)
 
-	if err := s.repo.Save(ctx, t); err != nil {
-		logger.FromContext(ctx).Error("failed to save updated todo", "id", id, "error", err)
-		return Todo{}, fmt.Errorf("failed to save updated todo: %w", err)
+		if err := s.repo.Save(ctx, t); err != nil {
+			logger.FromContext(ctx).Error("failed to save updated todo", "id", id, "error", err)
+			return fmt.Errorf("failed to save updated todo: %w", err)
+		}
+		if err := s.record(ctx, EventUpdated, id, &before, &t); err != nil {
+			return err
+		}
+		s.publish(ctx, EventUpdated, t)
+		return nil
+	})
+	if err != nil {
+		return Todo{}, err
 	}
-
 	return t, nil
 }
 
 func (s *service) SetCompleted(ctx context.Context, id string, completed bool) (Todo, error) {
-	t, err := s.repo.FindByID(ctx, id)
+	var t Todo
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		t, err = s.repo.FindByID(ctx, id)
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to find todo for update", "id", id, "error", err)
+			return fmt.Errorf("failed to find todo for update: %w", err)
+		}
+
+		before := t
+		t.Completed = completed
+
+		logger.FromContext(ctx).Info("setting todo completion", "id", id, "completed", completed)
+
+		if err := s.repo.Save(ctx, t); err != nil {
+			logger.FromContext(ctx).Error("failed to save updated todo", "id", id, "error", err)
+			return fmt.Errorf("failed to save updated todo: %w", err)
+		}
+
+		action := EventReopened
+		if completed {
+			action = EventCompleted
+		}
+		if err := s.record(ctx, action, id, &before, &t); err != nil {
+			return err
+		}
+		s.publish(ctx, action, t)
+		return nil
+	})
 	if err != nil {
-		logger.FromContext(ctx).Error("failed to find todo for update", "id", id, "error", err)
-		return Todo{}, fmt.Errorf("failed to find todo for update: %w", err)
+		return Todo{}, err
 	}
+	return t, nil
+}
 
-	t.Completed = completed
+// Delete moves a todo to the trash, from which it can be restored until it
+// is purged.
+func (s *service) Delete(ctx context.Context, id string) error {
+	return s.withinTx(ctx, func(ctx context.Context) error {
+		t, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to find todo for delete", "id", id, "error", err)
+			return fmt.Errorf("failed to delete todo %q: %w", id, err)
+		}
+
+		before := t
+		now := time.Now().UTC()
+		t.DeletedAt = &now
 
-	logger.FromContext(ctx).Info("setting todo completion", "id", id, "completed", completed)
+		logger.FromContext(ctx).Info("deleting todo", "id", id)
+		if err := s.repo.Save(ctx, t); err != nil {
+			logger.FromContext(ctx).Error("failed to delete todo", "id", id, "error", err)
+			return fmt.Errorf("failed to delete todo %q: %w", id, err)
+		}
+		if err := s.record(ctx, EventDeleted, id, &before, &t); err != nil {
+			return err
+		}
+		s.publish(ctx, EventDeleted, t)
+		return nil
+	})
+}
 
-	if err := s.repo.Save(ctx, t); err != nil {
-		logger.FromContext(ctx).Error("failed to save updated todo", "id", id, "error", err)
-		return Todo{}, fmt.Errorf("failed to save updated todo: %w", err)
+func (s *service) ListTrash(ctx context.Context) ([]Todo, error) {
+	todos, err := s.repo.FindDeleted(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to list trash", "error", err)
+		return nil, fmt.Errorf("failed to list trash: %w", err)
 	}
+	return todos, nil
+}
+
+// Restore takes a todo out of the trash.
+func (s *service) Restore(ctx context.Context, id string) (Todo, error) {
+	var t Todo
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		t, err = s.repo.FindDeletedByID(ctx, id)
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to find todo in trash", "id", id, "error", err)
+			return fmt.Errorf("failed to find todo %q in trash: %w", id, err)
+		}
+
+		before := t
+		t.DeletedAt = nil
 
+		logger.FromContext(ctx).Info("restoring todo", "id", id)
+		if err := s.repo.Save(ctx, t); err != nil {
+			logger.FromContext(ctx).Error("failed to restore todo", "id", id, "error", err)
+			return fmt.Errorf("failed to restore todo %q: %w", id, err)
+		}
+		if err := s.record(ctx, EventRestored, id, &before, &t); err != nil {
+			return err
+		}
+		s.publish(ctx, EventRestored, t)
+		return nil
+	})
+	if err != nil {
+		return Todo{}, err
+	}
 	return t, nil
 }
 
-func (s *service) Delete(ctx context.Context, id string) error {
-	logger.FromContext(ctx).Info("deleting todo", "id", id)
-	if err := s.repo.Delete(ctx, id); err != nil {
-		logger.FromContext(ctx).Error("failed to delete todo", "id", id, "error", err)
-		return fmt.Errorf("failed to delete todo %q: %w", id, err)
+// PurgeTrash removes the todos and records their audit entries in one unit
+// of work, so a todo is never gone without a trace.
+func (s *service) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	var purged []Todo
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		purged, err = s.repo.PurgeDeleted(ctx, before)
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to purge trash", "error", err)
+			return fmt.Errorf("failed to purge trash: %w", err)
+		}
+		for _, t := range purged {
+			if err := s.record(ctx, EventPurged, t.ID, &t, nil); err != nil {
+				return err
+			}
+			s.publish(ctx, EventPurged, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	if len(purged) > 0 {
+		logger.FromContext(ctx).Info("purged trash", "count", len(purged))
+	}
+	return len(purged), nil
+}
+
+// History returns the audit trail of a todo, oldest first. It is still
+// available after the todo has been deleted, and empty for todos created
+// before auditing was enabled. Todos that never existed are ErrNotFound.
+func (s *service) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	if s.audit == nil {
+		return nil, ErrAuditDisabled
+	}
+	entries, err := s.audit.FindByTodoID(ctx, id)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to get todo history", "id", id, "error", err)
+		return nil, fmt.Errorf("failed to get history of todo %q: %w", id, err)
+	}
+	if len(entries) == 0 {
+		_, err = s.repo.FindByID(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			_, err = s.repo.FindDeletedByID(ctx, id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get history of todo %q: %w", id, err)
+		}
+	}
+	return entries, nil
+}
+
+// Search limits applied by the service.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+func (s *service) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	if s.searcher == nil {
+		return nil, fmt.Errorf("search is not enabled")
+	}
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: search query cannot be empty", ErrInvalid)
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	results, err := s.searcher.Search(ctx, query, limit)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to search todos", "query", query, "error", err)
+		return nil, fmt.Errorf("failed to search todos: %w", err)
+	}
+	return results, nil
 }
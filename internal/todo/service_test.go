@@ -5,9 +5,13 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"maps"
 	"reflect"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/jllovet/go-server-template/internal/todo"
 	"github.com/jllovet/go-server-template/logger"
@@ -25,6 +29,7 @@ type mockRepository struct {
 	findByIDErr error
 	findAllErr  error
 	deleteErr   error
+	purgeErr    error
 }
 
 func newMockRepository() *mockRepository {
@@ -50,8 +55,8 @@ func (m *mockRepository) FindByID(_ context.Context, id string) (todo.Todo, erro
 		return todo.Todo{}, m.findByIDErr
 	}
 	t, ok := m.todos[id]
-	if !ok {
-		return todo.Todo{}, errors.New("not found")
+	if !ok || t.DeletedAt != nil {
+		return todo.Todo{}, todo.ErrNotFound
 	}
 	return t, nil
 }
@@ -64,11 +69,27 @@ func (m *mockRepository) FindAll(_ context.Context) ([]todo.Todo, error) {
 	}
 	all := make([]todo.Todo, 0, len(m.todos))
 	for _, t := range m.todos {
-		all = append(all, t)
+		if t.DeletedAt == nil {
+			all = append(all, t)
+		}
 	}
 	return all, nil
 }
 
+func (m *mockRepository) ForEach(ctx context.Context, fn func(todo.Todo) error) error {
+	all, err := m.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(all, func(a, b todo.Todo) int { return strings.Compare(a.ID, b.ID) })
+	for _, t := range all {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *mockRepository) Delete(_ context.Context, id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -76,12 +97,132 @@ func (m *mockRepository) Delete(_ context.Context, id string) error {
 		return m.deleteErr
 	}
 	if _, ok := m.todos[id]; !ok {
-		return errors.New("not found")
+		return todo.ErrNotFound
 	}
 	delete(m.todos, id)
 	return nil
 }
 
+func (m *mockRepository) FindDeletedByID(_ context.Context, id string) (todo.Todo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.todos[id]
+	if !ok || t.DeletedAt == nil {
+		return todo.Todo{}, todo.ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *mockRepository) FindDeleted(_ context.Context) ([]todo.Todo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []todo.Todo
+	for _, t := range m.todos {
+		if t.DeletedAt != nil {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// WithinTx restores the todos if fn fails. It does not isolate fn from
+// concurrent calls, which the tests do not need.
+func (m *mockRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.mu.RLock()
+	snapshot := maps.Clone(m.todos)
+	m.mu.RUnlock()
+	if err := fn(ctx); err != nil {
+		m.mu.Lock()
+		m.todos = snapshot
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// retryingRepository runs every unit of work twice, discarding the first
+// attempt, like a decorator retrying a transient failure.
+type retryingRepository struct {
+	*mockRepository
+}
+
+func (r *retryingRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	_ = r.mockRepository.WithinTx(ctx, func(ctx context.Context) error {
+		_ = fn(ctx)
+		return errRepository
+	})
+	return r.mockRepository.WithinTx(ctx, fn)
+}
+
+func (m *mockRepository) PurgeDeleted(_ context.Context, before time.Time) ([]todo.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.purgeErr != nil {
+		return nil, m.purgeErr
+	}
+	var out []todo.Todo
+	for id, t := range m.todos {
+		if t.DeletedAt != nil && t.DeletedAt.Before(before) {
+			out = append(out, t)
+			delete(m.todos, id)
+		}
+	}
+	return out, nil
+}
+
+// mockAuditRepository is a mock implementation of todo.AuditRepository.
+type mockAuditRepository struct {
+	mu        sync.Mutex
+	entries   []todo.AuditEntry
+	appendErr error
+}
+
+func (m *mockAuditRepository) Append(_ context.Context, e todo.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.appendErr != nil {
+		return m.appendErr
+	}
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+func (m *mockAuditRepository) FindByTodoID(_ context.Context, id string) ([]todo.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []todo.AuditEntry
+	for _, e := range m.entries {
+		if e.TodoID == id {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// recordingPublisher captures published events for assertions.
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []todo.Event
+	err    error
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, e todo.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, e)
+	return p.err
+}
+
+func (p *recordingPublisher) types() []todo.EventType {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	types := make([]todo.EventType, 0, len(p.events))
+	for _, e := range p.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
 func TestService(t *testing.T) {
 	ctx := context.Background()
 
@@ -257,12 +398,478 @@ func TestService(t *testing.T) {
 
 		// Repository error
 		created2, _ := service.Create(ctx, "Another one")
-		repo.deleteErr = errRepository
+		repo.saveErr = errRepository
 		err = service.Delete(ctx, created2.ID)
 		if !errors.Is(err, errRepository) {
 			t.Fatalf("Delete() expected repository error, got %v", err)
 		}
 	})
+
+	t.Run("Trash and Restore", func(t *testing.T) {
+		repo := newMockRepository()
+		service := todo.NewService(repo)
+		created, _ := service.Create(ctx, "Oops")
+
+		if err := service.Delete(ctx, created.ID); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+
+		// Hidden from normal reads
+		if _, err := service.Get(ctx, created.ID); !errors.Is(err, todo.ErrNotFound) {
+			t.Fatalf("Get() after Delete() error = %v, want ErrNotFound", err)
+		}
+		if todos, _ := service.List(ctx); len(todos) != 0 {
+			t.Fatalf("List() after Delete() got %d todos, want 0", len(todos))
+		}
+
+		// Visible in the trash
+		trash, err := service.ListTrash(ctx)
+		if err != nil {
+			t.Fatalf("ListTrash() error = %v", err)
+		}
+		if len(trash) != 1 || trash[0].ID != created.ID || trash[0].DeletedAt == nil {
+			t.Fatalf("ListTrash() got %+v, want the deleted todo", trash)
+		}
+
+		// Cannot be modified while trashed
+		if _, err := service.Update(ctx, created.ID, "Edited"); err == nil {
+			t.Error("Update() of trashed todo expected error, got nil")
+		}
+
+		restored, err := service.Restore(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		if restored.DeletedAt != nil || restored.Title != "Oops" {
+			t.Errorf("Restore() got %+v", restored)
+		}
+		if _, err := service.Get(ctx, created.ID); err != nil {
+			t.Errorf("Get() after Restore() error = %v", err)
+		}
+
+		// Only trashed todos can be restored
+		if _, err := service.Restore(ctx, created.ID); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("Restore() of active todo error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("PurgeTrash", func(t *testing.T) {
+		repo := newMockRepository()
+		audit := &mockAuditRepository{}
+		pub := &recordingPublisher{}
+		service := todo.NewService(repo, todo.WithAudit(audit), todo.WithPublisher(pub))
+		old, _ := service.Create(ctx, "Old")
+		kept, _ := service.Create(ctx, "Kept")
+		_ = service.Delete(ctx, old.ID)
+		cutoff := time.Now().Add(time.Second)
+		_ = service.Delete(ctx, kept.ID)
+
+		// Backdate the kept todo's deletion to after the cutoff.
+		later := cutoff.Add(time.Hour)
+		k := repo.todos[kept.ID]
+		k.DeletedAt = &later
+		repo.todos[kept.ID] = k
+
+		n, err := service.PurgeTrash(ctx, cutoff)
+		if err != nil {
+			t.Fatalf("PurgeTrash() error = %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("PurgeTrash() purged %d, want 1", n)
+		}
+		if _, ok := repo.todos[old.ID]; ok {
+			t.Error("old todo still stored after purge")
+		}
+		if trash, _ := service.ListTrash(ctx); len(trash) != 1 || trash[0].ID != kept.ID {
+			t.Errorf("trash after purge = %+v, want only %q", trash, kept.ID)
+		}
+
+		history, _ := service.History(ctx, old.ID)
+		last := history[len(history)-1]
+		if last.Action != todo.EventPurged || last.Before == nil || last.After != nil {
+			t.Errorf("last history entry = %+v, want purge with before snapshot", last)
+		}
+		if types := pub.types(); types[len(types)-1] != todo.EventPurged {
+			t.Errorf("last published event = %q, want %q", types[len(types)-1], todo.EventPurged)
+		}
+
+		repo.purgeErr = errRepository
+		if _, err := service.PurgeTrash(ctx, cutoff); !errors.Is(err, errRepository) {
+			t.Errorf("PurgeTrash() expected repository error, got %v", err)
+		}
+	})
+}
+
+func TestService_Publish(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Publishes an event per mutation", func(t *testing.T) {
+		pub := &recordingPublisher{}
+		service := todo.NewService(newMockRepository(), todo.WithPublisher(pub))
+
+		created, _ := service.Create(ctx, "Publish me")
+		_, _ = service.Update(ctx, created.ID, "Renamed")
+		_, _ = service.SetCompleted(ctx, created.ID, true)
+		_, _ = service.SetCompleted(ctx, created.ID, false)
+		_ = service.Delete(ctx, created.ID)
+
+		want := []todo.EventType{
+			todo.EventCreated,
+			todo.EventUpdated,
+			todo.EventCompleted,
+			todo.EventReopened,
+			todo.EventDeleted,
+		}
+		if got := pub.types(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("published %v, want %v", got, want)
+		}
+		for _, e := range pub.events {
+			if e.ID == "" || e.OccurredAt.IsZero() {
+				t.Errorf("event %+v missing ID or timestamp", e)
+			}
+			if e.Todo.ID != created.ID {
+				t.Errorf("event todo ID = %q, want %q", e.Todo.ID, created.ID)
+			}
+		}
+	})
+
+	t.Run("Failed mutations are not published", func(t *testing.T) {
+		pub := &recordingPublisher{}
+		repo := newMockRepository()
+		service := todo.NewService(repo, todo.WithPublisher(pub))
+
+		_, _ = service.Create(ctx, "")
+		repo.saveErr = errRepository
+		_, _ = service.Create(ctx, "Never saved")
+
+		if got := pub.types(); len(got) != 0 {
+			t.Fatalf("published %v, want nothing", got)
+		}
+	})
+
+	t.Run("Retried units of work publish once", func(t *testing.T) {
+		pub := &recordingPublisher{}
+		service := todo.NewService(&retryingRepository{newMockRepository()}, todo.WithPublisher(pub))
+
+		if _, err := service.Create(ctx, "Retried"); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if got := pub.types(); !reflect.DeepEqual(got, []todo.EventType{todo.EventCreated}) {
+			t.Fatalf("published %v, want a single %v", got, todo.EventCreated)
+		}
+	})
+
+	t.Run("Publisher errors do not fail the mutation", func(t *testing.T) {
+		pub := &recordingPublisher{err: errors.New("publisher down")}
+		service := todo.NewService(newMockRepository(), todo.WithPublisher(pub))
+
+		if _, err := service.Create(ctx, "Still saved"); err != nil {
+			t.Fatalf("Create() error = %v, want nil", err)
+		}
+	})
+}
+
+func TestService_Audit(t *testing.T) {
+	t.Run("Records every mutation", func(t *testing.T) {
+		audit := &mockAuditRepository{}
+		service := todo.NewService(newMockRepository(), todo.WithAudit(audit))
+		ctx := logger.WithRequestID(todo.WithActor(context.Background(), "alice"), "req-1")
+
+		created, _ := service.Create(ctx, "Audit me")
+		_, _ = service.Update(ctx, created.ID, "Renamed")
+		_, _ = service.SetCompleted(ctx, created.ID, true)
+		_ = service.Delete(ctx, created.ID)
+
+		history, err := service.History(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("History() error = %v", err)
+		}
+		wantActions := []todo.EventType{todo.EventCreated, todo.EventUpdated, todo.EventCompleted, todo.EventDeleted}
+		if len(history) != len(wantActions) {
+			t.Fatalf("History() got %d entries, want %d", len(history), len(wantActions))
+		}
+		for i, e := range history {
+			if e.Action != wantActions[i] {
+				t.Errorf("entry %d action = %q, want %q", i, e.Action, wantActions[i])
+			}
+			if e.Actor != "alice" || e.RequestID != "req-1" || e.At.IsZero() {
+				t.Errorf("entry %d = %+v, want actor alice, request req-1 and a timestamp", i, e)
+			}
+		}
+
+		if history[0].Before != nil || history[0].After.Title != "Audit me" {
+			t.Errorf("create entry before=%v after=%v", history[0].Before, history[0].After)
+		}
+		if history[1].Before.Title != "Audit me" || history[1].After.Title != "Renamed" {
+			t.Errorf("update entry before=%v after=%v", history[1].Before, history[1].After)
+		}
+		if history[2].Before.Completed || !history[2].After.Completed {
+			t.Errorf("complete entry before=%v after=%v", history[2].Before, history[2].After)
+		}
+		if history[3].Before.DeletedAt != nil || history[3].After.DeletedAt == nil {
+			t.Errorf("delete entry before=%v after=%v", history[3].Before, history[3].After)
+		}
+	})
+
+	t.Run("Defaults to anonymous actor", func(t *testing.T) {
+		audit := &mockAuditRepository{}
+		service := todo.NewService(newMockRepository(), todo.WithAudit(audit))
+
+		created, _ := service.Create(context.Background(), "Who did this?")
+		history, _ := service.History(context.Background(), created.ID)
+		if len(history) != 1 || history[0].Actor != todo.AnonymousActor {
+			t.Errorf("got %+v, want one anonymous entry", history)
+		}
+	})
+
+	t.Run("Audit failures fail the mutation", func(t *testing.T) {
+		audit := &mockAuditRepository{appendErr: errRepository}
+		pub := &recordingPublisher{}
+		service := todo.NewService(newMockRepository(), todo.WithAudit(audit), todo.WithPublisher(pub))
+
+		if _, err := service.Create(context.Background(), "Unaudited"); !errors.Is(err, errRepository) {
+			t.Fatalf("Create() error = %v, want audit error", err)
+		}
+		if len(pub.types()) != 0 {
+			t.Error("unaudited change was published")
+		}
+		if todos, _ := service.List(context.Background()); len(todos) != 0 {
+			t.Errorf("unaudited change was kept: %+v", todos)
+		}
+	})
+
+	t.Run("History of unknown todo", func(t *testing.T) {
+		service := todo.NewService(newMockRepository(), todo.WithAudit(&mockAuditRepository{}))
+		if _, err := service.History(context.Background(), "missing"); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("History() error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("History without audit log", func(t *testing.T) {
+		service := todo.NewService(newMockRepository())
+		if _, err := service.History(context.Background(), "any"); !errors.Is(err, todo.ErrAuditDisabled) {
+			t.Errorf("History() error = %v, want ErrAuditDisabled", err)
+		}
+	})
+}
+
+func TestService_Batch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Atomic batch applies every operation", func(t *testing.T) {
+		repo := newMockRepository()
+		audit := &mockAuditRepository{}
+		pub := &recordingPublisher{}
+		service := todo.NewService(repo, todo.WithAudit(audit), todo.WithPublisher(pub))
+		existing, _ := service.Create(ctx, "Existing")
+
+		results, err := service.Batch(ctx, []todo.BatchOperation{
+			{Op: todo.BatchCreate, Title: "New"},
+			{Op: todo.BatchComplete, ID: existing.ID},
+			{Op: todo.BatchDelete, ID: existing.ID},
+		}, true)
+		if err != nil {
+			t.Fatalf("Batch() error = %v", err)
+		}
+		if len(results) != 3 || results[0].Todo == nil || results[0].Todo.Title != "New" || !results[1].Todo.Completed {
+			t.Fatalf("unexpected results %+v", results)
+		}
+		if _, err := service.Get(ctx, existing.ID); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("expected deleted todo, got %v", err)
+		}
+		want := []todo.EventType{todo.EventCreated, todo.EventCreated, todo.EventCompleted, todo.EventDeleted}
+		if got := pub.types(); !reflect.DeepEqual(got, want) {
+			t.Errorf("published %v, want %v", got, want)
+		}
+		if len(audit.entries) != 4 {
+			t.Errorf("expected 4 audit entries, got %d", len(audit.entries))
+		}
+	})
+
+	t.Run("Atomic batch rolls back on failure", func(t *testing.T) {
+		repo := newMockRepository()
+		pub := &recordingPublisher{}
+		service := todo.NewService(repo, todo.WithPublisher(pub))
+
+		_, err := service.Batch(ctx, []todo.BatchOperation{
+			{Op: todo.BatchCreate, Title: "Rolled back"},
+			{Op: todo.BatchDelete, ID: "missing"},
+		}, true)
+		var batchErr *todo.BatchError
+		if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, todo.ErrNotFound) {
+			t.Fatalf("Batch() error = %v, want BatchError at index 1 wrapping ErrNotFound", err)
+		}
+		if todos, _ := service.List(ctx); len(todos) != 0 {
+			t.Errorf("expected no todos after rollback, got %+v", todos)
+		}
+		if got := pub.types(); len(got) != 0 {
+			t.Errorf("published %v for a rolled back batch", got)
+		}
+	})
+
+	t.Run("Non-atomic batch reports each result", func(t *testing.T) {
+		service := todo.NewService(newMockRepository())
+
+		results, err := service.Batch(ctx, []todo.BatchOperation{
+			{Op: todo.BatchCreate, Title: "Kept"},
+			{Op: todo.BatchUpdate, ID: "missing", Title: "Nope"},
+			{Op: "explode"},
+		}, false)
+		if err != nil {
+			t.Fatalf("Batch() error = %v", err)
+		}
+		if results[0].Err != nil || results[0].Todo == nil {
+			t.Errorf("expected first operation to succeed, got %+v", results[0])
+		}
+		if !errors.Is(results[1].Err, todo.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", results[1].Err)
+		}
+		if !errors.Is(results[2].Err, todo.ErrInvalid) {
+			t.Errorf("expected ErrInvalid, got %v", results[2].Err)
+		}
+		if todos, _ := service.List(ctx); len(todos) != 1 {
+			t.Errorf("expected 1 todo, got %d", len(todos))
+		}
+	})
+
+	t.Run("Size limits", func(t *testing.T) {
+		service := todo.NewService(newMockRepository(), todo.WithMaxBatchSize(2))
+		ops := make([]todo.BatchOperation, 3)
+		if _, err := service.Batch(ctx, ops, false); !errors.Is(err, todo.ErrBatchTooLarge) {
+			t.Errorf("expected ErrBatchTooLarge, got %v", err)
+		}
+		if _, err := service.Batch(ctx, nil, false); !errors.Is(err, todo.ErrInvalid) {
+			t.Errorf("expected ErrInvalid for empty batch, got %v", err)
+		}
+	})
+}
+
+func TestService_Import(t *testing.T) {
+	ctx := context.Background()
+	keep := todo.ImportOptions{KeepIDs: true}
+
+	t.Run("Keeps or regenerates IDs", func(t *testing.T) {
+		audit := &mockAuditRepository{}
+		pub := &recordingPublisher{}
+		service := todo.NewService(newMockRepository(), todo.WithAudit(audit), todo.WithPublisher(pub))
+
+		kept, outcome, err := service.Import(ctx, todo.Todo{ID: "todo-1", Title: "Kept", Completed: true}, keep)
+		if err != nil || outcome != todo.ImportCreated {
+			t.Fatalf("Import() = %v, %v, want created", outcome, err)
+		}
+		if got, _ := service.Get(ctx, "todo-1"); got != kept || !got.Completed {
+			t.Errorf("got %+v, want %+v", got, kept)
+		}
+		renamed, _, err := service.Import(ctx, todo.Todo{ID: "todo-1", Title: "Copy"}, todo.ImportOptions{})
+		if err != nil || renamed.ID == "todo-1" || renamed.ID == "" {
+			t.Errorf("Import() = %+v, %v, want a new ID", renamed, err)
+		}
+		if len(audit.entries) != 2 || !reflect.DeepEqual(pub.types(), []todo.EventType{todo.EventCreated, todo.EventCreated}) {
+			t.Errorf("got %d audit entries and events %v, want 2 creations", len(audit.entries), pub.types())
+		}
+	})
+
+	t.Run("Conflict policies", func(t *testing.T) {
+		repo := newMockRepository()
+		service := todo.NewService(repo)
+		existing := todo.Todo{ID: "todo-1", Title: "Existing"}
+		if _, _, err := service.Import(ctx, existing, keep); err != nil {
+			t.Fatalf("Import() error = %v", err)
+		}
+		_ = service.Delete(ctx, "todo-1")
+		incoming := todo.Todo{ID: "todo-1", Title: "Incoming", Completed: true}
+
+		// Todos in the trash conflict too.
+		if _, _, err := service.Import(ctx, incoming, keep); !errors.Is(err, todo.ErrConflict) {
+			t.Errorf("Import() error = %v, want ErrConflict", err)
+		}
+		skip := todo.ImportOptions{KeepIDs: true, OnConflict: todo.ConflictSkip}
+		if _, outcome, err := service.Import(ctx, incoming, skip); err != nil || outcome != todo.ImportSkipped {
+			t.Errorf("Import() = %v, %v, want skipped", outcome, err)
+		}
+		overwrite := todo.ImportOptions{KeepIDs: true, OnConflict: todo.ConflictOverwrite}
+		if _, outcome, err := service.Import(ctx, incoming, overwrite); err != nil || outcome != todo.ImportOverwritten {
+			t.Errorf("Import() = %v, %v, want overwritten", outcome, err)
+		}
+		if got, err := service.Get(ctx, "todo-1"); err != nil || got != incoming {
+			t.Errorf("got %+v, %v, want the incoming todo out of the trash", got, err)
+		}
+	})
+
+	t.Run("Dry run changes nothing", func(t *testing.T) {
+		pub := &recordingPublisher{}
+		service := todo.NewService(newMockRepository(), todo.WithPublisher(pub))
+		dry := todo.ImportOptions{KeepIDs: true, DryRun: true}
+		if _, outcome, err := service.Import(ctx, todo.Todo{ID: "todo-1", Title: "Dry"}, dry); err != nil || outcome != todo.ImportCreated {
+			t.Errorf("Import() = %v, %v, want created", outcome, err)
+		}
+		if todos, _ := service.List(ctx); len(todos) != 0 || len(pub.types()) != 0 {
+			t.Errorf("dry run stored %+v and published %v", todos, pub.types())
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		service := todo.NewService(newMockRepository())
+		for _, tc := range []struct {
+			todo todo.Todo
+			opts todo.ImportOptions
+		}{
+			{todo.Todo{ID: "todo-1"}, keep},
+			{todo.Todo{Title: "No ID"}, keep},
+			{todo.Todo{ID: "has space", Title: "Bad ID"}, keep},
+			{todo.Todo{ID: "todo-1", Title: "Bad policy"}, todo.ImportOptions{KeepIDs: true, OnConflict: "merge"}},
+		} {
+			if _, _, err := service.Import(ctx, tc.todo, tc.opts); !errors.Is(err, todo.ErrInvalid) {
+				t.Errorf("Import(%+v) error = %v, want ErrInvalid", tc.todo, err)
+			}
+		}
+	})
+}
+
+// searcherFunc adapts a function to todo.Searcher.
+type searcherFunc func(ctx context.Context, query string, limit int) ([]todo.SearchResult, error)
+
+func (f searcherFunc) Search(ctx context.Context, query string, limit int) ([]todo.SearchResult, error) {
+	return f(ctx, query, limit)
+}
+
+func TestService_Search(t *testing.T) {
+	ctx := context.Background()
+	var gotQuery string
+	var gotLimit int
+	searcher := searcherFunc(func(_ context.Context, query string, limit int) ([]todo.SearchResult, error) {
+		gotQuery, gotLimit = query, limit
+		return []todo.SearchResult{{Todo: todo.Todo{ID: "1"}, Rank: 1}}, nil
+	})
+	service := todo.NewService(newMockRepository(), todo.WithSearcher(searcher))
+
+	t.Run("Applies limits", func(t *testing.T) {
+		tests := []struct{ limit, want int }{
+			{0, todo.DefaultSearchLimit},
+			{5, 5},
+			{1000, todo.MaxSearchLimit},
+		}
+		for _, tt := range tests {
+			if _, err := service.Search(ctx, "  milk ", tt.limit); err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if gotQuery != "milk" || gotLimit != tt.want {
+				t.Errorf("Search(limit %d) searched %q with limit %d, want %q with %d", tt.limit, gotQuery, gotLimit, "milk", tt.want)
+			}
+		}
+	})
+
+	t.Run("Empty query", func(t *testing.T) {
+		if _, err := service.Search(ctx, "   ", 0); !errors.Is(err, todo.ErrInvalid) {
+			t.Errorf("Search() error = %v, want ErrInvalid", err)
+		}
+	})
+
+	t.Run("Without searcher", func(t *testing.T) {
+		if _, err := todo.NewService(newMockRepository()).Search(ctx, "milk", 0); err == nil {
+			t.Error("Search() expected error when search is disabled")
+		}
+	})
 }
 
 func BenchmarkService_Create(b *testing.B) {
@@ -1,21 +1,118 @@
 package todo
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a todo does not exist, or is not in the
+// requested state (for example restoring a todo that is not in the trash).
+var ErrNotFound = errors.New("todo not found")
+
+// ErrInvalid is returned when a request to the Service fails validation.
+var ErrInvalid = errors.New("invalid todo")
+
+// ErrUnavailable is returned when storage is temporarily unable to serve
+// requests, for example while a circuit breaker is open.
+var ErrUnavailable = errors.New("todo storage unavailable")
 
 // Todo represents a task in the system.
 type Todo struct {
 	ID        string `json:"id"`
 	Title     string `json:"title"`
 	Completed bool   `json:"completed"`
+	// DeletedAt is set while the todo is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// EventType identifies the kind of change that happened to a Todo.
+type EventType string
+
+const (
+	EventCreated   EventType = "todo.created"
+	EventUpdated   EventType = "todo.updated"
+	EventCompleted EventType = "todo.completed"
+	EventReopened  EventType = "todo.reopened"
+	EventDeleted   EventType = "todo.deleted"
+	EventRestored  EventType = "todo.restored"
+	EventPurged    EventType = "todo.purged"
+)
+
+// Valid reports whether t is one of the known event types.
+func (t EventType) Valid() bool {
+	switch t {
+	case EventCreated, EventUpdated, EventCompleted, EventReopened, EventDeleted, EventRestored, EventPurged:
+		return true
+	}
+	return false
+}
+
+// Event describes a change made to a Todo through the Service.
+// Todo holds the state after the change, or the last state for purges.
+type Event struct {
+	ID         string    `json:"id"`
+	Type       EventType `json:"type"`
+	Todo       Todo      `json:"todo"`
+	OccurredAt time.Time `json:"occurred_at"`
 }
 
 // Repository defines the interface for storing and retrieving Todos.
 // In Hexagonal Architecture, this is a "Driven Port".
+//
+// Todos with DeletedAt set are in the trash: FindByID and FindAll ignore
+// them, and the FindDeleted methods only see them. Save persists DeletedAt,
+// which is how todos are moved to and from the trash.
 type Repository interface {
 	Save(ctx context.Context, t Todo) error
 	FindByID(ctx context.Context, id string) (Todo, error)
 	FindAll(ctx context.Context) ([]Todo, error)
+	// ForEach calls fn with each todo that is not in the trash, in ID
+	// order, as it is read, so that the whole list is never held at once.
+	// An error from fn stops it and is returned.
+	ForEach(ctx context.Context, fn func(Todo) error) error
+	// Delete permanently removes a todo, whether or not it is in the trash.
 	Delete(ctx context.Context, id string) error
+
+	FindDeletedByID(ctx context.Context, id string) (Todo, error)
+	// FindDeleted returns the trash, most recently deleted first.
+	FindDeleted(ctx context.Context) ([]Todo, error)
+	// PurgeDeleted permanently removes todos deleted before the given time
+	// and returns them.
+	PurgeDeleted(ctx context.Context, before time.Time) ([]Todo, error)
+
+	// WithinTx runs fn as a unit of work: the changes made through the
+	// context passed to fn, including those of an AuditRepository from the
+	// same adapter, are applied if fn returns nil and discarded otherwise.
+	// Calls nested in a unit of work join it.
+	// A decorator may run fn again after a transient failure, so fn must
+	// not have effects outside the unit of work.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// SearchResult is a todo matching a search. Rank orders results by
+// relevance, highest first; its scale depends on the Searcher.
+type SearchResult struct {
+	Todo Todo    `json:"todo"`
+	Rank float64 `json:"rank"`
+}
+
+// Searcher finds todos by the words in their titles.
+// In Hexagonal Architecture, this is a "Driven Port".
+//
+// Titles and queries are split into words, which are matched regardless of
+// case and of common English inflections ("reports" finds "report"). A todo
+// matches when it contains every word of the query; common words such as
+// "the" are ignored. Todos in the trash are never returned.
+type Searcher interface {
+	// Search returns at most limit matches, most relevant first.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// Publisher is notified after every successful mutation made through the Service.
+// In Hexagonal Architecture, this is a "Driven Port".
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
 }
 
 // Service defines the interface for the business logic.
@@ -24,7 +121,28 @@ type Service interface {
 	Create(ctx context.Context, title string) (Todo, error)
 	Get(ctx context.Context, id string) (Todo, error)
 	List(ctx context.Context) ([]Todo, error)
+	// ForEach streams the list: it calls fn with each todo, in ID order,
+	// and stops at the first error fn returns.
+	ForEach(ctx context.Context, fn func(Todo) error) error
 	Update(ctx context.Context, id string, title string) (Todo, error)
 	SetCompleted(ctx context.Context, id string, completed bool) (Todo, error)
+	// Delete moves a todo to the trash.
 	Delete(ctx context.Context, id string) error
+	ListTrash(ctx context.Context) ([]Todo, error)
+	Restore(ctx context.Context, id string) (Todo, error)
+	// PurgeTrash permanently removes todos deleted before the given time and
+	// returns how many were removed.
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+	History(ctx context.Context, id string) ([]AuditEntry, error)
+	// Search returns the todos whose titles match query, most relevant first.
+	// A limit of zero or less uses DefaultSearchLimit.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+	// Batch applies several operations. When atomic is set they all succeed
+	// or none is applied and a *BatchError is returned; otherwise each is
+	// applied on its own and its outcome reported in the matching result.
+	Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error)
+	// Import adds t with its own title and completion, and its own ID if
+	// opts.KeepIDs is set. It returns the todo as stored, or the zero Todo
+	// when it was skipped.
+	Import(ctx context.Context, t Todo, opts ImportOptions) (Todo, ImportOutcome, error)
 }
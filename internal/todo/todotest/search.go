@@ -0,0 +1,98 @@
+// Package todotest holds test suites that every adapter of a todo port must
+// pass, so that the adapters stay interchangeable.
+package todotest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// SearchRepository is an adapter that stores todos and searches them.
+type SearchRepository interface {
+	todo.Repository
+	todo.Searcher
+}
+
+// TestSearcher checks a Searcher against the behaviour documented on the
+// port. newRepo must return an empty repository.
+func TestSearcher(t *testing.T, newRepo func(t *testing.T) SearchRepository) {
+	ctx := context.Background()
+	deletedAt := time.Now().UTC()
+	todos := []todo.Todo{
+		{ID: "1", Title: "Write the quarterly report and file the report"},
+		{ID: "2", Title: "Email report to Bob"},
+		{ID: "3", Title: "Buy groceries"},
+		{ID: "4", Title: "Go running in the park"},
+		{ID: "5", Title: "Shred old reports", DeletedAt: &deletedAt},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{"single word, ranked by occurrences", "report", 0, []string{"1", "2"}},
+		{"case insensitive", "REPORT", 0, []string{"1", "2"}},
+		{"plural matches singular", "reports", 0, []string{"1", "2"}},
+		{"ies plural", "grocery", 0, []string{"3"}},
+		{"ing form", "run", 0, []string{"4"}},
+		{"ing form in query", "writing", 0, []string{"1"}},
+		{"every word must match", "quarterly report", 0, []string{"1"}},
+		{"stop words are ignored", "the report", 0, []string{"1", "2"}},
+		{"no match", "vacation", 0, nil},
+		{"limit", "report", 1, []string{"1"}},
+	}
+
+	repo := newRepo(t)
+	for _, td := range todos {
+		if err := repo.Save(ctx, td); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Search(ctx, tt.query, tt.limit)
+			if err != nil {
+				t.Fatalf("Search(%q) error = %v", tt.query, err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.Todo.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := 1; i < len(results); i++ {
+				if results[i].Rank > results[i-1].Rank {
+					t.Errorf("results not ordered by rank: %+v", results)
+				}
+			}
+		})
+	}
+
+	t.Run("index follows changes", func(t *testing.T) {
+		_ = repo.Save(ctx, todo.Todo{ID: "3", Title: "Buy flowers"})
+		_ = repo.Delete(ctx, "2")
+		_ = repo.Save(ctx, todo.Todo{ID: "5", Title: "Shred old reports"})
+
+		results, err := repo.Search(ctx, "report", 0)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Todo.ID)
+		}
+		if !slices.Equal(got, []string{"1", "5"}) {
+			t.Errorf("Search(report) = %v, want [1 5]", got)
+		}
+		if results, _ := repo.Search(ctx, "groceries", 0); len(results) != 0 {
+			t.Errorf("Search(groceries) = %+v, want nothing after rename", results)
+		}
+	})
+}
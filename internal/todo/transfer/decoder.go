@@ -0,0 +1,160 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// RecordError reports a todo that could not be decoded. The Decoder can go
+// on to the next one.
+type RecordError struct {
+	// Record is the position of the todo in the stream, from 1.
+	Record int
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads todos from a stream in a Format one at a time.
+type Decoder struct {
+	format Format
+	json   *json.Decoder
+	csv    *csv.Reader
+	// columns maps CSV column names to their index.
+	columns map[string]int
+	started bool
+	n       int
+}
+
+// NewDecoder returns a Decoder reading from r in format. Unknown JSON
+// fields and CSV columns are errors.
+func NewDecoder(r io.Reader, format Format) *Decoder {
+	d := &Decoder{format: format}
+	if format == CSV {
+		d.csv = csv.NewReader(r)
+	} else {
+		d.json = json.NewDecoder(r)
+		d.json.DisallowUnknownFields()
+	}
+	return d
+}
+
+// Next returns the next todo, or io.EOF after the last. Errors other than
+// a *RecordError end the stream.
+func (d *Decoder) Next() (todo.Todo, error) {
+	if !d.started {
+		d.started = true
+		if err := d.start(); err != nil {
+			return todo.Todo{}, err
+		}
+	}
+	if d.format == CSV {
+		return d.nextCSV()
+	}
+	return d.nextJSON()
+}
+
+// start reads what comes before the first todo.
+func (d *Decoder) start() error {
+	switch d.format {
+	case JSON:
+		tok, err := d.json.Token()
+		if err != nil {
+			return fmt.Errorf("reading JSON array: %w", err)
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("want a JSON array of todos, got %v", tok)
+		}
+	case CSV:
+		header, err := d.csv.Read()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("missing CSV header")
+		}
+		if err != nil {
+			return err
+		}
+		d.columns = make(map[string]int, len(header))
+		for i, name := range header {
+			if i == 0 {
+				// Spreadsheets may start the file with a byte order mark.
+				name = strings.TrimPrefix(name, "\ufeff")
+			}
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !slices.Contains(csvHeader, name) {
+				return fmt.Errorf("unknown CSV column %q, want %s", name, strings.Join(csvHeader, ", "))
+			}
+			d.columns[name] = i
+		}
+		if _, ok := d.columns["title"]; !ok {
+			return errors.New("missing CSV column \"title\"")
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) nextJSON() (todo.Todo, error) {
+	if d.format == JSON && !d.json.More() {
+		if _, err := d.json.Token(); err != nil {
+			return todo.Todo{}, fmt.Errorf("reading JSON array: %w", err)
+		}
+		return todo.Todo{}, io.EOF
+	}
+	var rec transferRecord
+	err := d.json.Decode(&rec)
+	if d.format == NDJSON && errors.Is(err, io.EOF) {
+		return todo.Todo{}, io.EOF
+	}
+	d.n++
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// The stream cannot be followed past malformed JSON.
+			return todo.Todo{}, fmt.Errorf("record %d: %w", d.n, err)
+		}
+		return todo.Todo{}, &RecordError{Record: d.n, Err: err}
+	}
+	return todo.Todo{ID: rec.ID, Title: rec.Title, Completed: rec.Completed}, nil
+}
+
+func (d *Decoder) nextCSV() (todo.Todo, error) {
+	row, err := d.csv.Read()
+	if errors.Is(err, io.EOF) {
+		return todo.Todo{}, io.EOF
+	}
+	d.n++
+	if err != nil {
+		if errors.Is(err, csv.ErrFieldCount) {
+			return todo.Todo{}, &RecordError{Record: d.n, Err: err}
+		}
+		return todo.Todo{}, fmt.Errorf("record %d: %w", d.n, err)
+	}
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+	t := todo.Todo{ID: field("id"), Title: field("title")}
+	if v := strings.TrimSpace(field("completed")); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return todo.Todo{}, &RecordError{Record: d.n, Err: fmt.Errorf("completed: %q is not true or false", v)}
+		}
+		t.Completed = completed
+	}
+	return t, nil
+}
@@ -0,0 +1,99 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// csvHeader names the CSV columns written by Encoder.
+var csvHeader = []string{"id", "title", "completed"}
+
+// Encoder writes todos to a stream in a Format as they are given to it.
+type Encoder struct {
+	w      io.Writer
+	format Format
+	csv    *csv.Writer
+	n      int
+}
+
+// NewEncoder returns an Encoder writing to w in format.
+func NewEncoder(w io.Writer, format Format) *Encoder {
+	e := &Encoder{w: w, format: format}
+	if format == CSV {
+		e.csv = csv.NewWriter(w)
+	}
+	return e
+}
+
+// Encode writes t.
+func (e *Encoder) Encode(t todo.Todo) error {
+	defer func() { e.n++ }()
+	switch e.format {
+	case CSV:
+		if e.n == 0 {
+			if err := e.csv.Write(csvHeader); err != nil {
+				return err
+			}
+		}
+		if err := e.csv.Write([]string{t.ID, t.Title, strconv.FormatBool(t.Completed)}); err != nil {
+			return err
+		}
+		// Write each row through so that it streams.
+		e.csv.Flush()
+		return e.csv.Error()
+	case NDJSON:
+		// json.Encoder ends every value with a newline.
+		return json.NewEncoder(e.w).Encode(record(t))
+	}
+	sep := ",\n"
+	if e.n == 0 {
+		sep = "[\n"
+	}
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	b, err := json.Marshal(record(t))
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+// Close ends the stream, which is complete even if no todo was encoded.
+// It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	switch e.format {
+	case CSV:
+		if e.n == 0 {
+			if err := e.csv.Write(csvHeader); err != nil {
+				return err
+			}
+		}
+		e.csv.Flush()
+		return e.csv.Error()
+	case NDJSON:
+		return nil
+	}
+	end := "\n]\n"
+	if e.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// transferRecord is how a todo is written: the trash is left out.
+type transferRecord struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+}
+
+func record(t todo.Todo) transferRecord {
+	return transferRecord{ID: t.ID, Title: t.Title, Completed: t.Completed}
+}
@@ -0,0 +1,60 @@
+// Package transfer moves todos in and out of the service in bulk, as a
+// JSON array, newline-delimited JSON or CSV, one todo at a time so that
+// neither side needs to hold them all.
+package transfer
+
+import (
+	"fmt"
+	"mime"
+)
+
+// Format is an encoding of a list of todos.
+type Format string
+
+const (
+	// JSON is a JSON array of todos.
+	JSON Format = "json"
+	// NDJSON is one JSON todo per line.
+	NDJSON Format = "ndjson"
+	// CSV has a header row naming the columns id, title and completed, in
+	// any order, and a todo per row. Only title is required.
+	CSV Format = "csv"
+)
+
+// Formats lists every Format.
+var Formats = []Format{JSON, NDJSON, CSV}
+
+// ParseFormat parses the name of a Format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case JSON, NDJSON, CSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, want json, ndjson or csv", s)
+}
+
+// ContentType is the media type of f.
+func (f Format) ContentType() string {
+	switch f {
+	case NDJSON:
+		return "application/x-ndjson"
+	case CSV:
+		return "text/csv"
+	}
+	return "application/json"
+}
+
+// FormatOf returns the Format whose media type is contentType, ignoring
+// parameters such as charset.
+func FormatOf(contentType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	for _, f := range Formats {
+		if f.ContentType() == mediaType {
+			return f, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,110 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+// ErrInput is wrapped by the errors of Import that come from the input
+// rather than the service: it could not be read or is not in its format.
+var ErrInput = errors.New("bad import input")
+
+// ProgressInterval is how many todos Import handles between calls of its
+// progress function.
+const ProgressInterval = 1000
+
+// MaxReportedErrors bounds the failures listed in a Report.
+const MaxReportedErrors = 100
+
+// Report sums up an import.
+type Report struct {
+	// Read counts the todos read, including those that failed.
+	Read        int  `json:"read"`
+	Created     int  `json:"created"`
+	Overwritten int  `json:"overwritten"`
+	Skipped     int  `json:"skipped"`
+	Failed      int  `json:"failed"`
+	DryRun      bool `json:"dry_run"`
+	// Errors describes the first MaxReportedErrors failures.
+	Errors []Failure `json:"errors,omitempty"`
+}
+
+// Failure is a todo that could not be imported.
+type Failure struct {
+	Record int    `json:"record"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error"`
+}
+
+func (r *Report) fail(record int, id string, err error) {
+	r.Failed++
+	if len(r.Errors) < MaxReportedErrors {
+		r.Errors = append(r.Errors, Failure{Record: record, ID: id, Error: err.Error()})
+	}
+}
+
+// Import imports every todo read from dec through service. Todos that are
+// invalid are counted and listed in the report, and the import goes on;
+// it stops at the first conflict under todo.ConflictFail, at malformed
+// input and at storage errors, returning the report so far along with the
+// error. Each todo is imported on its own, so those before a failure stay
+// imported. progress, if not nil, is called every ProgressInterval todos.
+func Import(ctx context.Context, service todo.Service, dec *Decoder, opts todo.ImportOptions, progress func(Report)) (Report, error) {
+	report := Report{DryRun: opts.DryRun}
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		t, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+		var recordErr *RecordError
+		if errors.As(err, &recordErr) {
+			report.Read++
+			report.fail(recordErr.Record, "", recordErr.Err)
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("%w: %w", ErrInput, err)
+		}
+
+		report.Read++
+		_, outcome, err := service.Import(ctx, t, opts)
+		switch {
+		case errors.Is(err, todo.ErrInvalid):
+			report.fail(report.Read, t.ID, err)
+		case err != nil:
+			report.fail(report.Read, t.ID, err)
+			return report, fmt.Errorf("record %d: %w", report.Read, err)
+		case outcome == todo.ImportCreated:
+			report.Created++
+		case outcome == todo.ImportOverwritten:
+			report.Overwritten++
+		case outcome == todo.ImportSkipped:
+			report.Skipped++
+		}
+		if progress != nil && report.Read%ProgressInterval == 0 {
+			progress(report)
+		}
+	}
+}
+
+// Export writes every todo, in ID order, as it is read from the service
+// and returns how many there were.
+func Export(ctx context.Context, service todo.Service, w io.Writer, format Format) (int, error) {
+	enc := NewEncoder(w, format)
+	n := 0
+	err := service.ForEach(ctx, func(t todo.Todo) error {
+		n++
+		return enc.Encode(t)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, enc.Close()
+}
@@ -0,0 +1,185 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/todo/memory"
+	"github.com/jllovet/go-server-template/internal/todo/transfer"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := todo.NewService(memory.New())
+	for _, title := range []string{"Plain", `Quotes "and", commas`, "Line\nbreak"} {
+		created, _ := source.Create(ctx, title)
+		if title == "Plain" {
+			_, _ = source.SetCompleted(ctx, created.ID, true)
+		}
+	}
+	want, _ := source.List(ctx)
+	slices.SortFunc(want, byID)
+
+	for _, format := range transfer.Formats {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := transfer.Export(ctx, source, &buf, format)
+			if err != nil || n != len(want) {
+				t.Fatalf("Export() = %d, %v, want %d todos", n, err, len(want))
+			}
+
+			target := todo.NewService(memory.New())
+			report, err := transfer.Import(ctx, target, transfer.NewDecoder(&buf, format), todo.ImportOptions{KeepIDs: true}, nil)
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if report.Read != len(want) || report.Created != len(want) || report.Failed != 0 {
+				t.Errorf("got report %+v, want %d created", report, len(want))
+			}
+			got, _ := target.List(ctx)
+			slices.SortFunc(got, byID)
+			if !slices.Equal(got, want) {
+				t.Errorf("imported %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func byID(a, b todo.Todo) int {
+	return strings.Compare(a.ID, b.ID)
+}
+
+func TestEmptyExport(t *testing.T) {
+	want := map[transfer.Format]string{
+		transfer.JSON:   "[]\n",
+		transfer.NDJSON: "",
+		transfer.CSV:    "id,title,completed\n",
+	}
+	for _, format := range transfer.Formats {
+		var buf bytes.Buffer
+		if _, err := transfer.Export(context.Background(), todo.NewService(memory.New()), &buf, format); err != nil {
+			t.Fatalf("%s: Export() error = %v", format, err)
+		}
+		if buf.String() != want[format] {
+			t.Errorf("%s: got %q, want %q", format, buf.String(), want[format])
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Invalid records are reported", func(t *testing.T) {
+		in := `{"id": "a", "title": "Good"}
+{"id": "b", "title": ""}
+{"id": "c", "title": "Unknown field", "priority": 1}
+{"id": "d", "title": 42}
+{"id": "e", "title": "Also good"}
+`
+		service := todo.NewService(memory.New())
+		report, err := transfer.Import(ctx, service, transfer.NewDecoder(strings.NewReader(in), transfer.NDJSON), todo.ImportOptions{KeepIDs: true}, nil)
+		if err != nil {
+			t.Fatalf("Import() error = %v", err)
+		}
+		if report.Read != 5 || report.Created != 2 || report.Failed != 3 {
+			t.Errorf("got report %+v, want 2 created and 3 failed of 5", report)
+		}
+		var records []int
+		for _, f := range report.Errors {
+			records = append(records, f.Record)
+		}
+		if !slices.Equal(records, []int{2, 3, 4}) {
+			t.Errorf("got failures at records %v, want 2, 3 and 4", records)
+		}
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		service := todo.NewService(memory.New())
+		_, _, _ = service.Import(ctx, todo.Todo{ID: "b", Title: "Existing"}, todo.ImportOptions{KeepIDs: true})
+		in := "id,title\na,First\nb,Second\nc,Third\n"
+		importCSV := func(policy todo.ConflictPolicy, dryRun bool) (transfer.Report, error) {
+			opts := todo.ImportOptions{KeepIDs: true, OnConflict: policy, DryRun: dryRun}
+			return transfer.Import(ctx, service, transfer.NewDecoder(strings.NewReader(in), transfer.CSV), opts, nil)
+		}
+
+		report, err := importCSV(todo.ConflictFail, true)
+		if !errors.Is(err, todo.ErrConflict) || report.Created != 1 || !report.DryRun {
+			t.Errorf("dry run got %+v, %v, want a conflict after 1 todo", report, err)
+		}
+		if todos, _ := service.List(ctx); len(todos) != 1 {
+			t.Errorf("dry run left %d todos, want 1", len(todos))
+		}
+		if report, err := importCSV(todo.ConflictSkip, false); err != nil || report.Created != 2 || report.Skipped != 1 {
+			t.Errorf("got %+v, %v, want 2 created and 1 skipped", report, err)
+		}
+		if report, err := importCSV(todo.ConflictOverwrite, false); err != nil || report.Overwritten != 3 {
+			t.Errorf("got %+v, %v, want 3 overwritten", report, err)
+		}
+		if got, _ := service.Get(ctx, "b"); got.Title != "Second" {
+			t.Errorf("got title %q, want the overwritten one", got.Title)
+		}
+	})
+
+	t.Run("Malformed input stops the import", func(t *testing.T) {
+		for format, in := range map[transfer.Format]string{
+			transfer.JSON:   `[{"title": "Good"}, {"title": `,
+			transfer.NDJSON: "{\"title\": \"Good\"}\n{oops}\n",
+			transfer.CSV:    "title,colour\nGood,red\n",
+		} {
+			_, err := transfer.Import(ctx, todo.NewService(memory.New()), transfer.NewDecoder(strings.NewReader(in), format), todo.ImportOptions{}, nil)
+			var recordErr *transfer.RecordError
+			if !errors.Is(err, transfer.ErrInput) || errors.As(err, &recordErr) {
+				t.Errorf("%s: Import() error = %v, want an input error ending the import", format, err)
+			}
+		}
+	})
+
+	t.Run("Progress", func(t *testing.T) {
+		var in strings.Builder
+		for range transfer.ProgressInterval + 1 {
+			in.WriteString("{\"title\": \"Todo\"}\n")
+		}
+		var calls []int
+		report, err := transfer.Import(ctx, todo.NewService(memory.New()), transfer.NewDecoder(strings.NewReader(in.String()), transfer.NDJSON), todo.ImportOptions{}, func(r transfer.Report) {
+			calls = append(calls, r.Read)
+		})
+		if err != nil || report.Created != transfer.ProgressInterval+1 {
+			t.Fatalf("Import() = %+v, %v", report, err)
+		}
+		if !slices.Equal(calls, []int{transfer.ProgressInterval}) {
+			t.Errorf("progress called at %v, want once at %d", calls, transfer.ProgressInterval)
+		}
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	in := "\ufeffTitle,Completed\n\"Quoted, title\",TRUE\nNot done,\nBad,maybe\n"
+	dec := transfer.NewDecoder(strings.NewReader(in), transfer.CSV)
+	var got []todo.Todo
+	var recordErrs int
+	for {
+		td, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var recordErr *transfer.RecordError
+		if errors.As(err, &recordErr) {
+			recordErrs++
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		got = append(got, td)
+	}
+	want := []todo.Todo{{Title: "Quoted, title", Completed: true}, {Title: "Not done"}}
+	if !slices.Equal(got, want) || recordErrs != 1 {
+		t.Errorf("got %+v and %d record errors, want %+v and 1", got, recordErrs, want)
+	}
+}
@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/logger"
+	"github.com/segmentio/ksuid"
+)
+
+// Options tunes how deliveries are attempted. Zero values use the defaults below.
+type Options struct {
+	// Client sends delivery requests. Defaults to a client with Timeout.
+	Client *http.Client
+	// Timeout bounds a single delivery attempt. Defaults to 10s.
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a delivery is dead-lettered. Defaults to 8.
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles on every
+	// subsequent attempt up to MaxBackoff. Defaults to 1s and 1h.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// PollInterval is how often Run looks for due retries. Defaults to 1s.
+	PollInterval time.Duration
+	// Concurrency is how many deliveries are attempted at once, so that a
+	// slow subscriber holds up no more than its own. Defaults to 8.
+	Concurrency int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: o.Timeout}
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 8
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Hour
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 8
+	}
+	return o
+}
+
+// leaseMargin is how much longer than an attempt's Timeout a claimed
+// delivery is kept from other dispatchers, to cover recording the outcome.
+// A dispatcher that stops before then leaves it to be claimed again.
+const leaseMargin = time.Minute
+
+// Dispatcher queues deliveries for todo events and sends them to subscribers,
+// retrying failures with exponential backoff until they succeed or go dead.
+type Dispatcher struct {
+	store Store
+	opts  Options
+	wake  chan struct{}
+	now   func() time.Time
+	// workers holds a token for every attempt in progress.
+	workers chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher backed by store.
+func NewDispatcher(store Store, opts Options) *Dispatcher {
+	opts = opts.withDefaults()
+	return &Dispatcher{
+		store:   store,
+		opts:    opts,
+		wake:    make(chan struct{}, 1),
+		now:     time.Now,
+		workers: make(chan struct{}, opts.Concurrency),
+	}
+}
+
+// Publish implements todo.Publisher by queueing a delivery for every
+// subscription interested in e. Delivery itself happens in Run.
+func (d *Dispatcher) Publish(ctx context.Context, e todo.Event) error {
+	subs, err := d.store.FindSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("find subscriptions: %w", err)
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	now := d.now().UTC()
+	queued := 0
+	for _, sub := range subs {
+		if !sub.Matches(e.Type) {
+			continue
+		}
+		del := Delivery{
+			ID:             ksuid.New().String(),
+			SubscriptionID: sub.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        payload,
+			Status:         StatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		if err := d.store.SaveDelivery(ctx, del); err != nil {
+			return fmt.Errorf("queue delivery for subscription %q: %w", sub.ID, err)
+		}
+		queued++
+	}
+	if queued > 0 {
+		d.Wake()
+	}
+	return nil
+}
+
+// Wake asks Run to look for due deliveries now rather than at the next poll.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run attempts due deliveries until ctx is cancelled, then waits for the
+// attempts in progress.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		d.deliverDue(ctx, &wg)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// deliverDue claims as many due deliveries as there are idle workers and
+// starts attempting them. Claiming no more than can start at once keeps
+// deliveries from waiting out their lease behind a slow subscriber.
+func (d *Dispatcher) deliverDue(ctx context.Context, wg *sync.WaitGroup) {
+	idle := cap(d.workers) - len(d.workers)
+	if idle == 0 || ctx.Err() != nil {
+		return
+	}
+	due, err := d.store.ClaimDue(ctx, d.now().UTC(), d.opts.Timeout+leaseMargin, idle)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.FromContext(ctx).Error("failed to claim due webhook deliveries", "error", err)
+		}
+		return
+	}
+	for _, del := range due {
+		// Only Run starts workers, so a token is always free.
+		d.workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.attempt(ctx, del)
+			<-d.workers
+			// The freed worker may have more to do.
+			d.Wake()
+		}()
+	}
+}
+
+// attempt sends del once and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, del Delivery) {
+	log := logger.FromContext(ctx).With("delivery_id", del.ID, "subscription_id", del.SubscriptionID)
+
+	sub, err := d.store.FindSubscription(ctx, del.SubscriptionID)
+	if err != nil {
+		// The subscription was removed while the delivery was queued.
+		del.Status = StatusDead
+		del.LastError = err.Error()
+		if err := d.store.SaveDelivery(ctx, del); err != nil {
+			log.Error("failed to save webhook delivery", "error", err)
+		}
+		return
+	}
+
+	status, sendErr := d.send(ctx, sub, del)
+	now := d.now().UTC()
+	del.Attempts++
+	del.LastAttemptAt = now
+	del.LastStatusCode = status
+	del.LastError = ""
+
+	switch {
+	case sendErr == nil:
+		del.Status = StatusSucceeded
+		log.Info("webhook delivered", "attempts", del.Attempts, "status", status)
+	case del.Attempts >= d.opts.MaxAttempts:
+		del.Status = StatusDead
+		del.LastError = sendErr.Error()
+		log.Error("webhook delivery dead-lettered", "attempts", del.Attempts, "error", sendErr)
+	default:
+		del.LastError = sendErr.Error()
+		del.NextAttemptAt = now.Add(d.backoff(del.Attempts))
+		log.Warn("webhook delivery failed, will retry", "attempts", del.Attempts, "next_attempt_at", del.NextAttemptAt, "error", sendErr)
+	}
+
+	if err := d.store.SaveDelivery(ctx, del); err != nil {
+		log.Error("failed to save webhook delivery", "error", err)
+	}
+}
+
+// send posts the delivery payload to the subscriber and returns the response status.
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, del Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(del.EventType))
+	req.Header.Set(HeaderDelivery, del.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, ts, del.Payload))
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the attempt following attempt n, doubling
+// from Backoff up to MaxBackoff with up to 50% random jitter subtracted so
+// that failing subscribers are not retried in lockstep.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	delay := d.opts.Backoff
+	for i := 1; i < n && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.opts.MaxBackoff)
+	return delay - time.Duration(rand.Int64N(int64(delay)/2+1))
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/webhook"
+)
+
+// Store is an in-memory implementation of webhook.Store.
+type Store struct {
+	// mu protects both maps from concurrent access.
+	mu            sync.RWMutex
+	subscriptions map[string]webhook.Subscription
+	deliveries    map[string]webhook.Delivery
+}
+
+// New creates a new in-memory webhook store.
+func New() *Store {
+	return &Store{
+		subscriptions: make(map[string]webhook.Subscription),
+		deliveries:    make(map[string]webhook.Delivery),
+	}
+}
+
+// SaveSubscription creates or replaces a subscription.
+func (s *Store) SaveSubscription(ctx context.Context, sub webhook.Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub.Events = slices.Clone(sub.Events)
+	s.subscriptions[sub.ID] = sub
+	return nil
+}
+
+// FindSubscription retrieves a subscription by its ID.
+func (s *Store) FindSubscription(ctx context.Context, id string) (webhook.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return webhook.Subscription{}, webhook.ErrNotFound
+	}
+	return sub, nil
+}
+
+// FindSubscriptions retrieves all subscriptions, oldest first.
+func (s *Store) FindSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subs := make([]webhook.Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		subs = append(subs, sub)
+	}
+	slices.SortFunc(subs, func(a, b webhook.Subscription) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return subs, nil
+}
+
+// DeleteSubscription removes a subscription and its delivery history.
+func (s *Store) DeleteSubscription(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscriptions[id]; !ok {
+		return webhook.ErrNotFound
+	}
+	delete(s.subscriptions, id)
+	for did, d := range s.deliveries {
+		if d.SubscriptionID == id {
+			delete(s.deliveries, did)
+		}
+	}
+	return nil
+}
+
+// SaveDelivery creates or replaces a delivery.
+func (s *Store) SaveDelivery(ctx context.Context, d webhook.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+// FindDelivery retrieves a delivery by its ID.
+func (s *Store) FindDelivery(ctx context.Context, id string) (webhook.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return webhook.Delivery{}, webhook.ErrNotFound
+	}
+	return d, nil
+}
+
+// FindDeliveries retrieves the deliveries of a subscription, newest first.
+func (s *Store) FindDeliveries(ctx context.Context, subscriptionID string) ([]webhook.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []webhook.Delivery
+	for _, d := range s.deliveries {
+		if d.SubscriptionID == subscriptionID {
+			out = append(out, d)
+		}
+	}
+	slices.SortFunc(out, func(a, b webhook.Delivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return out, nil
+}
+
+// ClaimDue claims pending deliveries due at or before now, earliest first.
+func (s *Store) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []webhook.Delivery
+	for _, d := range s.deliveries {
+		if d.Status == webhook.StatusPending && !d.NextAttemptAt.After(now) {
+			out = append(out, d)
+		}
+	}
+	slices.SortFunc(out, func(a, b webhook.Delivery) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	for i := range out {
+		out[i].NextAttemptAt = now.Add(lease)
+		s.deliveries[out[i].ID] = out[i]
+	}
+	return out, nil
+}
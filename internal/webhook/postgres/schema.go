@@ -0,0 +1,9 @@
+package postgres
+
+import _ "embed"
+
+// Schema creates or updates the webhook subscription and delivery tables
+// used by Store. Applying it again changes nothing.
+//
+//go:embed schema.sql
+var Schema string
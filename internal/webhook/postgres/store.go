@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/webhook"
+)
+
+// Store implements webhook.Store using PostgreSQL.
+type Store struct {
+	db *sql.DB
+}
+
+// New creates a new Postgres webhook store.
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// SaveSubscription creates or updates a subscription.
+func (s *Store) SaveSubscription(ctx context.Context, sub webhook.Subscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (id, url, events, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE
+		SET url = EXCLUDED.url, events = EXCLUDED.events, secret = EXCLUDED.secret
+	`
+	_, err := s.db.ExecContext(ctx, query, sub.ID, sub.URL, joinEvents(sub.Events), sub.Secret, sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres save subscription: %w", err)
+	}
+	return nil
+}
+
+// FindSubscription retrieves a subscription by ID.
+func (s *Store) FindSubscription(ctx context.Context, id string) (webhook.Subscription, error) {
+	query := `SELECT id, url, events, secret, created_at FROM webhook_subscriptions WHERE id = $1`
+	sub, err := scanSubscription(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webhook.Subscription{}, webhook.ErrNotFound
+		}
+		return webhook.Subscription{}, fmt.Errorf("postgres find subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// FindSubscriptions retrieves all subscriptions, oldest first.
+func (s *Store) FindSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	query := `SELECT id, url, events, secret, created_at FROM webhook_subscriptions ORDER BY created_at`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("postgres find subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []webhook.Subscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres scan: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// DeleteSubscription removes a subscription; its deliveries cascade.
+func (s *Store) DeleteSubscription(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("postgres delete subscription: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return webhook.ErrNotFound
+	}
+	return nil
+}
+
+// SaveDelivery creates or updates a delivery.
+func (s *Store) SaveDelivery(ctx context.Context, d webhook.Delivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (
+			id, subscription_id, event_id, event_type, payload, status, attempts,
+			next_attempt_at, last_attempt_at, last_status_code, last_error, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE
+		SET status = EXCLUDED.status,
+			attempts = EXCLUDED.attempts,
+			next_attempt_at = EXCLUDED.next_attempt_at,
+			last_attempt_at = EXCLUDED.last_attempt_at,
+			last_status_code = EXCLUDED.last_status_code,
+			last_error = EXCLUDED.last_error
+	`
+	var lastAttempt sql.NullTime
+	if !d.LastAttemptAt.IsZero() {
+		lastAttempt = sql.NullTime{Time: d.LastAttemptAt, Valid: true}
+	}
+	_, err := s.db.ExecContext(ctx, query,
+		d.ID, d.SubscriptionID, d.EventID, string(d.EventType), string(d.Payload), string(d.Status), d.Attempts,
+		d.NextAttemptAt, lastAttempt, d.LastStatusCode, d.LastError, d.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres save delivery: %w", err)
+	}
+	return nil
+}
+
+const deliveryColumns = `
+	id, subscription_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_attempt_at, last_status_code, last_error, created_at
+`
+
+// FindDelivery retrieves a delivery by ID.
+func (s *Store) FindDelivery(ctx context.Context, id string) (webhook.Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+	d, err := scanDelivery(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webhook.Delivery{}, webhook.ErrNotFound
+		}
+		return webhook.Delivery{}, fmt.Errorf("postgres find delivery: %w", err)
+	}
+	return d, nil
+}
+
+// FindDeliveries retrieves the deliveries of a subscription, newest first.
+func (s *Store) FindDeliveries(ctx context.Context, subscriptionID string) ([]webhook.Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE subscription_id = $1 ORDER BY created_at DESC`
+	return s.queryDeliveries(ctx, query, subscriptionID)
+}
+
+// ClaimDue claims pending deliveries due at or before now, earliest first.
+// SKIP LOCKED lets dispatchers claiming at the same time take different
+// deliveries rather than wait for each other.
+func (s *Store) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	query := `UPDATE webhook_deliveries SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns
+	return s.queryDeliveries(ctx, query, now, now.Add(lease), limit)
+}
+
+func (s *Store) queryDeliveries(ctx context.Context, query string, args ...any) ([]webhook.Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("postgres find deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var out []webhook.Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres scan: %w", err)
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(row scanner) (webhook.Subscription, error) {
+	var sub webhook.Subscription
+	var events string
+	if err := row.Scan(&sub.ID, &sub.URL, &events, &sub.Secret, &sub.CreatedAt); err != nil {
+		return webhook.Subscription{}, err
+	}
+	sub.Events = splitEvents(events)
+	return sub, nil
+}
+
+func scanDelivery(row scanner) (webhook.Delivery, error) {
+	var d webhook.Delivery
+	var eventType, status string
+	var payload []byte
+	var lastAttempt sql.NullTime
+	err := row.Scan(
+		&d.ID, &d.SubscriptionID, &d.EventID, &eventType, &payload, &status, &d.Attempts,
+		&d.NextAttemptAt, &lastAttempt, &d.LastStatusCode, &d.LastError, &d.CreatedAt,
+	)
+	if err != nil {
+		return webhook.Delivery{}, err
+	}
+	d.EventType = todo.EventType(eventType)
+	d.Status = webhook.Status(status)
+	d.Payload = payload
+	if lastAttempt.Valid {
+		d.LastAttemptAt = lastAttempt.Time
+	}
+	return d, nil
+}
+
+// Event filters are stored as a comma separated list; event types never contain commas.
+func joinEvents(events []todo.EventType) string {
+	parts := make([]string, len(events))
+	for i, e := range events {
+		parts[i] = string(e)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitEvents(s string) []todo.EventType {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	events := make([]todo.EventType, len(parts))
+	for i, p := range parts {
+		events[i] = todo.EventType(p)
+	}
+	return events
+}
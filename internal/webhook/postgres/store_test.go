@@ -0,0 +1,132 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/webhook"
+	"github.com/jllovet/go-server-template/internal/webhook/postgres"
+)
+
+func TestStore(t *testing.T) {
+	// Skip if TEST_DATABASE_URL is not set.
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("Skipping postgres webhook store tests: TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("pgx", dbURL)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	cleanDB := func() {
+		if _, err := db.Exec("TRUNCATE TABLE webhook_subscriptions CASCADE"); err != nil {
+			t.Fatalf("failed to truncate tables: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	sub := webhook.Subscription{
+		ID:        "sub-1",
+		URL:       "https://example.com/hook",
+		Events:    []todo.EventType{todo.EventCreated, todo.EventCompleted},
+		Secret:    "s3cret",
+		CreatedAt: now,
+	}
+	delivery := func(id string, status webhook.Status, next time.Time) webhook.Delivery {
+		return webhook.Delivery{
+			ID:             id,
+			SubscriptionID: sub.ID,
+			EventID:        "evt-" + id,
+			EventType:      todo.EventCreated,
+			Payload:        []byte(`{"id":"evt"}`),
+			Status:         status,
+			NextAttemptAt:  next,
+			CreatedAt:      next,
+		}
+	}
+
+	t.Run("Save and FindSubscription", func(t *testing.T) {
+		cleanDB()
+		store := postgres.New(db)
+		if err := store.SaveSubscription(ctx, sub); err != nil {
+			t.Fatalf("SaveSubscription() error = %v", err)
+		}
+		found, err := store.FindSubscription(ctx, sub.ID)
+		if err != nil {
+			t.Fatalf("FindSubscription() error = %v", err)
+		}
+		if found.URL != sub.URL || found.Secret != sub.Secret || len(found.Events) != 2 {
+			t.Errorf("got %+v, want %+v", found, sub)
+		}
+		if _, err := store.FindSubscription(ctx, "missing"); !errors.Is(err, webhook.ErrNotFound) {
+			t.Errorf("FindSubscription() error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ClaimDue and FindDeliveries", func(t *testing.T) {
+		cleanDB()
+		store := postgres.New(db)
+		_ = store.SaveSubscription(ctx, sub)
+		_ = store.SaveDelivery(ctx, delivery("due", webhook.StatusPending, now.Add(-time.Minute)))
+		_ = store.SaveDelivery(ctx, delivery("later", webhook.StatusPending, now.Add(time.Hour)))
+		_ = store.SaveDelivery(ctx, delivery("done", webhook.StatusSucceeded, now.Add(-time.Hour)))
+
+		due, err := store.ClaimDue(ctx, now, time.Minute, 10)
+		if err != nil {
+			t.Fatalf("ClaimDue() error = %v", err)
+		}
+		if len(due) != 1 || due[0].ID != "due" || !due[0].NextAttemptAt.Equal(now.Add(time.Minute)) {
+			t.Errorf("ClaimDue() got %+v, want only %q leased for a minute", due, "due")
+		}
+		// A claimed delivery is not claimed again until its lease runs out.
+		if again, _ := store.ClaimDue(ctx, now, time.Minute, 10); len(again) != 0 {
+			t.Errorf("ClaimDue() claimed %+v again", again)
+		}
+		if again, _ := store.ClaimDue(ctx, now.Add(time.Minute), time.Minute, 10); len(again) != 1 {
+			t.Errorf("ClaimDue() got %d deliveries after the lease, want 1", len(again))
+		}
+
+		all, err := store.FindDeliveries(ctx, sub.ID)
+		if err != nil {
+			t.Fatalf("FindDeliveries() error = %v", err)
+		}
+		if len(all) != 3 || all[0].ID != "later" {
+			t.Errorf("FindDeliveries() got %d deliveries starting %q, want 3 newest first", len(all), all[0].ID)
+		}
+	})
+
+	t.Run("DeleteSubscription cascades", func(t *testing.T) {
+		cleanDB()
+		store := postgres.New(db)
+		_ = store.SaveSubscription(ctx, sub)
+		_ = store.SaveDelivery(ctx, delivery("d1", webhook.StatusPending, now))
+
+		if err := store.DeleteSubscription(ctx, sub.ID); err != nil {
+			t.Fatalf("DeleteSubscription() error = %v", err)
+		}
+		if _, err := store.FindDelivery(ctx, "d1"); !errors.Is(err, webhook.ErrNotFound) {
+			t.Errorf("FindDelivery() after delete error = %v, want ErrNotFound", err)
+		}
+		if err := store.DeleteSubscription(ctx, sub.ID); !errors.Is(err, webhook.ErrNotFound) {
+			t.Errorf("second DeleteSubscription() error = %v, want ErrNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/logger"
+	"github.com/segmentio/ksuid"
+)
+
+// service implements the Service interface.
+type service struct {
+	store      Store
+	dispatcher *Dispatcher
+}
+
+// NewService creates a webhook service. Redeliveries are handed to dispatcher.
+func NewService(store Store, dispatcher *Dispatcher) Service {
+	return &service{store: store, dispatcher: dispatcher}
+}
+
+// Subscribe validates and stores a new subscription. When secret is empty a
+// random one is generated; either way it is returned only in this call.
+func (s *service) Subscribe(ctx context.Context, rawURL string, events []todo.EventType, secret string) (Subscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalid)
+	}
+	for _, e := range events {
+		if !e.Valid() {
+			return Subscription{}, fmt.Errorf("%w: unknown event %q", ErrInvalid, e)
+		}
+	}
+	if secret == "" {
+		if secret, err = newSecret(); err != nil {
+			return Subscription{}, fmt.Errorf("generate secret: %w", err)
+		}
+	}
+
+	sub := Subscription{
+		ID:        ksuid.New().String(),
+		URL:       u.String(),
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	logger.FromContext(ctx).Info("creating webhook subscription", "id", sub.ID, "url", sub.URL)
+
+	if err := s.store.SaveSubscription(ctx, sub); err != nil {
+		return Subscription{}, fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return sub, nil
+}
+
+func (s *service) Get(ctx context.Context, id string) (Subscription, error) {
+	sub, err := s.store.FindSubscription(ctx, id)
+	if err != nil {
+		return Subscription{}, fmt.Errorf("failed to get subscription %q: %w", id, err)
+	}
+	return sub, nil
+}
+
+func (s *service) List(ctx context.Context) ([]Subscription, error) {
+	subs, err := s.store.FindSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+func (s *service) Unsubscribe(ctx context.Context, id string) error {
+	logger.FromContext(ctx).Info("deleting webhook subscription", "id", id)
+	if err := s.store.DeleteSubscription(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete subscription %q: %w", id, err)
+	}
+	return nil
+}
+
+func (s *service) Deliveries(ctx context.Context, subscriptionID string) ([]Delivery, error) {
+	if _, err := s.store.FindSubscription(ctx, subscriptionID); err != nil {
+		return nil, fmt.Errorf("failed to get subscription %q: %w", subscriptionID, err)
+	}
+	deliveries, err := s.store.FindDeliveries(ctx, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a fresh copy of a past delivery, whatever its status.
+// The original is kept unchanged so the history stays intact.
+func (s *service) Redeliver(ctx context.Context, subscriptionID, deliveryID string) (Delivery, error) {
+	orig, err := s.store.FindDelivery(ctx, deliveryID)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("failed to get delivery %q: %w", deliveryID, err)
+	}
+	if orig.SubscriptionID != subscriptionID {
+		return Delivery{}, fmt.Errorf("failed to get delivery %q: %w", deliveryID, ErrNotFound)
+	}
+
+	now := time.Now().UTC()
+	del := Delivery{
+		ID:             ksuid.New().String(),
+		SubscriptionID: orig.SubscriptionID,
+		EventID:        orig.EventID,
+		EventType:      orig.EventType,
+		Payload:        orig.Payload,
+		Status:         StatusPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+
+	logger.FromContext(ctx).Info("redelivering webhook", "id", del.ID, "original_id", orig.ID)
+
+	if err := s.store.SaveDelivery(ctx, del); err != nil {
+		return Delivery{}, fmt.Errorf("failed to queue redelivery: %w", err)
+	}
+	s.dispatcher.Wake()
+	return del, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
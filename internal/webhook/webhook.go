@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+)
+
+var (
+	// ErrNotFound is returned when a subscription or delivery does not exist.
+	ErrNotFound = errors.New("webhook not found")
+	// ErrInvalid is returned when a subscription fails validation.
+	ErrInvalid = errors.New("invalid webhook subscription")
+)
+
+// Subscription registers a URL to receive signed deliveries of todo events.
+type Subscription struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Events filters which event types are delivered. Empty means all events.
+	Events []todo.EventType `json:"events"`
+	// Secret is the HMAC-SHA256 key used to sign deliveries. It is never
+	// serialized; callers only see it once, when the subscription is created.
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether the subscription wants events of type t.
+func (s Subscription) Matches(t todo.EventType) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, t)
+}
+
+// Status is the lifecycle state of a Delivery.
+type Status string
+
+const (
+	// StatusPending deliveries are waiting for their next attempt.
+	StatusPending Status = "pending"
+	// StatusSucceeded deliveries were acknowledged with a 2xx response.
+	StatusSucceeded Status = "succeeded"
+	// StatusDead deliveries exhausted their attempts and will not be retried
+	// unless redelivered explicitly.
+	StatusDead Status = "dead"
+)
+
+// Delivery is a single event queued for a single subscription, together with
+// the outcome of its most recent attempt.
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      todo.EventType  `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         Status          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  time.Time       `json:"last_attempt_at,omitzero"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Store persists subscriptions and their deliveries.
+// In Hexagonal Architecture, this is a "Driven Port".
+type Store interface {
+	SaveSubscription(ctx context.Context, s Subscription) error
+	FindSubscription(ctx context.Context, id string) (Subscription, error)
+	FindSubscriptions(ctx context.Context) ([]Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+
+	SaveDelivery(ctx context.Context, d Delivery) error
+	FindDelivery(ctx context.Context, id string) (Delivery, error)
+	// FindDeliveries returns the delivery history of a subscription, newest first.
+	FindDeliveries(ctx context.Context, subscriptionID string) ([]Delivery, error)
+	// ClaimDue returns up to limit pending deliveries whose next attempt is
+	// at or before now, after moving their next attempt to now+lease in the
+	// same atomic step, so that no other dispatcher claims them until the
+	// lease runs out.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+}
+
+// Service manages webhook subscriptions.
+// In Hexagonal Architecture, this is a "Driving Port" used by the HTTP handler.
+type Service interface {
+	Subscribe(ctx context.Context, url string, events []todo.EventType, secret string) (Subscription, error)
+	Get(ctx context.Context, id string) (Subscription, error)
+	List(ctx context.Context) ([]Subscription, error)
+	Unsubscribe(ctx context.Context, id string) error
+	Deliveries(ctx context.Context, subscriptionID string) ([]Delivery, error)
+	Redeliver(ctx context.Context, subscriptionID, deliveryID string) (Delivery, error)
+}
@@ -0,0 +1,361 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/internal/todo"
+	"github.com/jllovet/go-server-template/internal/webhook"
+	"github.com/jllovet/go-server-template/internal/webhook/memory"
+)
+
+// receiver is a local webhook endpoint that fails the first failures requests
+// and records every request it accepts.
+type receiver struct {
+	*httptest.Server
+	failures atomic.Int32
+
+	mu       sync.Mutex
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, failures int) *receiver {
+	t.Helper()
+	rcv := &receiver{}
+	rcv.failures.Store(int32(failures))
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rcv.failures.Add(-1) >= 0 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		rcv.requests = append(rcv.requests, receivedRequest{header: r.Header.Clone(), body: body})
+		rcv.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// setup wires a store, dispatcher and service, and runs the dispatcher until the test ends.
+func setup(t *testing.T, opts webhook.Options) (webhook.Service, *webhook.Dispatcher) {
+	t.Helper()
+	if opts.PollInterval == 0 {
+		opts.PollInterval = 5 * time.Millisecond
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = time.Millisecond
+		opts.MaxBackoff = 5 * time.Millisecond
+	}
+	store := memory.New()
+	dispatcher := webhook.NewDispatcher(store, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return webhook.NewService(store, dispatcher), dispatcher
+}
+
+// waitForDeliveries polls until the subscription's latest delivery satisfies ok.
+func waitForDeliveries(t *testing.T, svc webhook.Service, subID string, ok func([]webhook.Delivery) bool) []webhook.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ds, err := svc.Deliveries(context.Background(), subID)
+		if err != nil {
+			t.Fatalf("Deliveries() error = %v", err)
+		}
+		if ok(ds) {
+			return ds
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for deliveries, last state: %+v", ds)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func allInStatus(status webhook.Status) func([]webhook.Delivery) bool {
+	return func(ds []webhook.Delivery) bool {
+		if len(ds) == 0 {
+			return false
+		}
+		for _, d := range ds {
+			if d.Status != status {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func event(typ todo.EventType) todo.Event {
+	return todo.Event{
+		ID:         "evt-" + string(typ),
+		Type:       typ,
+		Todo:       todo.Todo{ID: "1", Title: "Ship it", Completed: typ == todo.EventCompleted},
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Delivers signed payload", func(t *testing.T) {
+		rcv := newReceiver(t, 0)
+		svc, dispatcher := setup(t, webhook.Options{})
+		sub, err := svc.Subscribe(ctx, rcv.URL, nil, "s3cret")
+		if err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+
+		if err := dispatcher.Publish(ctx, event(todo.EventCompleted)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		ds := waitForDeliveries(t, svc, sub.ID, allInStatus(webhook.StatusSucceeded))
+		if ds[0].Attempts != 1 || ds[0].LastStatusCode != http.StatusNoContent {
+			t.Errorf("got attempts=%d status=%d, want 1 and 204", ds[0].Attempts, ds[0].LastStatusCode)
+		}
+
+		reqs := rcv.received()
+		if len(reqs) != 1 {
+			t.Fatalf("receiver got %d requests, want 1", len(reqs))
+		}
+		got := reqs[0]
+		ts, err := strconv.ParseInt(got.header.Get(webhook.HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Fatalf("bad timestamp header: %v", err)
+		}
+		if !webhook.Verify("s3cret", got.header.Get(webhook.HeaderSignature), ts, got.body) {
+			t.Error("signature did not verify")
+		}
+		if webhook.Verify("wrong", got.header.Get(webhook.HeaderSignature), ts, got.body) {
+			t.Error("signature verified with the wrong secret")
+		}
+		if h := got.header.Get(webhook.HeaderEvent); h != string(todo.EventCompleted) {
+			t.Errorf("event header = %q, want %q", h, todo.EventCompleted)
+		}
+		if h := got.header.Get(webhook.HeaderDelivery); h != ds[0].ID {
+			t.Errorf("delivery header = %q, want %q", h, ds[0].ID)
+		}
+		var e todo.Event
+		if err := json.Unmarshal(got.body, &e); err != nil {
+			t.Fatalf("payload is not an event: %v", err)
+		}
+		if e.Type != todo.EventCompleted || !e.Todo.Completed {
+			t.Errorf("payload = %+v, want completed event", e)
+		}
+	})
+
+	t.Run("Filters by event type", func(t *testing.T) {
+		rcv := newReceiver(t, 0)
+		svc, dispatcher := setup(t, webhook.Options{})
+		sub, _ := svc.Subscribe(ctx, rcv.URL, []todo.EventType{todo.EventCompleted}, "")
+
+		_ = dispatcher.Publish(ctx, event(todo.EventCreated))
+		_ = dispatcher.Publish(ctx, event(todo.EventCompleted))
+
+		ds := waitForDeliveries(t, svc, sub.ID, allInStatus(webhook.StatusSucceeded))
+		if len(ds) != 1 || ds[0].EventType != todo.EventCompleted {
+			t.Fatalf("got deliveries %+v, want a single todo.completed", ds)
+		}
+	})
+
+	t.Run("Retries with backoff until success", func(t *testing.T) {
+		rcv := newReceiver(t, 2)
+		svc, dispatcher := setup(t, webhook.Options{MaxAttempts: 5})
+		sub, _ := svc.Subscribe(ctx, rcv.URL, nil, "")
+
+		_ = dispatcher.Publish(ctx, event(todo.EventCreated))
+
+		ds := waitForDeliveries(t, svc, sub.ID, allInStatus(webhook.StatusSucceeded))
+		if ds[0].Attempts != 3 {
+			t.Errorf("got %d attempts, want 3", ds[0].Attempts)
+		}
+		if ds[0].LastError != "" {
+			t.Errorf("LastError = %q, want it cleared after success", ds[0].LastError)
+		}
+	})
+
+	t.Run("Dead-letters after max attempts", func(t *testing.T) {
+		rcv := newReceiver(t, 1000)
+		svc, dispatcher := setup(t, webhook.Options{MaxAttempts: 3})
+		sub, _ := svc.Subscribe(ctx, rcv.URL, nil, "")
+
+		_ = dispatcher.Publish(ctx, event(todo.EventCreated))
+
+		ds := waitForDeliveries(t, svc, sub.ID, allInStatus(webhook.StatusDead))
+		if ds[0].Attempts != 3 {
+			t.Errorf("got %d attempts, want 3", ds[0].Attempts)
+		}
+		if ds[0].LastStatusCode != http.StatusServiceUnavailable || ds[0].LastError == "" {
+			t.Errorf("got status=%d error=%q, want 503 and an error", ds[0].LastStatusCode, ds[0].LastError)
+		}
+	})
+
+	t.Run("Redeliver queues a fresh attempt", func(t *testing.T) {
+		rcv := newReceiver(t, 2)
+		svc, dispatcher := setup(t, webhook.Options{MaxAttempts: 2})
+		sub, _ := svc.Subscribe(ctx, rcv.URL, nil, "")
+
+		_ = dispatcher.Publish(ctx, event(todo.EventCreated))
+		dead := waitForDeliveries(t, svc, sub.ID, allInStatus(webhook.StatusDead))[0]
+
+		again, err := svc.Redeliver(ctx, sub.ID, dead.ID)
+		if err != nil {
+			t.Fatalf("Redeliver() error = %v", err)
+		}
+		if again.ID == dead.ID || again.EventID != dead.EventID {
+			t.Errorf("redelivery %+v should be a new delivery of event %q", again, dead.EventID)
+		}
+
+		ds := waitForDeliveries(t, svc, sub.ID, func(ds []webhook.Delivery) bool {
+			return len(ds) == 2 && ds[0].Status == webhook.StatusSucceeded
+		})
+		if ds[1].ID != dead.ID || ds[1].Status != webhook.StatusDead {
+			t.Errorf("original delivery changed: %+v", ds[1])
+		}
+
+		if _, err := svc.Redeliver(ctx, "other-subscription", dead.ID); !errors.Is(err, webhook.ErrNotFound) {
+			t.Errorf("Redeliver() for wrong subscription error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestDispatcher_Concurrency(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Slow subscriber does not hold up others", func(t *testing.T) {
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		t.Cleanup(slow.Close)
+		t.Cleanup(func() { close(release) })
+		fast := newReceiver(t, 0)
+		svc, dispatcher := setup(t, webhook.Options{Timeout: time.Minute})
+		_, _ = svc.Subscribe(ctx, slow.URL, []todo.EventType{todo.EventCreated}, "")
+		sub, _ := svc.Subscribe(ctx, fast.URL, []todo.EventType{todo.EventCompleted}, "")
+
+		// The slow delivery is queued, and picked up, first.
+		_ = dispatcher.Publish(ctx, event(todo.EventCreated))
+		time.Sleep(20 * time.Millisecond)
+		_ = dispatcher.Publish(ctx, event(todo.EventCompleted))
+		waitForDeliveries(t, svc, sub.ID, allInStatus(webhook.StatusSucceeded))
+	})
+
+	t.Run("Dispatchers sharing a store deliver once", func(t *testing.T) {
+		rcv := newReceiver(t, 0)
+		store := memory.New()
+		opts := webhook.Options{PollInterval: time.Millisecond}
+		dispatchers := []*webhook.Dispatcher{webhook.NewDispatcher(store, opts), webhook.NewDispatcher(store, opts)}
+		runCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for _, d := range dispatchers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.Run(runCtx)
+			}()
+		}
+		t.Cleanup(func() {
+			cancel()
+			wg.Wait()
+		})
+		svc := webhook.NewService(store, dispatchers[0])
+		sub, _ := svc.Subscribe(ctx, rcv.URL, nil, "")
+
+		const events = 20
+		for i := range events {
+			e := event(todo.EventUpdated)
+			e.ID = strconv.Itoa(i)
+			_ = dispatchers[i%2].Publish(ctx, e)
+		}
+		ds := waitForDeliveries(t, svc, sub.ID, func(ds []webhook.Delivery) bool {
+			return len(ds) == events && allInStatus(webhook.StatusSucceeded)(ds)
+		})
+		for _, d := range ds {
+			if d.Attempts != 1 {
+				t.Errorf("delivery %s took %d attempts, want 1", d.ID, d.Attempts)
+			}
+		}
+		if got := len(rcv.received()); got != events {
+			t.Errorf("receiver got %d requests, want %d", got, events)
+		}
+	})
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := setup(t, webhook.Options{})
+
+	t.Run("Subscribe validates input", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			url    string
+			events []todo.EventType
+		}{
+			{"relative url", "/hook", nil},
+			{"bad scheme", "ftp://example.com/hook", nil},
+			{"unknown event", "https://example.com/hook", []todo.EventType{"todo.exploded"}},
+		}
+		for _, tc := range cases {
+			if _, err := svc.Subscribe(ctx, tc.url, tc.events, ""); !errors.Is(err, webhook.ErrInvalid) {
+				t.Errorf("%s: Subscribe() error = %v, want ErrInvalid", tc.name, err)
+			}
+		}
+	})
+
+	t.Run("Generates a secret when none is given", func(t *testing.T) {
+		sub, err := svc.Subscribe(ctx, "https://example.com/hook", nil, "")
+		if err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		if sub.Secret == "" {
+			t.Error("expected a generated secret")
+		}
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		sub, _ := svc.Subscribe(ctx, "https://example.com/hook", nil, "")
+		if err := svc.Unsubscribe(ctx, sub.ID); err != nil {
+			t.Fatalf("Unsubscribe() error = %v", err)
+		}
+		if _, err := svc.Get(ctx, sub.ID); !errors.Is(err, webhook.ErrNotFound) {
+			t.Errorf("Get() after Unsubscribe() error = %v, want ErrNotFound", err)
+		}
+		if err := svc.Unsubscribe(ctx, sub.ID); !errors.Is(err, webhook.ErrNotFound) {
+			t.Errorf("second Unsubscribe() error = %v, want ErrNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ComponentKey is the attribute naming the part of the program a logger
+// belongs to, whose level Levels may set apart from the rest.
+const ComponentKey = "component"
+
+// Levels holds the least severe level logged, overall and for components
+// that override it, and lets them change while loggers use them.
+type Levels struct {
+	mu        sync.RWMutex
+	level     slog.LevelVar
+	overrides map[string]*slog.LevelVar
+	// restores undo the changes made by SetFor, by component.
+	restores map[string]*restore
+}
+
+// restore puts a component back to its level from before SetFor, or to
+// no override when had is false.
+type restore struct {
+	level slog.Level
+	had   bool
+	timer *time.Timer
+}
+
+// NewLevels returns Levels logging at level, with no overrides.
+func NewLevels(level slog.Level) *Levels {
+	l := &Levels{overrides: map[string]*slog.LevelVar{}, restores: map[string]*restore{}}
+	l.level.Set(level)
+	return l
+}
+
+// Level returns the level of component, or the overall level when it has
+// no override or is "".
+func (l *Levels) Level(component string) slog.Level {
+	l.mu.RLock()
+	v, ok := l.overrides[component]
+	l.mu.RUnlock()
+	if ok {
+		return v.Level()
+	}
+	return l.level.Level()
+}
+
+// Snapshot returns the overall level and the overrides.
+func (l *Levels) Snapshot() (slog.Level, map[string]slog.Level) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	overrides := make(map[string]slog.Level, len(l.overrides))
+	for c, v := range l.overrides {
+		overrides[c] = v.Level()
+	}
+	return l.level.Level(), overrides
+}
+
+// Configure replaces the overall level and every override, cancelling
+// pending SetFor restores.
+func (l *Levels) Configure(level slog.Level, overrides map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for c, r := range l.restores {
+		r.timer.Stop()
+		delete(l.restores, c)
+	}
+	l.level.Set(level)
+	l.overrides = make(map[string]*slog.LevelVar, len(overrides))
+	for c, lvl := range overrides {
+		l.setLocked(c, lvl)
+	}
+}
+
+// Set sets the level of component, or the overall level when component is
+// "". It makes any pending SetFor change of component permanent.
+func (l *Levels) Set(component string, level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cancelRestoreLocked(component)
+	l.setLocked(component, level)
+}
+
+// Unset removes the override of component, which then logs at the overall
+// level.
+func (l *Levels) Unset(component string) {
+	if component == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cancelRestoreLocked(component)
+	delete(l.overrides, component)
+}
+
+// SetFor is Set for d, after which component goes back to the level it had
+// before the first of any overlapping SetFor calls.
+func (l *Levels) SetFor(component string, level slog.Level, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, pending := l.restores[component]
+	if pending {
+		r.timer.Stop()
+	} else {
+		r = &restore{level: l.level.Level()}
+		if component != "" {
+			if v, ok := l.overrides[component]; ok {
+				r.level, r.had = v.Level(), true
+			}
+		}
+		l.restores[component] = r
+	}
+	l.setLocked(component, level)
+	r.timer = time.AfterFunc(d, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// A later change may have replaced r.
+		if l.restores[component] != r {
+			return
+		}
+		delete(l.restores, component)
+		if component != "" && !r.had {
+			delete(l.overrides, component)
+			return
+		}
+		l.setLocked(component, r.level)
+	})
+}
+
+func (l *Levels) setLocked(component string, level slog.Level) {
+	if component == "" {
+		l.level.Set(level)
+		return
+	}
+	v, ok := l.overrides[component]
+	if !ok {
+		v = new(slog.LevelVar)
+		l.overrides[component] = v
+	}
+	v.Set(level)
+}
+
+func (l *Levels) cancelRestoreLocked(component string) {
+	if r, ok := l.restores[component]; ok {
+		r.timer.Stop()
+		delete(l.restores, component)
+	}
+}
+
+// ParseLevel parses debug, info, warn or error, in any case.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown level %q, want debug, info, warn or error", s)
+}
+
+// ParseLevels parses a comma-separated list of component=level overrides,
+// such as "webhook=debug,http=warn".
+func ParseLevels(s string) (map[string]slog.Level, error) {
+	overrides := map[string]slog.Level{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		component, level, ok := strings.Cut(part, "=")
+		component = strings.TrimSpace(component)
+		if !ok || component == "" {
+			return nil, fmt.Errorf("%q is not component=level", part)
+		}
+		lvl, err := ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", component, err)
+		}
+		overrides[component] = lvl
+	}
+	return overrides, nil
+}
+
+// levelHandler drops the records below the level of the component they are
+// logged for: the last ComponentKey attribute given to Logger.With outside
+// a group. That attribute is written once, however often it is replaced.
+type levelHandler struct {
+	next      slog.Handler
+	levels    *Levels
+	component string
+	// written is set once the component has been passed to next, because
+	// a group was opened after it.
+	written bool
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.component) && h.next.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.written && h.component != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String(ComponentKey, h.component))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	if h.written {
+		c.next = h.next.WithAttrs(attrs)
+		return &c
+	}
+	rest := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Key == ComponentKey {
+			c.component = a.Value.String()
+			continue
+		}
+		rest = append(rest, a)
+	}
+	if len(rest) > 0 {
+		c.next = h.next.WithAttrs(rest)
+	}
+	return &c
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	next := h.next
+	if !h.written && h.component != "" {
+		next = next.WithAttrs([]slog.Attr{slog.String(ComponentKey, h.component)})
+	}
+	c.next = next.WithGroup(name)
+	c.written = true
+	return &c
+}
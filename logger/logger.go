@@ -2,18 +2,61 @@ package logger
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 )
 
+// Format is how a logger writes records.
+type Format string
+
+const (
+	// FormatJSON writes a JSON object per record, for log collectors.
+	FormatJSON Format = "json"
+	// FormatText writes logfmt-style key=value lines.
+	FormatText Format = "text"
+	// FormatPretty writes coloured, aligned lines for local development.
+	FormatPretty Format = "pretty"
+)
+
+// Formats lists every Format.
+var Formats = []Format{FormatJSON, FormatText, FormatPretty}
+
+// ParseFormat parses the name of a Format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatJSON, FormatText, FormatPretty:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown log format %q, want json, text or pretty", s)
+}
+
+// Options configures NewWithOptions.
+type Options struct {
+	// Format defaults to FormatJSON.
+	Format Format
+	// Levels decides what is logged, by component. Nil logs at info.
+	Levels *Levels
+	// Redact decides what is scrubbed from every record.
+	Redact RedactOptions
+	// Sample thins out frequent records. The zero value keeps them all.
+	Sample SampleOptions
+}
+
 func New(out io.Writer, component string) *slog.Logger {
+	return NewWithLevel(out, component, slog.LevelInfo)
+}
+
+// NewWithLevel is New logging at level, which may be a *slog.LevelVar to
+// change it while the logger is in use.
+func NewWithLevel(out io.Writer, component string, level slog.Leveler) *slog.Logger {
 	if out == nil {
 		out = os.Stdout
 	}
 
 	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+		Level: level,
 	})
 
 	logger := slog.New(handler)
@@ -23,6 +66,42 @@ func New(out io.Writer, component string) *slog.Logger {
 	return logger
 }
 
+// NewWithOptions is New writing in opts.Format at the levels of
+// opts.Levels, sampled and redacted as opts.Sample and opts.Redact say.
+// Loggers derived from it with a different ComponentKey attribute, such as
+// log.With("component", "webhook"), log at that component's level.
+func NewWithOptions(out io.Writer, component string, opts Options) *slog.Logger {
+	if out == nil {
+		out = os.Stdout
+	}
+	levels := opts.Levels
+	if levels == nil {
+		levels = NewLevels(slog.LevelInfo)
+	}
+
+	// levelHandler does the filtering, so the handler it wraps lets
+	// everything through.
+	all := &slog.HandlerOptions{Level: slog.Level(-1 << 10)}
+	var handler slog.Handler
+	switch opts.Format {
+	case FormatText:
+		handler = slog.NewTextHandler(out, all)
+	case FormatPretty:
+		handler = newPrettyHandler(out, all.Level)
+	default:
+		handler = slog.NewJSONHandler(out, all)
+	}
+
+	// Records dropped by sampling need not be redacted.
+	handler = NewSamplingHandler(NewRedactingHandler(handler, opts.Redact), opts.Sample)
+
+	logger := slog.New(&levelHandler{next: handler, levels: levels})
+	if component != "" {
+		logger = logger.With(ComponentKey, component)
+	}
+	return logger
+}
+
 func Stdout(component string) *slog.Logger {
 	return New(os.Stdout, component)
 }
@@ -39,3 +118,16 @@ func FromContext(ctx context.Context) *slog.Logger {
 	}
 	return slog.Default()
 }
+
+type requestIDKey struct{}
+
+// WithRequestID stores the ID of the request being served in ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,125 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// records decodes the JSON lines written to buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		out = append(out, rec)
+	}
+	buf.Reset()
+	return out
+}
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	levels := logger.NewLevels(slog.LevelInfo)
+	levels.Configure(slog.LevelInfo, map[string]slog.Level{"webhook": slog.LevelDebug})
+	log := logger.NewWithOptions(&buf, "todo-service", logger.Options{Levels: levels})
+	webhook := log.With(logger.ComponentKey, "webhook", "attempt", 1)
+
+	log.Debug("hidden")
+	webhook.Debug("shown")
+	got := records(t, &buf)
+	if len(got) != 1 || got[0]["msg"] != "shown" || got[0]["component"] != "webhook" || got[0]["attempt"] != 1.0 {
+		t.Fatalf("got %v, want only the webhook's debug record", got)
+	}
+	webhook.Info("replaced")
+	if n := strings.Count(buf.String(), `"component"`); n != 1 {
+		t.Errorf("got %q, want the component written once", buf.String())
+	}
+	buf.Reset()
+
+	levels.Set("", slog.LevelWarn)
+	levels.Unset("webhook")
+	log.Info("hidden")
+	webhook.Info("hidden")
+	if got := records(t, &buf); len(got) != 0 {
+		t.Errorf("got %v, want nothing below warn", got)
+	}
+
+	// Groups opened after the component keep it outside them.
+	log.With(logger.ComponentKey, "http").WithGroup("request").Warn("grouped", "path", "/")
+	got = records(t, &buf)
+	if len(got) != 1 || got[0]["component"] != "http" || got[0]["request"] == nil {
+		t.Errorf("got %v, want the component beside the group", got)
+	}
+}
+
+func TestLevels_SetFor(t *testing.T) {
+	levels := logger.NewLevels(slog.LevelInfo)
+	levels.Set("cache", slog.LevelWarn)
+
+	levels.SetFor("cache", slog.LevelDebug, 20*time.Millisecond)
+	levels.SetFor("cache", slog.LevelError, 20*time.Millisecond)
+	levels.SetFor("http", slog.LevelDebug, 20*time.Millisecond)
+	if got := levels.Level("cache"); got != slog.LevelError {
+		t.Errorf("got %v, want the latest change", got)
+	}
+	time.Sleep(60 * time.Millisecond)
+	level, overrides := levels.Snapshot()
+	if level != slog.LevelInfo || len(overrides) != 1 || overrides["cache"] != slog.LevelWarn {
+		t.Errorf("got %v and %v, want info and cache back at warn", level, overrides)
+	}
+
+	// A permanent change is not undone.
+	levels.SetFor("", slog.LevelDebug, 20*time.Millisecond)
+	levels.Set("", slog.LevelError)
+	time.Sleep(60 * time.Millisecond)
+	if got := levels.Level(""); got != slog.LevelError {
+		t.Errorf("got %v, want the permanent change kept", got)
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	got, err := logger.ParseLevels(" webhook=DEBUG, http=warn ,")
+	if err != nil || len(got) != 2 || got["webhook"] != slog.LevelDebug || got["http"] != slog.LevelWarn {
+		t.Errorf("ParseLevels() = %v, %v", got, err)
+	}
+	for _, bad := range []string{"webhook", "=debug", "webhook=loud"} {
+		if _, err := logger.ParseLevels(bad); err == nil {
+			t.Errorf("ParseLevels(%q) expected error, got nil", bad)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	for _, format := range logger.Formats {
+		var buf bytes.Buffer
+		log := logger.NewWithOptions(&buf, "todo-service", logger.Options{Format: format})
+		log.Info("request done", "status", 200, slog.Group("db", "query", "select 1"))
+		out := buf.String()
+		for _, want := range []string{"request done", "status", "200", "todo-service", "select 1"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: %q does not contain %q", format, out, want)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	logger.NewWithOptions(&buf, "", logger.Options{Format: logger.FormatPretty}).Warn("careful", "path", "/a b")
+	if out := buf.String(); !strings.Contains(out, "WARN") || !strings.Contains(out, `"/a b"`) || strings.Count(out, "\n") != 1 {
+		t.Errorf("got %q, want one line with the level and a quoted value", out)
+	}
+	if _, err := logger.ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat() expected error, got nil")
+	}
+}
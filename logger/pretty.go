@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ANSI colours of the levels written by the pretty handler.
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "\x1b[2m"
+	colorDebug = "\x1b[35m"
+	colorInfo  = "\x1b[36m"
+	colorWarn  = "\x1b[33m"
+	colorError = "\x1b[31m"
+)
+
+// prettyHandler writes a record per line for people rather than machines:
+// the time of day, a coloured level, the message and then key=value
+// attributes, with those of groups prefixed by the group's name.
+type prettyHandler struct {
+	mu    *sync.Mutex
+	out   io.Writer
+	level slog.Leveler
+	// attrs are the attributes given to WithAttrs, already formatted.
+	attrs  string
+	prefix string
+}
+
+func newPrettyHandler(out io.Writer, level slog.Leveler) *prettyHandler {
+	return &prettyHandler{mu: new(sync.Mutex), out: out, level: level}
+}
+
+func (h *prettyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *prettyHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if !r.Time.IsZero() {
+		b.WriteString(colorDim + r.Time.Format("15:04:05.000") + colorReset + " ")
+	}
+	fmt.Fprintf(&b, "%s%-5s%s %s", levelColor(r.Level), r.Level.String(), colorReset, r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	var b strings.Builder
+	for _, a := range attrs {
+		writeAttr(&b, h.prefix, a)
+	}
+	c.attrs += b.String()
+	return &c
+}
+
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix += name + "."
+	return &c
+}
+
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return colorError
+	case level >= slog.LevelWarn:
+		return colorWarn
+	case level >= slog.LevelInfo:
+		return colorInfo
+	}
+	return colorDebug
+}
+
+// writeAttr writes " key=value", or one such pair per member of a group.
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			writeAttr(b, prefix, member)
+		}
+		return
+	}
+	b.WriteString(" " + colorDim + prefix + a.Key + "=" + colorReset)
+	var s string
+	switch a.Value.Kind() {
+	case slog.KindTime:
+		s = a.Value.Time().Format(time.RFC3339Nano)
+	default:
+		s = a.Value.String()
+	}
+	b.WriteString(quoteIfNeeded(s))
+}
+
+// quoteIfNeeded quotes s when it would not read as a single value.
+func quoteIfNeeded(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue is logged in place of what redaction hides.
+const RedactedValue = "[redacted]"
+
+// Redacted is a string, such as a token, that is never logged: its
+// LogValue, String, GoString and MarshalText all give RedactedValue. Use
+// string(r) where the content is needed.
+type Redacted string
+
+func (Redacted) LogValue() slog.Value {
+	return slog.StringValue(RedactedValue)
+}
+
+func (Redacted) String() string {
+	return RedactedValue
+}
+
+func (Redacted) GoString() string {
+	return RedactedValue
+}
+
+func (Redacted) MarshalText() ([]byte, error) {
+	return []byte(RedactedValue), nil
+}
+
+// DefaultRedactKeys are the attribute keys redacted by default.
+var DefaultRedactKeys = []string{"authorization", "cookie", "password", "secret", "token", "api_key", "email"}
+
+// DefaultRedactPatterns mask email addresses, bearer tokens, JWTs and the
+// passwords of URLs wherever they appear in messages and string values.
+var DefaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	regexp.MustCompile(`://[^:/@\s]+:([^@\s]+)@`),
+}
+
+// RedactOptions configures NewRedactingHandler.
+type RedactOptions struct {
+	// Keys are the attribute keys whose values are replaced, whole groups
+	// included. They match regardless of case and of '-' or '_', and also
+	// as the last word of a key: "token" matches "Admin-Token". Nil uses
+	// DefaultRedactKeys.
+	Keys []string
+	// Patterns mask what they match in messages, string values and errors;
+	// a pattern with a capturing group masks only what the first group
+	// matches. Nil uses DefaultRedactPatterns.
+	Patterns []*regexp.Regexp
+}
+
+// redactHandler scrubs records before passing them to next. Values of
+// kinds other than strings, groups and errors, such as structs, are passed
+// as they are: fields that must not be logged should be Redacted.
+type redactHandler struct {
+	next     slog.Handler
+	keys     []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactingHandler returns a handler that passes records to next with
+// the values of sensitive attributes replaced by RedactedValue and the
+// sensitive parts of messages and other values masked.
+func NewRedactingHandler(next slog.Handler, opts RedactOptions) slog.Handler {
+	keys := opts.Keys
+	if keys == nil {
+		keys = DefaultRedactKeys
+	}
+	patterns := opts.Patterns
+	if patterns == nil {
+		patterns = DefaultRedactPatterns
+	}
+	h := &redactHandler{next: next, patterns: patterns}
+	for _, k := range keys {
+		h.keys = append(h.keys, normalizeKey(k))
+	}
+	return h
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.mask(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	c := *h
+	c.next = h.next.WithAttrs(redacted)
+	return &c
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.next = h.next.WithGroup(name)
+	return &c
+}
+
+func (h *redactHandler) redact(a slog.Attr) slog.Attr {
+	if h.sensitive(a.Key) {
+		return slog.String(a.Key, RedactedValue)
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		members := v.Group()
+		redacted := make([]slog.Attr, len(members))
+		for i, m := range members {
+			redacted[i] = h.redact(m)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindString:
+		return slog.String(a.Key, h.mask(v.String()))
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			if s := err.Error(); h.mask(s) != s {
+				return slog.String(a.Key, h.mask(s))
+			}
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+func (h *redactHandler) sensitive(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range h.keys {
+		if key == k || strings.HasSuffix(key, "_"+k) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "-", "_")
+}
+
+// mask replaces what the patterns match in s.
+func (h *redactHandler) mask(s string) string {
+	for _, p := range h.patterns {
+		if p.NumSubexp() == 0 {
+			s = p.ReplaceAllLiteralString(s, RedactedValue)
+			continue
+		}
+		matches := p.FindAllStringSubmatchIndex(s, -1)
+		if matches == nil {
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			if m[2] < 0 {
+				continue
+			}
+			b.WriteString(s[last:m[2]])
+			b.WriteString(RedactedValue)
+			last = m[3]
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jllovet/go-server-template/logger"
+)
+
+// leak is a secret that must never reach the output.
+const leak = "hunter2-s3cret"
+
+func TestRedaction(t *testing.T) {
+	type credentials struct {
+		User  string
+		Token logger.Redacted
+	}
+	logAll := func(log *slog.Logger) {
+		log.Info("login", "password", leak)
+		log.Info("login", "Authorization", "Bearer "+leak)
+		log.Info("login", "X-Admin-Token", leak)
+		log.Info("login", slog.Group("user", "name", "ann", "email", "ann@example.com"))
+		log.Info("login", "credentials", slog.GroupValue(slog.String("secret", leak)))
+		log.With("api_key", leak).Info("login")
+		log.WithGroup("request").Info("login", "cookie", leak)
+		log.Info("login", "token", logger.Redacted(leak))
+		log.Info("login", "value", logger.Redacted(leak))
+		log.Info("login", "creds", credentials{User: "ann", Token: logger.Redacted(leak)})
+		log.Info("login", "header", "authorization: Bearer "+leak)
+		log.Info("login", "error", fmt.Errorf("calling postgres://ann:%s@db/todo: refused", leak))
+		log.Info("mailing ann@example.com with bearer " + leak)
+		log.Info("login", "jwt", "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiJhbm4ifQ."+leak)
+		log.Info(fmt.Sprintf("%v %s %#v", logger.Redacted(leak), logger.Redacted(leak), logger.Redacted(leak)))
+	}
+
+	for _, format := range logger.Formats {
+		var buf bytes.Buffer
+		logAll(logger.NewWithOptions(&buf, "test", logger.Options{Format: format}))
+		out := buf.String()
+		for _, secret := range []string{leak, "ann@example.com"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%s: output leaks %q:\n%s", format, secret, out)
+			}
+		}
+		if got := strings.Count(out, logger.RedactedValue); got < 15 {
+			t.Errorf("%s: got %d redactions, want at least 15:\n%s", format, got, out)
+		}
+		// Masking keeps the rest of a value.
+		if !strings.Contains(out, "postgres://ann:"+logger.RedactedValue+"@db/todo") || !strings.Contains(out, "ann") {
+			t.Errorf("%s: output lost what is not sensitive:\n%s", format, out)
+		}
+	}
+}
+
+func TestRedactingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := logger.NewRedactingHandler(slog.NewTextHandler(&buf, nil), logger.RedactOptions{
+		Keys:     []string{"ssn"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`card ([0-9]{4})`)},
+	})
+	log := slog.New(h)
+	log.Info("paid by card 4242", "ssn", "123-45-6789", "password", "kept", "err", errors.New("card 4242 declined"))
+
+	out := buf.String()
+	for _, leaked := range []string{"4242", "123-45-6789"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("output leaks %q: %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "password=kept") || !strings.Contains(out, "card [redacted] declined") {
+		t.Errorf("got %s, want only the configured keys and patterns redacted", out)
+	}
+	if !h.Enabled(context.Background(), slog.LevelInfo) || h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Enabled() should follow the wrapped handler")
+	}
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SampleOptions configures NewSamplingHandler.
+type SampleOptions struct {
+	// First is how many records with the same level and message are passed
+	// in each Interval. Zero turns sampling off.
+	First int
+	// Thereafter passes one in every Thereafter of the records that follow
+	// the first First in an Interval. Zero drops them all.
+	Thereafter int
+	Interval   time.Duration
+}
+
+// sampleKey is what records are counted by.
+type sampleKey struct {
+	level   slog.Level
+	message string
+}
+
+// sampler counts records in intervals. It is shared by the handlers derived
+// from the same NewSamplingHandler, so that a message is counted once
+// however many loggers log it.
+type sampler struct {
+	opts   SampleOptions
+	mu     sync.Mutex
+	start  time.Time
+	counts map[sampleKey]int
+}
+
+// allow counts r and reports whether it should be passed on.
+func (s *sampler) allow(r slog.Record) bool {
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Counts are dropped together at the end of every interval, which also
+	// keeps the map from growing with messages that are no longer logged.
+	if now.Sub(s.start) >= s.opts.Interval {
+		s.start = now
+		clear(s.counts)
+	}
+	key := sampleKey{level: r.Level, message: r.Message}
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.opts.First {
+		return true
+	}
+	return s.opts.Thereafter > 0 && (n-s.opts.First)%s.opts.Thereafter == 0
+}
+
+type sampleHandler struct {
+	next    slog.Handler
+	sampler *sampler
+}
+
+// NewSamplingHandler returns a handler that passes to next the first
+// opts.First records with each level and message in every opts.Interval,
+// then one in every opts.Thereafter. Warnings and errors are always
+// passed. With opts.First zero it returns next.
+func NewSamplingHandler(next slog.Handler, opts SampleOptions) slog.Handler {
+	if opts.First <= 0 || opts.Interval <= 0 {
+		return next
+	}
+	return &sampleHandler{next: next, sampler: &sampler{opts: opts, counts: map[sampleKey]int{}}}
+}
+
+func (h *sampleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *sampleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn && !h.sampler.allow(r) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *sampleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampleHandler{next: h.next.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *sampleHandler) WithGroup(name string) slog.Handler {
+	return &sampleHandler{next: h.next.WithGroup(name), sampler: h.sampler}
+}
@@ -0,0 +1,93 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jllovet/go-server-template/logger"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := logger.NewSamplingHandler(slog.NewTextHandler(&buf, nil), logger.SampleOptions{
+		First:      3,
+		Thereafter: 5,
+		Interval:   time.Second,
+	})
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	log := func(at time.Duration, level slog.Level, msg string) {
+		t.Helper()
+		if err := h.Handle(context.Background(), slog.NewRecord(start.Add(at), level, msg, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(msg string) int {
+		return strings.Count(buf.String(), "msg="+msg+"\n")
+	}
+
+	t.Run("1. First Then One In Thereafter", func(t *testing.T) {
+		for range 20 {
+			log(0, slog.LevelInfo, "probe")
+		}
+		// Records 1 to 3, then 8, 13 and 18.
+		if got := count("probe"); got != 6 {
+			t.Errorf("got %d records, want 6", got)
+		}
+	})
+
+	t.Run("2. Counted By Level And Message", func(t *testing.T) {
+		for range 3 {
+			log(0, slog.LevelInfo, "other")
+			log(0, slog.LevelDebug, "probe")
+		}
+		if got := count("other"); got != 3 {
+			t.Errorf("got %d records of another message, want 3", got)
+		}
+		if got := strings.Count(buf.String(), "level=DEBUG"); got != 3 {
+			t.Errorf("got %d records at another level, want 3", got)
+		}
+	})
+
+	t.Run("3. Warnings And Errors Always Pass", func(t *testing.T) {
+		for range 20 {
+			log(0, slog.LevelWarn, "slow")
+			log(0, slog.LevelError, "failed")
+		}
+		if got, want := count("slow")+count("failed"), 40; got != want {
+			t.Errorf("got %d records, want %d", got, want)
+		}
+	})
+
+	t.Run("4. Counts Restart Every Interval", func(t *testing.T) {
+		buf.Reset()
+		for range 4 {
+			log(time.Second, slog.LevelInfo, "probe")
+		}
+		if got := count("probe"); got != 3 {
+			t.Errorf("got %d records, want 3", got)
+		}
+	})
+
+	t.Run("5. Derived Handlers Share Counts", func(t *testing.T) {
+		buf.Reset()
+		l := slog.New(h)
+		for i := range 10 {
+			l.With("n", i).WithGroup("g").Info("derived")
+		}
+		// Records 1 to 3, then 8.
+		if got := strings.Count(buf.String(), "msg=derived"); got != 4 {
+			t.Errorf("got %d records, want 4", got)
+		}
+	})
+
+	t.Run("6. Zero Options Keep Everything", func(t *testing.T) {
+		next := slog.NewTextHandler(&buf, nil)
+		if got := logger.NewSamplingHandler(next, logger.SampleOptions{}); got != slog.Handler(next) {
+			t.Errorf("got %T, want the handler itself", got)
+		}
+	})
+}